@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// accessTracker records when and how often objects are downloaded and writes
+// them to the meta store in the background, so downloads never wait on a
+// write. Repeated downloads of an object between flushes are coalesced into a
+// single update.
+type accessTracker struct {
+	mu      sync.Mutex
+	pending map[string]access
+
+	stop  chan struct{}
+	wg    sync.WaitGroup
+	store *MetaStore
+}
+
+// EnableAccessTracking turns on recording of object access times, which are
+// flushed to the store every interval.
+func (s *MetaStore) EnableAccessTracking(interval time.Duration) {
+	t := &accessTracker{
+		pending: make(map[string]access),
+		stop:    make(chan struct{}),
+		store:   s,
+	}
+	s.access = t
+
+	t.wg.Add(1)
+	go t.run(interval)
+}
+
+// Touch records that the object was accessed at. It is a no-op when access
+// tracking is not enabled.
+func (s *MetaStore) Touch(oid string, at time.Time) {
+	if s.access == nil {
+		return
+	}
+	s.access.touch(oid, at)
+}
+
+func (t *accessTracker) run(interval time.Duration) {
+	defer t.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-t.stop:
+			return
+		}
+
+		if err := t.flush(); err != nil {
+			logger.Log(kv{"fn": "accessTracker", "err": "Could not flush access times: " + err.Error()})
+		}
+	}
+}
+
+// access is the latest time an object was downloaded at and how many times
+// it was since the last flush.
+type access struct {
+	at    time.Time
+	count int64
+}
+
+func (t *accessTracker) touch(oid string, at time.Time) {
+	t.add(oid, access{at: at, count: 1})
+}
+
+func (t *accessTracker) add(oid string, a access) {
+	t.mu.Lock()
+	p := t.pending[oid]
+	if a.at.After(p.at) {
+		p.at = a.at
+	}
+	p.count += a.count
+	t.pending[oid] = p
+	t.mu.Unlock()
+}
+
+func (t *accessTracker) take() map[string]access {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	pending := t.pending
+	t.pending = make(map[string]access)
+	return pending
+}
+
+// flush writes all pending accesses in one transaction. Objects deleted since
+// they were accessed are skipped. Accesses that could not be written are put
+// back to be retried with the next flush.
+func (t *accessTracker) flush() error {
+	pending := t.take()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	err := t.store.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(objectsBucket)
+		if bucket == nil {
+			return errNoBucket
+		}
+
+		for oid, a := range pending {
+			value := bucket.Get([]byte(oid))
+			if len(value) == 0 {
+				continue
+			}
+
+			var meta MetaObject
+			if err := gob.NewDecoder(bytes.NewBuffer(value)).Decode(&meta); err != nil {
+				return err
+			}
+			meta.Downloads += a.count
+			if meta.LastAccessedAt == nil || a.at.After(*meta.LastAccessedAt) {
+				at := a.at
+				meta.LastAccessedAt = &at
+			}
+
+			var buf bytes.Buffer
+			if err := gob.NewEncoder(&buf).Encode(meta); err != nil {
+				return err
+			}
+			if err := bucket.Put([]byte(oid), buf.Bytes()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		for oid, a := range pending {
+			t.add(oid, a)
+		}
+	}
+	return err
+}
+
+func (t *accessTracker) close() error {
+	close(t.stop)
+	t.wg.Wait()
+	return t.flush()
+}
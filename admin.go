@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const defaultAdminUser = "admin"
+
+// storedAdmin holds the admin credentials kept in the meta store. There is a
+// single admin, kept apart from the users, and only a bcrypt hash of their
+// password is stored. The SHA-256 of the last password that matched it is kept
+// in memory, so that every mgmt request does not pay for bcrypt.
+var storedAdmin struct {
+	sync.RWMutex
+	user     string
+	hash     []byte
+	verified []byte
+}
+
+// loadAdmin reads the admin credentials stored in the meta store. If none are
+// stored yet, LFS_ADMINUSER and LFS_ADMINPASS are stored first, their password
+// hashed. They are not read again once an admin is stored, so changing them
+// later has no effect and the password is changed with resetadmin instead. A
+// warning is logged when they no longer match the stored admin, so that the
+// change is not silently ignored.
+func loadAdmin(s *MetaStore) error {
+	user, hash, err := s.Admin()
+	if err != nil {
+		return err
+	}
+
+	configured := Config.AdminUser != "" && Config.AdminPass != ""
+	if user == "" && configured {
+		hash, err = bcrypt.GenerateFromPassword([]byte(Config.AdminPass), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+		if err := s.SetAdmin(Config.AdminUser, hash); err != nil {
+			return err
+		}
+		user = Config.AdminUser
+	} else if configured && (user != Config.AdminUser || bcrypt.CompareHashAndPassword(hash, []byte(Config.AdminPass)) != nil) {
+		logger.Log(kv{"fn": "loadAdmin", "msg": "LFS_ADMINUSER and LFS_ADMINPASS do not match the stored admin and are ignored, change the password with resetadmin", "user": user})
+	}
+
+	storedAdmin.Lock()
+	storedAdmin.user, storedAdmin.hash, storedAdmin.verified = user, hash, nil
+	storedAdmin.Unlock()
+	return nil
+}
+
+// isAdminEnabled returns true if admin credentials are stored.
+func isAdminEnabled() bool {
+	storedAdmin.RLock()
+	defer storedAdmin.RUnlock()
+	return storedAdmin.user != ""
+}
+
+// checkAdmin returns true if user and pass are the admin credentials.
+func checkAdmin(user, pass string) bool {
+	sum := sha256.Sum256([]byte(pass))
+
+	storedAdmin.RLock()
+	name, hash, verified := storedAdmin.user, storedAdmin.hash, storedAdmin.verified
+	storedAdmin.RUnlock()
+
+	if name == "" || user != name {
+		return false
+	}
+	if verified != nil && subtle.ConstantTimeCompare(verified, sum[:]) == 1 {
+		return true
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(pass)) != nil {
+		return false
+	}
+
+	storedAdmin.Lock()
+	if bytes.Equal(storedAdmin.hash, hash) {
+		storedAdmin.verified = sum[:]
+	}
+	storedAdmin.Unlock()
+	return true
+}
+
+// ResetAdminPassword generates a random admin password and stores its bcrypt
+// hash, replacing the current admin credentials. It returns the admin user,
+// which keeps the stored name, or LFS_ADMINUSER if none was stored yet.
+func ResetAdminPassword(s *MetaStore) (user, pass string, err error) {
+	user, _, err = s.Admin()
+	if err != nil {
+		return "", "", err
+	}
+	if user == "" {
+		user = Config.AdminUser
+	}
+	if user == "" {
+		user = defaultAdminUser
+	}
+
+	var b [24]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", "", err
+	}
+	pass = base64.RawURLEncoding.EncodeToString(b[:])
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost)
+	if err != nil {
+		return "", "", err
+	}
+	if err := s.SetAdmin(user, hash); err != nil {
+		return "", "", err
+	}
+
+	return user, pass, loadAdmin(s)
+}
+
+// runResetAdmin resets the admin password in the configured meta store and
+// prints the new credentials, returning the process exit code.
+func runResetAdmin() int {
+	metaStore, err := NewMetaStore(Config.MetaDB)
+	if err != nil {
+		fmt.Printf("Could not open the meta store: %s\n", err)
+		return 1
+	}
+	defer metaStore.Close()
+
+	user, pass, err := ResetAdminPassword(metaStore)
+	if err != nil {
+		fmt.Printf("Could not reset the admin password: %s\n", err)
+		return 1
+	}
+
+	fmt.Printf("user: %s\npassword: %s\n", user, pass)
+	return 0
+}
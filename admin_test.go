@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestResetAdminPassword(t *testing.T) {
+	setupMeta()
+	defer teardownMeta()
+	defer func(user string, hash, verified []byte) {
+		storedAdmin.user, storedAdmin.hash, storedAdmin.verified = user, hash, verified
+	}(storedAdmin.user, storedAdmin.hash, storedAdmin.verified)
+
+	if !checkAdmin(testAdminUser, testAdminPass) {
+		t.Fatalf("expected configured admin to authenticate")
+	}
+
+	user, pass, err := ResetAdminPassword(metaStoreTest)
+	if err != nil {
+		t.Fatalf("expected reset to succeed, got: %s", err)
+	}
+	if user != testAdminUser {
+		t.Errorf("expected admin user %s, got %s", testAdminUser, user)
+	}
+	if len(pass) < 20 {
+		t.Errorf("expected a strong password, got %q", pass)
+	}
+
+	if !checkAdmin(user, pass) {
+		t.Errorf("expected generated password to authenticate")
+	}
+	if checkAdmin(testAdminUser, testAdminPass) {
+		t.Errorf("expected old password to be rejected")
+	}
+	if _, ok := metaStoreTest.Authenticate(user, pass); !ok {
+		t.Errorf("expected generated password to authenticate against the meta store")
+	}
+
+	_, stored, err := metaStoreTest.Admin()
+	if err != nil {
+		t.Fatalf("error reading stored admin: %s", err)
+	}
+	if string(stored) == pass {
+		t.Errorf("expected password to be stored hashed")
+	}
+
+	_, again, err := ResetAdminPassword(metaStoreTest)
+	if err != nil {
+		t.Fatalf("expected second reset to succeed, got: %s", err)
+	}
+	if checkAdmin(user, pass) || !checkAdmin(user, again) {
+		t.Errorf("expected only the latest generated password to authenticate")
+	}
+}
+
+func TestLoadAdmin(t *testing.T) {
+	setupMeta()
+	defer teardownMeta()
+	defer func(user string, hash, verified []byte) {
+		storedAdmin.user, storedAdmin.hash, storedAdmin.verified = user, hash, verified
+	}(storedAdmin.user, storedAdmin.hash, storedAdmin.verified)
+	defer func(user, pass string) { Config.AdminUser, Config.AdminPass = user, pass }(Config.AdminUser, Config.AdminPass)
+
+	if err := loadAdmin(metaStoreTest); err != nil {
+		t.Fatalf("expected load to succeed, got: %s", err)
+	}
+	user, hash, err := metaStoreTest.Admin()
+	if err != nil || user != testAdminUser || string(hash) == testAdminPass {
+		t.Fatalf("expected the configured admin to be stored hashed, got %s %q %v", user, hash, err)
+	}
+
+	// The stored admin is kept once there is one, with a warning
+	defer func(l *KVLogger) { logger = l }(logger)
+	var log bytes.Buffer
+	logger = NewKVLogger(&log)
+	if err := loadAdmin(metaStoreTest); err != nil {
+		t.Fatalf("expected load to succeed, got: %s", err)
+	}
+	if strings.Contains(log.String(), "resetadmin") {
+		t.Errorf("expected no warning while LFS_ADMINPASS matches, got %q", log.String())
+	}
+
+	Config.AdminPass = "changed"
+	if err := loadAdmin(metaStoreTest); err != nil {
+		t.Fatalf("expected load to succeed, got: %s", err)
+	}
+	if !checkAdmin(testAdminUser, testAdminPass) || checkAdmin(testAdminUser, "changed") {
+		t.Errorf("expected the stored admin to be kept over a changed LFS_ADMINPASS")
+	}
+	if !strings.Contains(log.String(), "resetadmin") {
+		t.Errorf("expected a warning that LFS_ADMINPASS is ignored, got %q", log.String())
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/context"
+)
+
+var errDownloadDenied = errors.New("You do not have permission to download this object")
+
+// Authorizer decides whether a user may download an object from a repo. The
+// user is empty when the server is public, and the ref is the git ref a batch
+// request was made for, if it named one.
+type Authorizer interface {
+	Authorize(user, repo, oid, ref string) (bool, error)
+}
+
+// httpAuthorizer asks an external service for each decision. The request is
+// posted as JSON, and the service answers 200 to allow the download or 403 to
+// deny it. Any other answer is an error.
+type httpAuthorizer struct {
+	url    string
+	client *http.Client
+}
+
+type authorizeRequest struct {
+	User string `json:"user"`
+	Repo string `json:"repo"`
+	Oid  string `json:"oid"`
+	Ref  string `json:"ref,omitempty"`
+}
+
+func newHTTPAuthorizer(url string) *httpAuthorizer {
+	return &httpAuthorizer{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (a *httpAuthorizer) Authorize(user, repo, oid, ref string) (bool, error) {
+	body, err := json.Marshal(authorizeRequest{User: user, Repo: repo, Oid: oid, Ref: ref})
+	if err != nil {
+		return false, err
+	}
+
+	res, err := a.client.Post(a.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusForbidden:
+		return false, nil
+	}
+	return false, fmt.Errorf("authorizer answered %d", res.StatusCode)
+}
+
+// cachingAuthorizer remembers the decisions of another Authorizer for a while,
+// so that a clone of many objects does not ask it for each one again. Errors
+// are not remembered.
+type cachingAuthorizer struct {
+	Authorizer
+	mu        sync.Mutex
+	ttl       time.Duration
+	decisions map[authorizeRequest]authorizeDecision
+}
+
+type authorizeDecision struct {
+	allowed bool
+	expires time.Time
+}
+
+func newCachingAuthorizer(a Authorizer, ttl time.Duration) *cachingAuthorizer {
+	return &cachingAuthorizer{Authorizer: a, ttl: ttl, decisions: make(map[authorizeRequest]authorizeDecision)}
+}
+
+func (c *cachingAuthorizer) Authorize(user, repo, oid, ref string) (bool, error) {
+	key := authorizeRequest{User: user, Repo: repo, Oid: oid, Ref: ref}
+	now := time.Now()
+
+	c.mu.Lock()
+	for k, d := range c.decisions {
+		if !now.Before(d.expires) {
+			delete(c.decisions, k)
+		}
+	}
+	d, ok := c.decisions[key]
+	c.mu.Unlock()
+	if ok {
+		return d.allowed, nil
+	}
+
+	allowed, err := c.Authorizer.Authorize(user, repo, oid, ref)
+	if err != nil {
+		return false, err
+	}
+
+	c.mu.Lock()
+	c.decisions[key] = authorizeDecision{allowed: allowed, expires: now.Add(c.ttl)}
+	c.mu.Unlock()
+	return allowed, nil
+}
+
+// newAuthorizer returns the configured download Authorizer, or nil if every
+// authenticated user may download every object.
+func newAuthorizer() Authorizer {
+	if Config.AuthURL == "" {
+		return nil
+	}
+	return newCachingAuthorizer(newHTTPAuthorizer(Config.AuthURL), Config.AuthCacheDuration())
+}
+
+// canDownload asks the authorizer, if there is one, whether the user of r may
+// download the object of rv. The repo is named with its owner, as in
+// "user/repo", so that repos of the same name are told apart.
+func (a *App) canDownload(r *http.Request, rv *RequestVars) (bool, error) {
+	if a.authorizer == nil {
+		return true, nil
+	}
+
+	user, _ := context.Get(r, "USER").(string)
+	allowed, err := a.authorizer.Authorize(user, refName(rv), rv.Oid, rv.GitRef)
+	if err != nil {
+		logger.Log(kv{"fn": "canDownload", "oid": rv.Oid, "user": user, "err": "Could not authorize download: " + err.Error()})
+	}
+	return allowed, err
+}
+
+// authorizeDownload answers the request itself and returns false if the
+// download of the object of rv is not authorized.
+func (a *App) authorizeDownload(w http.ResponseWriter, r *http.Request, rv *RequestVars) bool {
+	allowed, err := a.canDownload(r, rv)
+	if err != nil {
+		writeStatus(w, r, 503, false)
+		return false
+	}
+	if !allowed {
+		writeDownloadForbidden(w, r)
+		return false
+	}
+	return true
+}
+
+// writeDownloadForbidden answers a download the authorizer denied.
+func writeDownloadForbidden(w http.ResponseWriter, r *http.Request) {
+	requestID, _ := context.Get(r, "RequestID").(string)
+
+	w.Header().Set("Content-Type", metaMediaType)
+	w.WriteHeader(403)
+	json.NewEncoder(w).Encode(struct {
+		Message   string `json:"message"`
+		RequestID string `json:"request_id,omitempty"`
+	}{errDownloadDenied.Error(), requestID})
+
+	logRequest(r, 403)
+}
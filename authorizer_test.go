@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// mockAuthorizer allows downloads for the users in allowed and counts the
+// decisions it is asked for.
+type mockAuthorizer struct {
+	allowed map[string]bool
+	err     error
+	calls   int
+	repo    string
+	ref     string
+}
+
+func (m *mockAuthorizer) Authorize(user, repo, oid, ref string) (bool, error) {
+	m.calls++
+	m.repo = repo
+	m.ref = ref
+	if m.err != nil {
+		return false, m.err
+	}
+	return m.allowed[user], nil
+}
+
+func TestCachingAuthorizer(t *testing.T) {
+	mock := &mockAuthorizer{allowed: map[string]bool{"allowed": true}}
+	auth := newCachingAuthorizer(mock, time.Hour)
+
+	for i := 0; i < 2; i++ {
+		if ok, err := auth.Authorize("allowed", "repo", contentOid, ""); !ok || err != nil {
+			t.Errorf("expected download to be allowed, got %v %v", ok, err)
+		}
+		if ok, err := auth.Authorize("denied", "repo", contentOid, ""); ok || err != nil {
+			t.Errorf("expected download to be denied, got %v %v", ok, err)
+		}
+	}
+	if mock.calls != 2 {
+		t.Errorf("expected repeated decisions to be cached, got %d calls", mock.calls)
+	}
+
+	mock.err = errors.New("unavailable")
+	for i := 0; i < 2; i++ {
+		if _, err := auth.Authorize("other", "repo", contentOid, ""); err == nil {
+			t.Errorf("expected authorizer error to be returned")
+		}
+	}
+	if mock.calls != 4 {
+		t.Errorf("expected errors to not be cached, got %d calls", mock.calls)
+	}
+
+	expiring := newCachingAuthorizer(mock, time.Nanosecond)
+	mock.err = nil
+	expiring.Authorize("allowed", "repo", contentOid, "")
+	time.Sleep(time.Millisecond)
+	expiring.Authorize("allowed", "repo", contentOid, "")
+	if mock.calls != 6 {
+		t.Errorf("expected expired decisions to be asked again, got %d calls", mock.calls)
+	}
+}
+
+func TestHTTPAuthorizer(t *testing.T) {
+	callback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req authorizeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(400)
+			return
+		}
+		switch req.User {
+		case "allowed":
+			if req.Repo != "repo" || req.Oid != contentOid {
+				w.WriteHeader(400)
+				return
+			}
+			w.WriteHeader(200)
+		case "denied":
+			w.WriteHeader(403)
+		default:
+			w.WriteHeader(500)
+		}
+	}))
+	defer callback.Close()
+
+	auth := newHTTPAuthorizer(callback.URL)
+	if ok, err := auth.Authorize("allowed", "repo", contentOid, ""); !ok || err != nil {
+		t.Errorf("expected download to be allowed, got %v %v", ok, err)
+	}
+	if ok, err := auth.Authorize("denied", "repo", contentOid, ""); ok || err != nil {
+		t.Errorf("expected download to be denied, got %v %v", ok, err)
+	}
+	if ok, err := auth.Authorize("broken", "repo", contentOid, ""); ok || err == nil {
+		t.Errorf("expected an error for an unexpected answer, got %v %v", ok, err)
+	}
+}
+
+func TestDownloadAuthorizer(t *testing.T) {
+	mock := &mockAuthorizer{allowed: map[string]bool{testUser: true}}
+	app := NewApp(testContentStore, testMetaStore)
+	app.authorizer = mock
+	server := httptest.NewServer(app)
+	defer server.Close()
+
+	do := func(method, path, accept, user, pass string, body []byte) *http.Response {
+		req, err := http.NewRequest(method, server.URL+path, bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		req.SetBasicAuth(user, pass)
+		req.Header.Set("Accept", accept)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("response error: %s", err)
+		}
+		return res
+	}
+
+	path := "/user/repo/objects/" + contentOid
+	if res := do("GET", path, contentMediaType, testUser, testPass, nil); res.StatusCode != 200 {
+		t.Errorf("expected allowed download to succeed, got %d", res.StatusCode)
+	}
+
+	res := do("GET", path, contentMediaType, testUser1, testPass1, nil)
+	if res.StatusCode != 403 {
+		t.Fatalf("expected denied download to get 403, got %d", res.StatusCode)
+	}
+	var e struct{ Message string }
+	if err := json.NewDecoder(res.Body).Decode(&e); err != nil || e.Message != errDownloadDenied.Error() {
+		t.Errorf("expected a denied message, got %q %v", e.Message, err)
+	}
+
+	batch := []byte(fmt.Sprintf(`{"operation":"download","ref":{"name":"refs/heads/main"},"objects":[{"oid":"%s","size":%d}]}`, contentOid, contentSize))
+	res = do("POST", "/user/repo/objects/batch", metaMediaType, testUser1, testPass1, batch)
+	var response BatchResponse
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		t.Fatalf("expected response body to be a batch response, got: %s", err)
+	}
+	if len(response.Objects) != 1 || response.Objects[0].Error == nil || response.Objects[0].Error.Code != 403 {
+		t.Errorf("expected the denied object to carry a 403 error, got %+v", response.Objects)
+	}
+	if mock.repo != "user/repo" {
+		t.Errorf("expected the repo to be authorized with its owner, got %q", mock.repo)
+	}
+	if mock.ref != "refs/heads/main" {
+		t.Errorf("expected the batch ref to be authorized, got %q", mock.ref)
+	}
+
+	mock.err = errors.New("unavailable")
+	if res := do("GET", path, contentMediaType, testUser, testPass, nil); res.StatusCode != 503 {
+		t.Errorf("expected a failing authorizer to get 503, got %d", res.StatusCode)
+	}
+}
+
+func TestDownloadAuthorizerBeforeScan(t *testing.T) {
+	oid, size := seedObject(t, "TestDownloadAuthorizerBeforeScan content")
+	if _, err := testMetaStore.SetQuarantined(oid, true); err != nil {
+		t.Fatalf("error quarantining object: %s", err)
+	}
+
+	app := NewApp(testContentStore, testMetaStore)
+	app.authorizer = &mockAuthorizer{allowed: map[string]bool{testUser: true}}
+	server := httptest.NewServer(app)
+	defer server.Close()
+
+	batch := fmt.Sprintf(`{"operation":"download","objects":[{"oid":"%s","size":%d}]}`, oid, size)
+	for _, tc := range []struct {
+		user, pass string
+		status     int
+	}{
+		{testUser, testPass, 409},
+		{testUser1, testPass1, 403},
+	} {
+		req, _ := http.NewRequest("GET", server.URL+"/user/repo/objects/"+oid, nil)
+		req.SetBasicAuth(tc.user, tc.pass)
+		req.Header.Set("Accept", contentMediaType)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("response error: %s", err)
+		}
+		res.Body.Close()
+		if res.StatusCode != tc.status {
+			t.Errorf("expected a download by %s to get %d, got %d", tc.user, tc.status, res.StatusCode)
+		}
+
+		req, _ = http.NewRequest("POST", server.URL+"/user/repo/objects/batch", bytes.NewBufferString(batch))
+		req.SetBasicAuth(tc.user, tc.pass)
+		req.Header.Set("Accept", metaMediaType)
+		res, err = http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("response error: %s", err)
+		}
+		var response BatchResponse
+		err = json.NewDecoder(res.Body).Decode(&response)
+		res.Body.Close()
+		if err != nil || len(response.Objects) != 1 || response.Objects[0].Error == nil || response.Objects[0].Error.Code != tc.status {
+			t.Errorf("expected a batch by %s to carry a %d error, got %+v %v", tc.user, tc.status, response.Objects, err)
+		}
+	}
+}
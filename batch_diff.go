@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/context"
+)
+
+// BatchDecision is what a batch request would do with one of its objects:
+// "upload" it, "download" it, "skip" the upload of an object already stored,
+// or refuse it with an "error".
+type BatchDecision struct {
+	Oid     string `json:"oid"`
+	Size    int64  `json:"size"`
+	Action  string `json:"action"`
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// BatchDiff is an object the two batch requests of a diff would treat
+// differently. A nil decision means the object is not in that request.
+type BatchDiff struct {
+	Oid string         `json:"oid"`
+	A   *BatchDecision `json:"a"`
+	B   *BatchDecision `json:"b"`
+}
+
+// BatchDiffResult holds the decisions for each batch request of a diff, and
+// the objects they differ on.
+type BatchDiffResult struct {
+	A           []*BatchDecision `json:"a"`
+	B           []*BatchDecision `json:"b"`
+	Differences []BatchDiff      `json:"differences"`
+}
+
+// batchDiffHandler decides what each of the two batch request bodies "a" and
+// "b" would do against the current state of the server, without changing it.
+// The requests are for the user and repo in the form, as made by the LFS
+// user as, or anonymously if it is not set.
+func (a *App) batchDiffHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var body struct {
+		A *BatchVars `json:"a"`
+		B *BatchVars `json:"b"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.A == nil || body.B == nil {
+		w.WriteHeader(400)
+		fmt.Fprint(w, `{"message":"Expected batch requests a and b"}`)
+		return
+	}
+
+	// Decisions are made as the LFS user, on a copy of the request so that
+	// the admin request keeps its own context.
+	client := r.WithContext(r.Context())
+	defer context.Clear(client)
+	if as := r.FormValue("as"); as != "" {
+		context.Set(client, "USER", as)
+	}
+
+	result := BatchDiffResult{
+		A: a.batchDecisions(client, body.A),
+		B: a.batchDecisions(client, body.B),
+	}
+	result.Differences = diffBatchDecisions(result.A, result.B)
+
+	json.NewEncoder(w).Encode(result)
+}
+
+// batchDecisions returns the decision for each object of a batch request.
+func (a *App) batchDecisions(r *http.Request, bv *BatchVars) []*BatchDecision {
+	var gitRef string
+	if bv.Ref != nil {
+		gitRef = bv.Ref.Name
+	}
+	canWrite := bv.Operation != "upload" || a.canWrite(r)
+
+	decisions := []*BatchDecision{}
+	for _, object := range bv.Objects {
+		object.User = r.FormValue("user")
+		object.Repo = r.FormValue("repo")
+		object.GitRef = gitRef
+
+		decision := &BatchDecision{Oid: object.Oid, Size: object.Size}
+		decisions = append(decisions, decision)
+
+		if !canWrite {
+			decision.Action, decision.Code = "error", 403
+			decision.Message = "You do not have permission to write to this server"
+			continue
+		}
+
+		meta, objErr, status := a.decideBatchObject(r, bv, object)
+		switch {
+		case status != 0:
+			decision.Action, decision.Code, decision.Message = "error", status, http.StatusText(status)
+		case objErr != nil:
+			decision.Action, decision.Code, decision.Message = "error", objErr.Code, objErr.Message
+		case meta == nil:
+			decision.Action = "upload"
+		case bv.Operation == "upload":
+			decision.Action = "skip"
+		default:
+			decision.Action = "download"
+		}
+	}
+	return decisions
+}
+
+// diffBatchDecisions returns the objects decided differently by two batch
+// requests, in the order they first appear.
+func diffBatchDecisions(a, b []*BatchDecision) []BatchDiff {
+	byOid := func(decisions []*BatchDecision) map[string]*BatchDecision {
+		m := make(map[string]*BatchDecision)
+		for _, d := range decisions {
+			if _, ok := m[d.Oid]; !ok {
+				m[d.Oid] = d
+			}
+		}
+		return m
+	}
+	inA, inB := byOid(a), byOid(b)
+
+	diffs := []BatchDiff{}
+	seen := make(map[string]bool)
+	for _, d := range append(append([]*BatchDecision{}, a...), b...) {
+		if seen[d.Oid] {
+			continue
+		}
+		seen[d.Oid] = true
+
+		da, db := inA[d.Oid], inB[d.Oid]
+		if da == nil || db == nil || da.Action != db.Action || da.Code != db.Code {
+			diffs = append(diffs, BatchDiff{Oid: d.Oid, A: da, B: db})
+		}
+	}
+	return diffs
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestMgmtBatchDiff(t *testing.T) {
+	stored, size := seedObject(t, "TestMgmtBatchDiff stored")
+	missing := fmt.Sprintf("%x", sha256.Sum256([]byte("TestMgmtBatchDiff missing")))
+	other := fmt.Sprintf("%x", sha256.Sum256([]byte("TestMgmtBatchDiff other")))
+
+	diff := func(a, b string) BatchDiffResult {
+		body := bytes.NewBufferString(fmt.Sprintf(`{"a":%s,"b":%s}`, a, b))
+		res, err := api("POST", "/mgmt/api/batch/diff?user=user&repo=repo&as="+testUser, "", testAdminUser, testAdminPass, body)
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		if res.StatusCode != 200 {
+			t.Fatalf("expected status 200, got %d", res.StatusCode)
+		}
+		var result BatchDiffResult
+		if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+			t.Fatalf("expected response body to be a diff, got: %s", err)
+		}
+		return result
+	}
+
+	result := diff(
+		fmt.Sprintf(`{"operation":"upload","objects":[{"oid":%q,"size":%d},{"oid":%q,"size":5}]}`, stored, size, missing),
+		fmt.Sprintf(`{"operation":"upload","objects":[{"oid":%q,"size":%d},{"oid":%q,"size":5}]}`, stored, size, other),
+	)
+	if len(result.A) != 2 || result.A[0].Action != "skip" || result.A[1].Action != "upload" {
+		t.Errorf("expected the stored object to be skipped and the missing one uploaded, got %+v %+v", result.A[0], result.A[1])
+	}
+	if len(result.Differences) != 2 {
+		t.Fatalf("expected the objects in only one request to differ, got %d differences", len(result.Differences))
+	}
+	if d := result.Differences[0]; d.Oid != missing || d.A == nil || d.A.Action != "upload" || d.B != nil {
+		t.Errorf("expected the object missing from b first, got %+v", d)
+	}
+	if d := result.Differences[1]; d.Oid != other || d.A != nil || d.B == nil || d.B.Action != "upload" {
+		t.Errorf("expected the object missing from a second, got %+v", d)
+	}
+
+	result = diff(
+		fmt.Sprintf(`{"operation":"download","objects":[{"oid":%q,"size":%d},{"oid":%q,"size":5}]}`, stored, size, missing),
+		fmt.Sprintf(`{"operation":"upload","objects":[{"oid":%q,"size":%d},{"oid":%q,"size":5}]}`, stored, size, missing),
+	)
+	if len(result.Differences) != 2 {
+		t.Fatalf("expected both objects to differ, got %d differences", len(result.Differences))
+	}
+	if d := result.Differences[0]; d.A.Action != "download" || d.B.Action != "skip" {
+		t.Errorf("expected the stored object to be downloaded or skipped, got %+v %+v", d.A, d.B)
+	}
+	if d := result.Differences[1]; d.A.Action != "error" || d.A.Code != 404 || d.B.Action != "upload" {
+		t.Errorf("expected the missing object to be not found or uploaded, got %+v %+v", d.A, d.B)
+	}
+
+	// Deciding changes nothing
+	if _, err := testMetaStore.UnsafeGet(&RequestVars{Oid: missing}); err == nil {
+		t.Errorf("expected the diff to not register objects to upload")
+	}
+}
+
+func TestMgmtBatchDiffInvalid(t *testing.T) {
+	res, err := api("POST", "/mgmt/api/batch/diff", "", testAdminUser, testAdminPass, bytes.NewBufferString(`{"a":{}}`))
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 400 {
+		t.Errorf("expected status 400 without both requests, got %d", res.StatusCode)
+	}
+}
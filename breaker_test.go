@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	now := time.Now()
+	b := newCircuitBreaker(3, time.Minute)
+	b.now = func() time.Time { return now }
+
+	store := &failingStore{err: errors.New("timeout")}
+	call := func() error {
+		if !b.Allow() {
+			return errCircuitOpen
+		}
+		err := store.call()
+		b.Record(err == nil)
+		return err
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := call(); err == errCircuitOpen {
+			t.Fatalf("expected breaker to stay closed for failure %d", i+1)
+		}
+	}
+	if !b.IsOpen() {
+		t.Fatalf("expected breaker to open after 3 consecutive failures")
+	}
+
+	calls := store.calls
+	if err := call(); err != errCircuitOpen {
+		t.Fatalf("expected open breaker to fast-fail, got: %v", err)
+	}
+	if store.calls != calls {
+		t.Fatalf("expected open breaker to not call the store")
+	}
+
+	// Half-open after the cooldown: a failed probe reopens the breaker
+	now = now.Add(time.Minute)
+	if err := call(); err == errCircuitOpen {
+		t.Fatalf("expected a probe to be let through after the cooldown")
+	}
+	if !b.IsOpen() {
+		t.Fatalf("expected a failed probe to reopen the breaker")
+	}
+
+	// A successful probe closes it again
+	now = now.Add(time.Minute)
+	store.err = nil
+	if !b.Allow() {
+		t.Fatalf("expected a probe to be let through after the cooldown")
+	}
+	if b.Allow() {
+		t.Fatalf("expected only one probe while half-open")
+	}
+	b.Record(true)
+
+	if b.IsOpen() {
+		t.Fatalf("expected a successful probe to close the breaker")
+	}
+	if err := call(); err != nil {
+		t.Fatalf("expected closed breaker to call the store, got: %v", err)
+	}
+}
+
+func TestMetaStoreCircuitBreaker(t *testing.T) {
+	setupMeta()
+	defer teardownMeta()
+
+	metaStoreTest.EnableCircuitBreaker(2, time.Hour)
+
+	// Missing objects are results, not failures
+	for i := 0; i < 3; i++ {
+		if _, err := metaStoreTest.Get(&RequestVars{Oid: nonExistingOid}); err != errObjectNotFound {
+			t.Fatalf("expected errObjectNotFound, got: %v", err)
+		}
+	}
+	if !metaStoreTest.IsAvailable() {
+		t.Fatalf("expected missing objects to not trip the breaker")
+	}
+
+	metaStoreTest.db.Close()
+
+	for i := 0; i < 2; i++ {
+		if _, err := metaStoreTest.Get(&RequestVars{Oid: contentOid}); err == nil || err == errCircuitOpen {
+			t.Fatalf("expected a store failure, got: %v", err)
+		}
+	}
+	if _, err := metaStoreTest.Get(&RequestVars{Oid: contentOid}); err != errCircuitOpen {
+		t.Fatalf("expected errCircuitOpen, got: %v", err)
+	}
+	if metaStoreTest.IsAvailable() {
+		t.Fatalf("expected store to be unavailable")
+	}
+}
+
+type failingStore struct {
+	err   error
+	calls int
+}
+
+func (s *failingStore) call() error {
+	s.calls++
+	return s.err
+}
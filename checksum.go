@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+	"strings"
+)
+
+var (
+	errChecksumMismatch = errors.New("Content checksum does not match")
+	errInvalidChecksum  = errors.New("Invalid checksum header")
+)
+
+// checksumAlgorithms lists the algorithms clients may declare an upload
+// checksum with.
+var checksumAlgorithms = map[string]func() hash.Hash{
+	"sha256": sha256.New,
+	"sha1":   sha1.New,
+	"md5":    md5.New,
+	"crc32":  func() hash.Hash { return crc32.NewIEEE() },
+}
+
+// checksumReader hashes content as it is read and fails the final read with
+// errChecksumMismatch if the digest is not the expected one, so the upload is
+// never promoted.
+type checksumReader struct {
+	r        io.Reader
+	hash     hash.Hash
+	expected []byte
+}
+
+// newChecksumReader wraps r to verify it against a checksum declared as
+// "<algorithm>=<hex digest>", e.g. "crc32=0d4a1185".
+func newChecksumReader(r io.Reader, checksum string) (io.Reader, error) {
+	parts := strings.SplitN(checksum, "=", 2)
+	if len(parts) != 2 {
+		return nil, errInvalidChecksum
+	}
+
+	newHash, ok := checksumAlgorithms[strings.ToLower(strings.TrimSpace(parts[0]))]
+	if !ok {
+		return nil, errInvalidChecksum
+	}
+
+	expected, err := hex.DecodeString(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return nil, errInvalidChecksum
+	}
+
+	h := newHash()
+	if len(expected) != h.Size() {
+		return nil, errInvalidChecksum
+	}
+	return &checksumReader{r: r, hash: h, expected: expected}, nil
+}
+
+func (c *checksumReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.hash.Write(p[:n])
+
+	if err == io.EOF && !bytes.Equal(c.hash.Sum(nil), c.expected) {
+		return n, errChecksumMismatch
+	}
+	return n, err
+}
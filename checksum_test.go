@@ -0,0 +1,36 @@
+package main
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestChecksumReader(t *testing.T) {
+	data := "checksummed content"
+
+	for _, tc := range []struct {
+		checksum string
+		err      error
+	}{
+		{"crc32=59b6af81", nil},
+		{"CRC32=59B6AF81", nil},
+		{"sha1=6077bc26f921a7e63ac1778c9961f25a6f440f94", nil},
+		{"crc32=00000000", errChecksumMismatch},
+		{"sha1=0000bc26f921a7e63ac1778c9961f25a6f440f94", errChecksumMismatch},
+	} {
+		r, err := newChecksumReader(strings.NewReader(data), tc.checksum)
+		if err != nil {
+			t.Fatalf("expected %q to be accepted, got: %s", tc.checksum, err)
+		}
+		if _, err := ioutil.ReadAll(r); err != tc.err {
+			t.Errorf("expected %v reading with %q, got: %v", tc.err, tc.checksum, err)
+		}
+	}
+
+	for _, checksum := range []string{"59b6af81", "adler32=59b6af81", "crc32=xyz", "crc32=59b6af", "sha1=59b6af81"} {
+		if _, err := newChecksumReader(strings.NewReader(data), checksum); err != errInvalidChecksum {
+			t.Errorf("expected %q to be rejected, got: %v", checksum, err)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// CleanupCandidates lists the objects a retention job may delete, and the
+// bytes deleting them all would free.
+type CleanupCandidates struct {
+	Objects []*MetaObject `json:"objects"`
+	Size    int64         `json:"size"`
+}
+
+// CleanupCandidates returns the objects created before the time that are not
+// pinned, not referenced by any repository and not locked. As unreferenced
+// objects have no repository, locks in every repository are checked for a
+// path with the object's file name. That is a heuristic, as any lock on a file
+// of that name counts and one on a renamed file does not. Objects stored
+// before creation times were recorded never match, as their age is unknown.
+func (s *MetaStore) CleanupCandidates(before time.Time) ([]*MetaObject, error) {
+	if err := s.Flush(); err != nil {
+		return nil, err
+	}
+
+	var objects []*MetaObject
+	now := time.Now()
+	err := s.view(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(objectsBucket)
+		locks := tx.Bucket(locksBucket)
+		if bucket == nil || locks == nil {
+			return errNoBucket
+		}
+
+		locked := make(map[string]bool)
+		err := locks.ForEach(func(k, v []byte) error {
+			var repoLocks []Lock
+			if err := json.Unmarshal(v, &repoLocks); err != nil {
+				return err
+			}
+			for _, l := range liveLocks(repoLocks, now) {
+				locked[path.Base(l.Path)] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		return bucket.ForEach(func(k, v []byte) error {
+			var meta MetaObject
+			if err := gob.NewDecoder(bytes.NewBuffer(v)).Decode(&meta); err != nil {
+				return err
+			}
+			if meta.Pinned || meta.RefCount() > 0 || meta.CreatedAt == nil || !meta.CreatedAt.Before(before) {
+				return nil
+			}
+			if meta.Name != "" && locked[meta.Name] {
+				return nil
+			}
+			objects = append(objects, &meta)
+			return nil
+		})
+	})
+
+	return objects, err
+}
+
+// cleanupCandidatesHandler lists the cleanup candidates older than the days
+// in the form. Nothing is deleted.
+func (a *App) cleanupCandidatesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	days, err := strconv.Atoi(r.FormValue("days"))
+	if err != nil || days < 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"message":"days must be a number of days"}`)
+		return
+	}
+
+	objects, err := a.metaStore.CleanupCandidates(time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		writeStatus(w, r, metaErrorStatus(err, 500), false)
+		return
+	}
+
+	result := CleanupCandidates{Objects: []*MetaObject{}}
+	for _, meta := range objects {
+		result.Objects = append(result.Objects, meta)
+		result.Size += meta.Size
+	}
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCleanupCandidates(t *testing.T) {
+	setupMeta()
+	defer teardownMeta()
+
+	old := time.Now().AddDate(0, 0, -60)
+	young := time.Now().AddDate(0, 0, -1)
+	oids := make(map[string]string)
+	for _, tc := range []struct {
+		name string
+		rv   RequestVars
+		fn   func(*MetaObject)
+	}{
+		{"old", RequestVars{}, func(m *MetaObject) { m.CreatedAt = &old }},
+		{"old named", RequestVars{}, func(m *MetaObject) { m.CreatedAt, m.Name = &old, "notes.txt" }},
+		{"pinned", RequestVars{}, func(m *MetaObject) { m.CreatedAt, m.Pinned = &old, true }},
+		{"locked", RequestVars{}, func(m *MetaObject) { m.CreatedAt, m.Name = &old, "cover.psd" }},
+		{"referenced", RequestVars{User: "user", Repo: "repo"}, func(m *MetaObject) { m.CreatedAt = &old }},
+		{"young", RequestVars{}, func(m *MetaObject) { m.CreatedAt = &young }},
+		{"unknown age", RequestVars{}, func(m *MetaObject) { m.CreatedAt = nil }},
+	} {
+		rv := tc.rv
+		rv.Oid = fmt.Sprintf("%x", sha256.Sum256([]byte(tc.name)))
+		rv.Size = int64(len(tc.name))
+		if _, err := metaStoreTest.Put(&rv); err != nil {
+			t.Fatalf("error putting object: %s", err)
+		}
+		if _, err := metaStoreTest.updateObject(rv.Oid, tc.fn); err != nil {
+			t.Fatalf("error updating object: %s", err)
+		}
+		oids[rv.Oid] = tc.name
+	}
+	if err := metaStoreTest.AddLocks("art", NewTestLock("cover-lock", "assets/cover.psd", testUser)); err != nil {
+		t.Fatalf("error adding lock: %s", err)
+	}
+
+	objects, err := metaStoreTest.CleanupCandidates(time.Now().AddDate(0, 0, -30))
+	if err != nil {
+		t.Fatalf("expected cleanup candidates, got: %s", err)
+	}
+	found := make(map[string]bool)
+	for _, meta := range objects {
+		found[oids[meta.Oid]] = true
+	}
+	if len(objects) != 2 || !found["old"] || !found["old named"] {
+		t.Errorf("expected only the old unpinned, unlocked and unreferenced objects, got %v", found)
+	}
+}
+
+func TestMgmtCleanupCandidates(t *testing.T) {
+	oid, size := seedObject(t, "TestMgmtCleanupCandidates content")
+	old := time.Now().AddDate(0, 0, -400)
+	if _, err := testMetaStore.updateObject(oid, func(m *MetaObject) { m.CreatedAt = &old }); err != nil {
+		t.Fatalf("error updating object: %s", err)
+	}
+
+	res, err := api("GET", "/mgmt/api/cleanup-candidates?days=365", "", testAdminUser, testAdminPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+	var candidates CleanupCandidates
+	if err := json.NewDecoder(res.Body).Decode(&candidates); err != nil {
+		t.Fatalf("expected response body to be cleanup candidates, got: %s", err)
+	}
+	if len(candidates.Objects) != 1 || candidates.Objects[0].Oid != oid || candidates.Size != size {
+		t.Errorf("expected only the aged object to be a candidate, got %+v", candidates)
+	}
+	if _, err := testMetaStore.UnsafeGet(&RequestVars{Oid: oid}); err != nil {
+		t.Errorf("expected the candidate to not be deleted, got: %s", err)
+	}
+
+	res, err = api("GET", "/mgmt/api/cleanup-candidates", "", testAdminUser, testAdminPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 400 {
+		t.Errorf("expected status 400 without days, got %d", res.StatusCode)
+	}
+}
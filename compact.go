@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// CompactResult reports the size in bytes of the meta store file before and
+// after it was compacted.
+type CompactResult struct {
+	Before int64 `json:"before"`
+	After  int64 `json:"after"`
+}
+
+// runCompact compacts the configured meta store while the server is not
+// running. It returns the process exit code.
+func runCompact() int {
+	metaStore, err := NewMetaStore(Config.MetaDB)
+	if err != nil {
+		fmt.Printf("Could not open the meta store: %s\n", err)
+		return 1
+	}
+	defer metaStore.Close()
+
+	result, err := metaStore.Compact()
+	if err != nil {
+		fmt.Printf("Could not compact the meta store: %s\n", err)
+		return 1
+	}
+	fmt.Printf("before: %d\nafter: %d\n", result.Before, result.After)
+	return 0
+}
+
+// openBolt opens a meta store file for Compact. It is a variable so that
+// tests can make the compacted file fail to open.
+var openBolt = func(path string) (*bolt.DB, error) {
+	return bolt.Open(path, 0600, &bolt.Options{Timeout: 1 * time.Second})
+}
+
+// Compact copies the live data of the store into a fresh file and swaps it in
+// for the current one, reclaiming the pages freed by deletes. Transactions
+// wait until it is done, so nothing written meanwhile is lost. If the copy
+// fails, or the new file cannot be swapped in or opened, the current file is
+// kept and opened again.
+func (s *MetaStore) Compact() (*CompactResult, error) {
+	if err := s.Flush(); err != nil {
+		return nil, err
+	}
+
+	s.compactMu.Lock()
+	defer s.compactMu.Unlock()
+
+	path := s.db.Path()
+	before, err := fileSize(path)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpPath := path + ".compact"
+	os.Remove(tmpPath)
+	if err := copyBolt(s.db, tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return nil, err
+	}
+
+	if err := s.db.Close(); err != nil {
+		os.Remove(tmpPath)
+		return nil, err
+	}
+
+	// The current file is moved aside rather than replaced, so that it can
+	// be put back if the compacted one cannot be used.
+	oldPath := path + ".precompact"
+	if err := os.Rename(path, oldPath); err != nil {
+		os.Remove(tmpPath)
+		return nil, s.reopen(path, err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		os.Rename(oldPath, path)
+		return nil, s.reopen(path, err)
+	}
+	db, err := openBolt(path)
+	if err != nil {
+		os.Rename(oldPath, path)
+		return nil, s.reopen(path, err)
+	}
+	s.db = db
+	os.Remove(oldPath)
+
+	after, err := fileSize(path)
+	if err != nil {
+		return nil, err
+	}
+	return &CompactResult{Before: before, After: after}, nil
+}
+
+// reopen opens the file at path as the store's db again after a compaction
+// failed with cause, and returns cause. If the file cannot be opened either,
+// the store is left closed and that error is returned with it.
+func (s *MetaStore) reopen(path string, cause error) error {
+	db, err := openBolt(path)
+	if err != nil {
+		return fmt.Errorf("%s, and the meta store could not be opened again: %s", cause, err)
+	}
+	s.db = db
+	return cause
+}
+
+// copyBolt writes every bucket of src, including nested buckets, to a new
+// database at path.
+func copyBolt(src *bolt.DB, path string) error {
+	dst, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return err
+	}
+
+	err = src.View(func(tx *bolt.Tx) error {
+		return dst.Update(func(dtx *bolt.Tx) error {
+			return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+				nb, err := dtx.CreateBucket(name)
+				if err != nil {
+					return err
+				}
+				return copyBucket(b, nb)
+			})
+		})
+	})
+	if err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+func copyBucket(src, dst *bolt.Bucket) error {
+	return src.ForEach(func(k, v []byte) error {
+		if v != nil {
+			return dst.Put(k, v)
+		}
+
+		nb, err := dst.CreateBucket(k)
+		if err != nil {
+			return err
+		}
+		return copyBucket(src.Bucket(k), nb)
+	})
+}
+
+func fileSize(path string) (int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// compactHandler compacts the meta store and reports its file size before
+// and after.
+func (a *App) compactHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	result, err := a.metaStore.Compact()
+	if err != nil {
+		w.WriteHeader(metaErrorStatus(err, http.StatusInternalServerError))
+		fmt.Fprintf(w, `{"message":"%s"}`, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/boltdb/bolt"
+)
+
+func TestCompact(t *testing.T) {
+	store, err := NewMetaStore("compact-test.db")
+	if err != nil {
+		t.Fatalf("error creating meta store: %s", err)
+	}
+	defer os.Remove("compact-test.db")
+	defer store.Close()
+
+	if err := store.AddUser(testUser, testPass); err != nil {
+		t.Fatalf("error adding user: %s", err)
+	}
+	if err := store.AddLocks(testRepo, NewTestLock(lockId, lockPath, testUser)); err != nil {
+		t.Fatalf("error adding lock: %s", err)
+	}
+
+	// Most objects are deleted again, leaving their pages free
+	var kept []string
+	for i := 0; i < 2000; i++ {
+		rv := &RequestVars{Oid: fmt.Sprintf("%064d", i), Size: int64(i), User: testUser, Repo: testRepo}
+		if _, err := store.Put(rv); err != nil {
+			t.Fatalf("error putting object: %s", err)
+		}
+		if i%100 == 0 {
+			kept = append(kept, rv.Oid)
+		}
+	}
+	for i := 0; i < 2000; i++ {
+		if i%100 != 0 {
+			if err := store.Delete(&RequestVars{Oid: fmt.Sprintf("%064d", i)}); err != nil {
+				t.Fatalf("error deleting object: %s", err)
+			}
+		}
+	}
+
+	result, err := store.Compact()
+	if err != nil {
+		t.Fatalf("expected compaction to succeed, got: %s", err)
+	}
+	if result.After >= result.Before {
+		t.Errorf("expected the store to shrink, got %d bytes from %d", result.After, result.Before)
+	}
+	if size, _ := fileSize("compact-test.db"); size != result.After {
+		t.Errorf("expected the reported size %d to be the file's, got %d", result.After, size)
+	}
+
+	objects, err := store.Objects()
+	if err != nil {
+		t.Fatalf("expected objects to be listed, got: %s", err)
+	}
+	if len(objects) != len(kept) {
+		t.Errorf("expected %d objects to be kept, got %d", len(kept), len(objects))
+	}
+	for _, oid := range kept {
+		if _, err := store.UnsafeGet(&RequestVars{Oid: oid}); err != nil {
+			t.Errorf("expected object %s to be kept, got: %s", oid, err)
+		}
+	}
+	if _, ok := store.Authenticate(testUser, testPass); !ok {
+		t.Errorf("expected the user to be kept")
+	}
+	if locks, err := store.Locks(testRepo); err != nil || len(locks) != 1 || locks[0].Id != lockId {
+		t.Errorf("expected the lock to be kept, got %v %v", locks, err)
+	}
+
+	// The store keeps working on the new file
+	if _, err := store.Put(&RequestVars{Oid: fmt.Sprintf("%064d", 5000), Size: 1}); err != nil {
+		t.Errorf("expected writes after compaction to succeed, got: %s", err)
+	}
+	if _, err := os.Stat("compact-test.db.compact"); !os.IsNotExist(err) {
+		t.Errorf("expected no compaction file to be left behind")
+	}
+}
+
+func TestCompactReopenFails(t *testing.T) {
+	store, err := NewMetaStore("compact-test.db")
+	if err != nil {
+		t.Fatalf("error creating meta store: %s", err)
+	}
+	defer os.Remove("compact-test.db")
+	defer store.Close()
+
+	if err := store.AddUser(testUser, testPass); err != nil {
+		t.Fatalf("error adding user: %s", err)
+	}
+
+	// The compacted file cannot be opened, the original can
+	defer func(open func(string) (*bolt.DB, error)) { openBolt = open }(openBolt)
+	open := openBolt
+	calls := 0
+	openBolt = func(path string) (*bolt.DB, error) {
+		if calls++; calls == 1 {
+			return nil, errors.New("open failed")
+		}
+		return open(path)
+	}
+
+	if _, err := store.Compact(); err == nil {
+		t.Fatalf("expected compaction to fail")
+	}
+	if _, ok := store.Authenticate(testUser, testPass); !ok {
+		t.Errorf("expected the store to keep working on the original file")
+	}
+	for _, leftover := range []string{"compact-test.db.compact", "compact-test.db.precompact"} {
+		if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+			t.Errorf("expected %s to not be left behind", leftover)
+		}
+	}
+}
+
+func TestMgmtCompact(t *testing.T) {
+	res, err := api("POST", "/mgmt/api/compact", "", testAdminUser, testAdminPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+
+	var result CompactResult
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		t.Fatalf("expected response body to be a compaction result, got: %s", err)
+	}
+	if result.Before == 0 || result.After == 0 {
+		t.Errorf("expected the file sizes to be reported, got %+v", result)
+	}
+
+	res, err = api("GET", "/user/repo/objects/"+contentOid, contentMediaType, testUser, testPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 200 {
+		t.Errorf("expected objects to download after compaction, got %d", res.StatusCode)
+	}
+}
@@ -1,50 +1,652 @@
 package main
 
 import (
+	"crypto/tls"
 	"fmt"
+	"net"
 	"os"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // Configuration holds application configuration. Values will be pulled from
 // environment variables, prefixed by keyPrefix. Default values can be added
 // via tags.
 type Configuration struct {
-	Listen      string `config:"tcp://:8080"`
-	Host        string `config:"localhost:8080"`
-	MetaDB      string `config:"lfs.db"`
-	ContentPath string `config:"lfs-content"`
-	AdminUser   string `config:""`
-	AdminPass   string `config:""`
-	Cert        string `config:""`
-	Key         string `config:""`
-	Scheme      string `config:"http"`
-	Public      string `config:"public"`
-	UseTus      string `config:"false"`
-	TusHost     string `config:"localhost:1080"`
+	Listen          string `config:"tcp://:8080"`
+	MgmtListen      string `config:""`
+	Host            string `config:"localhost:8080"`
+	MetaDB          string `config:"lfs.db"`
+	ContentPath     string `config:"lfs-content"`
+	AdminUser       string `config:""`
+	AdminPass       string `config:""`
+	Cert            string `config:""`
+	Key             string `config:""`
+	Scheme          string `config:"http"`
+	TLSMinVersion   string `config:"1.2"`
+	TLSCiphers      string `config:""`
+	HSTSMaxAge      string `config:"31536000"`
+	Public          string `config:"public"`
+	UseTus          string `config:"false"`
+	VerifyURL       string `config:""`
+	TusHost         string `config:"localhost:1080"`
+	WriteBuffer     string `config:"0"`
+	WriteFlush      string `config:"1s"`
+	AccessFlush     string `config:"1m"`
+	PreloadHints    string `config:"false"`
+	BatchStream     string `config:"0"`
+	SizeBuckets     string `config:"1048576,10485760,104857600"`
+	MaxReads        string `config:"0"`
+	MaxWrites       string `config:"0"`
+	MaxUpload       string `config:"0"`
+	TransferWait    string `config:"30s"`
+	MetaBreaker     string `config:"0"`
+	MetaCooldown    string `config:"30s"`
+	Standalone      string `config:"false"`
+	TrustedNet      string `config:""`
+	AllowOverwrite  string `config:"false"`
+	MismatchPolicy  string `config:"delete"`
+	MismatchPath    string `config:"lfs-mismatch"`
+	MismatchMax     string `config:"10"`
+	ReadOnly        string `config:""`
+	ProtectedRefs   string `config:""`
+	RefWriters      string `config:""`
+	Quarantine      string `config:"false"`
+	ContentMD5      string `config:"false"`
+	LabelHeaders    string `config:""`
+	LockPaths       string `config:""`
+	LockTTL         string `config:"0"`
+	LockRef         string `config:""`
+	MaxLocksPerUser string `config:"0"`
+	CleanLockPaths  string `config:"true"`
+	RelockExisting  string `config:"true"`
+	LockBackoff     string `config:"0"`
+	WithholdSize    string `config:"0"`
+	WithholdAge     string `config:"0"`
+	GracePeriod     string `config:"0"`
+	VerifySample    string `config:"0"`
+	VerifyWorkers   string `config:"4"`
+	RegisterVerify  string `config:"hash"`
+	IdempotencyTTL  string `config:"10m"`
+	StrictUpload    string `config:"false"`
+	UploadDiag      string `config:"false"`
+	BasePath        string `config:""`
+	UploadMaxIdle   string `config:"0"`
+	AuthURL         string `config:""`
+	AuthCache       string `config:"30s"`
+	TrustAuthHeader string `config:""`
+	TrustedProxy    string `config:""`
+	ShareSecret     string `config:""`
+	SignDownloads   string `config:"false"`
+	TraceContext    string `config:"false"`
+	LogSampleRate   string `config:"1"`
+	SlowRequest     string `config:"0"`
+	FaultInjection  string `config:"false"`
+	Upstream        string `config:""`
+	UpstreamUser    string `config:""`
+	UpstreamPass    string `config:""`
+	UpstreamTimeout string `config:"10m"`
+	CachePath       string `config:""`
+	CacheSize       string `config:"0"`
 }
 
 func (c *Configuration) IsHTTPS() bool {
 	return strings.Contains(Config.Scheme, "https")
 }
 
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// tlsCipherSuites are the cipher suites that may be configured for TLS 1.2 and
+// below, by name. All of them use forward secrecy and authenticated
+// encryption.
+var tlsCipherSuites = map[string]uint16{
+	"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256":       tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256":         tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384":       tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384":         tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256": tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	"TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256":   tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+// TLSMinimumVersion returns the oldest TLS version clients may connect with.
+// It defaults to TLS 1.2.
+func (c *Configuration) TLSMinimumVersion() uint16 {
+	if v, ok := tlsVersions[strings.TrimSpace(c.TLSMinVersion)]; ok {
+		return v
+	}
+	return tls.VersionTLS12
+}
+
+// TLSCipherSuiteIDs returns the cipher suites listed in TLSCiphers, or every
+// suite in tlsCipherSuites if none are listed. Unknown names are ignored.
+func (c *Configuration) TLSCipherSuiteIDs() []uint16 {
+	var names []string
+	for _, name := range strings.Split(c.TLSCiphers, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		for name := range tlsCipherSuites {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	var suites []uint16
+	for _, name := range names {
+		if id, ok := tlsCipherSuites[name]; ok {
+			suites = append(suites, id)
+		}
+	}
+	return suites
+}
+
+// HSTSHeader returns the Strict-Transport-Security header value sent with
+// responses over TLS, or an empty string if HSTSMaxAge disables it.
+func (c *Configuration) HSTSHeader() string {
+	age := atoiOrZero(c.HSTSMaxAge)
+	if age <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("max-age=%d", age)
+}
+
 func (c *Configuration) IsPublic() bool {
-	switch Config.Public {
-	case "1", "true", "TRUE":
-		return true
+	return isTrue(Config.Public)
+}
+
+func (c *Configuration) IsUsingTus() bool {
+	return isTrue(Config.UseTus)
+}
+
+// IsTraceContext returns true if requests should continue the W3C trace
+// context of their traceparent header, or start a new trace.
+func (c *Configuration) IsTraceContext() bool {
+	return isTrue(c.TraceContext)
+}
+
+// IsFaultInjection returns true if the fault flags may fail and delay API
+// requests. It is for testing clients, never for servers in use.
+func (c *Configuration) IsFaultInjection() bool {
+	return isTrue(c.FaultInjection)
+}
+
+// IsSignDownloads returns true if batch download links carry the object's size
+// and a signature of it, for the download to be checked against.
+func (c *Configuration) IsSignDownloads() bool {
+	return isTrue(c.SignDownloads)
+}
+
+// IsPreloadHints returns true if batch responses should carry Link preload
+// headers for their download actions.
+func (c *Configuration) IsPreloadHints() bool {
+	return isTrue(c.PreloadHints)
+}
+
+// IsStandaloneTransfer returns true if trusted clients may download content
+// straight from the content store with the lfs-standalone-file adapter.
+func (c *Configuration) IsStandaloneTransfer() bool {
+	return isTrue(c.Standalone)
+}
+
+// BatchStreamSize returns the number of objects from which batch responses
+// are streamed, or zero if they never are.
+func (c *Configuration) BatchStreamSize() int {
+	return atoiOrZero(c.BatchStream)
+}
+
+// IsAllowOverwrite returns true if uploads may replace stored content that
+// differs from the upload. It is meant for recovering corrupted objects.
+func (c *Configuration) IsAllowOverwrite() bool {
+	return isTrue(c.AllowOverwrite)
+}
+
+const (
+	mismatchDelete     = "delete"
+	mismatchQuarantine = "quarantine"
+	mismatchKeep       = "keep"
+)
+
+// MismatchAction returns what is done with uploads that fail verification:
+// mismatchDelete, mismatchQuarantine or mismatchKeep. Unknown policies delete
+// them.
+func (c *Configuration) MismatchAction() string {
+	switch p := strings.ToLower(strings.TrimSpace(c.MismatchPolicy)); p {
+	case mismatchQuarantine, mismatchKeep:
+		return p
+	}
+	return mismatchDelete
+}
+
+// MismatchAttempts returns how many quarantined attempts are kept for each
+// object, at least 1.
+func (c *Configuration) MismatchAttempts() int {
+	if n := atoiOrZero(c.MismatchMax); n > 0 {
+		return n
+	}
+	return 1
+}
+
+// IsStrictContentType returns true if raw uploads sending a Content-Type
+// other than application/octet-stream are refused. A different type usually
+// means a proxy rewrote the body, or it was sent form encoded by mistake.
+func (c *Configuration) IsStrictContentType() bool {
+	return isTrue(c.StrictUpload)
+}
+
+// IsUploadDiagnostics returns true if failed uploads log the size and hash of
+// what was received.
+func (c *Configuration) IsUploadDiagnostics() bool {
+	return isTrue(c.UploadDiag)
+}
+
+// IsQuarantine returns true if uploaded objects are quarantined until an
+// external scanner approves them.
+func (c *Configuration) IsQuarantine() bool {
+	return isTrue(c.Quarantine)
+}
+
+// IsContentMD5 returns true if downloads carry a Content-MD5 header for
+// legacy clients.
+func (c *Configuration) IsContentMD5() bool {
+	return isTrue(c.ContentMD5)
+}
+
+// LabelHeader is a response header sent with downloads of objects carrying a
+// label.
+type LabelHeader struct {
+	Label string
+	Name  string
+	Value string
+}
+
+// LabelHeaderList returns the headers listed in LabelHeaders, as semicolon
+// separated label:Name=value entries, e.g.
+// "public:Cache-Control=public, max-age=86400". Invalid entries are ignored.
+func (c *Configuration) LabelHeaderList() []LabelHeader {
+	var headers []LabelHeader
+	for _, entry := range strings.Split(c.LabelHeaders, ";") {
+		colon := strings.Index(entry, ":")
+		if colon < 0 {
+			continue
+		}
+		eq := strings.Index(entry[colon+1:], "=")
+		if eq < 0 {
+			continue
+		}
+		h := LabelHeader{
+			Label: strings.TrimSpace(entry[:colon]),
+			Name:  strings.TrimSpace(entry[colon+1 : colon+1+eq]),
+			Value: strings.TrimSpace(entry[colon+2+eq:]),
+		}
+		if h.Label != "" && h.Name != "" {
+			headers = append(headers, h)
+		}
+	}
+	return headers
+}
+
+// LockPathPatterns returns the glob patterns listed in LockPaths.
+func (c *Configuration) LockPathPatterns() []string {
+	var patterns []string
+	for _, p := range strings.Split(c.LockPaths, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// IsReadOnlyUser returns true if user is listed in ReadOnly and may only
+// download.
+func (c *Configuration) IsReadOnlyUser(user string) bool {
+	for _, u := range strings.Split(c.ReadOnly, ",") {
+		if u = strings.TrimSpace(u); u != "" && normalizeUser(u) == normalizeUser(user) {
+			return true
+		}
 	}
 	return false
 }
 
-func (c *Configuration) IsUsingTus() bool {
-	switch Config.UseTus {
+// ProtectedRefPatterns returns the glob patterns listed in ProtectedRefs.
+func (c *Configuration) ProtectedRefPatterns() []string {
+	var patterns []string
+	for _, p := range strings.Split(c.ProtectedRefs, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// IsRefWriter returns true if user is listed in RefWriters and may push to
+// protected refs.
+func (c *Configuration) IsRefWriter(user string) bool {
+	for _, u := range strings.Split(c.RefWriters, ",") {
+		if u = strings.TrimSpace(u); u != "" && normalizeUser(u) == normalizeUser(user) {
+			return true
+		}
+	}
+	return false
+}
+
+// TrustedNets returns the networks listed in TrustedNet. Invalid entries are
+// ignored.
+func (c *Configuration) TrustedNets() []*net.IPNet {
+	return parseNets(c.TrustedNet)
+}
+
+// TrustedProxies returns the networks listed in TrustedProxy. Invalid entries
+// are ignored.
+func (c *Configuration) TrustedProxies() []*net.IPNet {
+	return parseNets(c.TrustedProxy)
+}
+
+func parseNets(list string) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, cidr := range strings.Split(list, ",") {
+		_, n, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			continue
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+func isTrue(v string) bool {
+	switch v {
 	case "1", "true", "TRUE":
 		return true
 	}
 	return false
 }
 
+// WriteBufferSize returns how many object writes may be buffered before they
+// are flushed to the meta store. Zero disables write buffering.
+func (c *Configuration) WriteBufferSize() int {
+	return atoiOrZero(c.WriteBuffer)
+}
+
+// WriteFlushInterval returns how often buffered object writes are flushed.
+func (c *Configuration) WriteFlushInterval() time.Duration {
+	d, err := time.ParseDuration(c.WriteFlush)
+	if err != nil || d <= 0 {
+		return time.Second
+	}
+	return d
+}
+
+// AccessFlushInterval returns how often recorded object access times are
+// written to the meta store, or zero if access times are not recorded.
+func (c *Configuration) AccessFlushInterval() time.Duration {
+	d, err := time.ParseDuration(c.AccessFlush)
+	if err != nil || d <= 0 {
+		return 0
+	}
+	return d
+}
+
+// MaxReadTransfers returns how many downloads may read from the content store
+// at once. Zero means unlimited.
+func (c *Configuration) MaxReadTransfers() int {
+	return atoiOrZero(c.MaxReads)
+}
+
+// MaxWriteTransfers returns how many uploads may write to the content store at
+// once. Zero means unlimited.
+func (c *Configuration) MaxWriteTransfers() int {
+	return atoiOrZero(c.MaxWrites)
+}
+
+// MaxUploadSize returns the size in bytes above which uploads are refused, or
+// zero if no size limit applies.
+func (c *Configuration) MaxUploadSize() int64 {
+	n, err := strconv.ParseInt(c.MaxUpload, 10, 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// CacheSizeLimit returns the size in bytes the cache of upstream content is
+// kept under, or zero if it is not bounded.
+func (c *Configuration) CacheSizeLimit() int64 {
+	n, err := strconv.ParseInt(c.CacheSize, 10, 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// IsRegisterSizeOnly returns true if registered content is only checked
+// against its declared size, not hashed.
+func (c *Configuration) IsRegisterSizeOnly() bool {
+	return c.RegisterVerify == "size"
+}
+
+// IsCleanLockPaths returns true if lock paths are normalized before they are
+// stored and compared.
+func (c *Configuration) IsCleanLockPaths() bool {
+	return isTrue(c.CleanLockPaths)
+}
+
+// IsRelockExisting returns true if a user locking a path they already hold
+// gets their existing lock back instead of a conflict.
+func (c *Configuration) IsRelockExisting() bool {
+	return isTrue(c.RelockExisting)
+}
+
+// LockLimit returns how many locks a user may hold at once, or zero if there
+// is no limit.
+func (c *Configuration) LockLimit() int {
+	return atoiOrZero(c.MaxLocksPerUser)
+}
+
+// TransferWaitTimeout returns how long a transfer waits for a free slot before
+// it is rejected.
+func (c *Configuration) TransferWaitTimeout() time.Duration {
+	d, err := time.ParseDuration(c.TransferWait)
+	if err != nil || d < 0 {
+		return 30 * time.Second
+	}
+	return d
+}
+
+func atoiOrZero(v string) int {
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// MetaBreakerThreshold returns the number of consecutive meta store failures
+// that open the circuit breaker. Zero disables the breaker.
+func (c *Configuration) MetaBreakerThreshold() int {
+	return atoiOrZero(c.MetaBreaker)
+}
+
+// MetaBreakerCooldown returns how long the circuit breaker stays open before
+// probing the meta store again.
+func (c *Configuration) MetaBreakerCooldown() time.Duration {
+	d, err := time.ParseDuration(c.MetaCooldown)
+	if err != nil || d <= 0 {
+		return 30 * time.Second
+	}
+	return d
+}
+
+// LockExpiry returns how long locks last before they expire and are swept,
+// or zero if locks never expire.
+func (c *Configuration) LockExpiry() time.Duration {
+	d, err := time.ParseDuration(c.LockTTL)
+	if err != nil || d <= 0 {
+		return 0
+	}
+	return d
+}
+
+// UpstreamFetchTimeout returns how long fetching the content of an object
+// from the upstream server may take.
+func (c *Configuration) UpstreamFetchTimeout() time.Duration {
+	d, err := time.ParseDuration(c.UpstreamTimeout)
+	if err != nil || d <= 0 {
+		return 10 * time.Minute
+	}
+	return d
+}
+
+// LockBackoffBase returns how long clients repeating a conflicting lock
+// request are first told to wait, or zero if they always get a conflict.
+func (c *Configuration) LockBackoffBase() time.Duration {
+	d, err := time.ParseDuration(c.LockBackoff)
+	if err != nil || d <= 0 {
+		return 0
+	}
+	return d
+}
+
+// BasePrefix returns the path prefix all routes are served under, with a
+// leading and no trailing slash, or "" to serve them from the root.
+func (c *Configuration) BasePrefix() string {
+	base := strings.Trim(c.BasePath, "/")
+	if base == "" {
+		return ""
+	}
+	return "/" + base
+}
+
+// WithholdSizeLimit returns the size in bytes above which object content is
+// withheld from downloads, or zero if no size limit applies.
+func (c *Configuration) WithholdSizeLimit() int64 {
+	n, err := strconv.ParseInt(c.WithholdSize, 10, 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// GracePeriodDuration returns how long new objects are held back from
+// downloads, or zero if they can be downloaded as soon as they are uploaded.
+func (c *Configuration) GracePeriodDuration() time.Duration {
+	d, err := time.ParseDuration(c.GracePeriod)
+	if err != nil || d <= 0 {
+		return 0
+	}
+	return d
+}
+
+// WithholdAgeLimit returns the age after which object content is withheld
+// from downloads, or zero if no age limit applies.
+func (c *Configuration) WithholdAgeLimit() time.Duration {
+	d, err := time.ParseDuration(c.WithholdAge)
+	if err != nil || d <= 0 {
+		return 0
+	}
+	return d
+}
+
+// LogSampling returns the fraction of successful requests, between 0 and 1,
+// that are logged. Invalid values log every request, rather than none.
+func (c *Configuration) LogSampling() float64 {
+	if _, err := strconv.ParseFloat(c.LogSampleRate, 64); err != nil {
+		return 1
+	}
+	return parseRate(c.LogSampleRate)
+}
+
+// VerifySampleRate returns the fraction of complete downloads, between 0 and
+// 1, that are verified against their oid while streaming.
+func (c *Configuration) VerifySampleRate() float64 {
+	return parseRate(c.VerifySample)
+}
+
+// VerifyAllWorkers returns how many objects verify-all hashes at once.
+func (c *Configuration) VerifyAllWorkers() int {
+	if n := atoiOrZero(c.VerifyWorkers); n > 0 {
+		return n
+	}
+	return 1
+}
+
+// parseRate parses a fraction, clamping it between 0 and 1. Invalid values
+// are 0.
+func parseRate(v string) float64 {
+	rate, err := strconv.ParseFloat(v, 64)
+	if err != nil || rate < 0 {
+		return 0
+	}
+	if rate > 1 {
+		return 1
+	}
+	return rate
+}
+
+// SlowRequestThreshold returns how long a request may take before it is
+// logged as slow with the time of each phase, or zero if none are.
+func (c *Configuration) SlowRequestThreshold() time.Duration {
+	d, err := time.ParseDuration(c.SlowRequest)
+	if err != nil || d < 0 {
+		return 0
+	}
+	return d
+}
+
+// IdempotencyKeyTTL returns how long the response to a request made with an
+// Idempotency-Key header is replayed for retries.
+func (c *Configuration) IdempotencyKeyTTL() time.Duration {
+	d, err := time.ParseDuration(c.IdempotencyTTL)
+	if err != nil || d <= 0 {
+		return 10 * time.Minute
+	}
+	return d
+}
+
+// AuthCacheDuration returns how long the decisions of the download authorizer
+// are remembered.
+func (c *Configuration) AuthCacheDuration() time.Duration {
+	d, err := time.ParseDuration(c.AuthCache)
+	if err != nil || d < 0 {
+		return 30 * time.Second
+	}
+	return d
+}
+
+// UploadIdleTimeout returns how long an upload may go without new data before
+// its temp file is purged, or zero if idle uploads are kept.
+func (c *Configuration) UploadIdleTimeout() time.Duration {
+	d, err := time.ParseDuration(c.UploadMaxIdle)
+	if err != nil || d <= 0 {
+		return 0
+	}
+	return d
+}
+
+// SizeBucketBounds returns the upper bounds, in bytes, of the buckets used for
+// the object size histogram. Invalid entries are ignored.
+func (c *Configuration) SizeBucketBounds() []int64 {
+	var bounds []int64
+	for _, b := range strings.Split(c.SizeBuckets, ",") {
+		n, err := strconv.ParseInt(strings.TrimSpace(b), 10, 64)
+		if err != nil || n <= 0 {
+			continue
+		}
+		bounds = append(bounds, n)
+	}
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i] < bounds[j] })
+	return bounds
+}
+
 // Config is the global app configuration
 var Config = &Configuration{}
 
@@ -1,20 +1,78 @@
 package main
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
+	"fmt"
+	"hash"
 	"io"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// mismatchSuffix marks the last upload of an object that failed verification,
+// when the mismatch policy keeps it in the store.
+const mismatchSuffix = ".mismatch"
+
 var (
-	errHashMismatch = errors.New("Content hash does not match OID")
-	errSizeMismatch = errors.New("Content size does not match")
-	errFileNotExist = errors.New("Content file does not exist")
+	errHashMismatch   = errors.New("Content hash does not match OID")
+	errSizeMismatch   = errors.New("Content size does not match")
+	errUploadTooLarge = errors.New("Object is larger than the upload size limit")
+	errFileNotExist   = errors.New("Content file does not exist")
+	errUnknownOidHash = errors.New("Unknown OID hash algorithm")
+	errContentExists  = errors.New("Content differs from the stored object")
+	errInvalidKey     = errors.New("Content key is outside the content store")
 )
 
+// oidAlgorithm describes a hash algorithm that object ids are derived from.
+type oidAlgorithm struct {
+	Name string
+	Size int // length of the hex encoded digest
+	New  func() hash.Hash
+}
+
+// oidAlgorithms lists the supported algorithms. Adding an algorithm here is
+// all that is needed for uploads to be verified with it.
+var oidAlgorithms = []*oidAlgorithm{
+	{Name: "sha256", Size: hex.EncodedLen(sha256.Size), New: sha256.New},
+}
+
+// oidAlgorithmFor returns the algorithm implied by the oid. Oids carry no
+// explicit scheme, so the algorithm is picked by the length of the digest.
+func oidAlgorithmFor(oid string) (*oidAlgorithm, error) {
+	if _, err := hex.DecodeString(oid); err != nil {
+		return nil, errUnknownOidHash
+	}
+
+	for _, alg := range oidAlgorithms {
+		if len(oid) == alg.Size {
+			return alg, nil
+		}
+	}
+	return nil, errUnknownOidHash
+}
+
+// newOidHash returns a hash that computes the given oid's digest.
+func newOidHash(oid string) (hash.Hash, error) {
+	alg, err := oidAlgorithmFor(oid)
+	if err != nil {
+		return nil, err
+	}
+	return alg.New(), nil
+}
+
+// oidMatches reports whether the digest computed by h is the oid.
+func oidMatches(h hash.Hash, oid string) bool {
+	return hex.EncodeToString(h.Sum(nil)) == oid
+}
+
 // ContentStore provides a simple file system based storage.
 type ContentStore struct {
 	basePath string
@@ -45,22 +103,29 @@ func (s *ContentStore) Get(meta *MetaObject, fromByte int64) (io.ReadCloser, err
 }
 
 // Put takes a Meta object and an io.Reader and writes the content to the store.
+// Uploading the content of an object that is already stored is a no-op, while
+// content that differs from the stored object is refused with
+// errContentExists unless overwrites are allowed.
 func (s *ContentStore) Put(meta *MetaObject, r io.Reader) error {
 	path := filepath.Join(s.basePath, transformKey(meta.Oid))
-	tmpPath := path + ".tmp"
+	tmpPath := path + tmpSuffix
 
 	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, 0750); err != nil {
 		return err
 	}
 
+	hash, err := newOidHash(meta.Oid)
+	if err != nil {
+		return errHashMismatch
+	}
+
 	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0640)
 	if err != nil {
 		return err
 	}
 	defer os.Remove(tmpPath)
 
-	hash := sha256.New()
 	hw := io.MultiWriter(hash, file)
 
 	written, err := io.Copy(hw, r)
@@ -70,21 +135,239 @@ func (s *ContentStore) Put(meta *MetaObject, r io.Reader) error {
 	}
 	file.Close()
 
+	if stored, err := hashFile(path, meta.Oid); err == nil {
+		if bytes.Equal(stored, hash.Sum(nil)) {
+			return nil
+		}
+		if !Config.IsAllowOverwrite() {
+			return errContentExists
+		}
+	}
+
 	if written != meta.Size {
+		s.keepMismatch(meta.Oid, tmpPath)
 		return errSizeMismatch
 	}
 
-	shaStr := hex.EncodeToString(hash.Sum(nil))
-	if shaStr != meta.Oid {
+	if !oidMatches(hash, meta.Oid) {
+		s.keepMismatch(meta.Oid, tmpPath)
 		return errHashMismatch
 	}
 
 	if err := os.Rename(tmpPath, path); err != nil {
 		return err
 	}
+	os.Remove(path + mismatchSuffix)
 	return nil
 }
 
+// keepMismatch applies the mismatch policy to the temp file of an upload that
+// failed verification. Quarantined uploads are moved out of the store for
+// inspection, one file per attempt, keeping the last MismatchAttempts of each
+// object. Kept uploads stay next to where the
+// object would be stored, replacing the previous attempt, until content that
+// matches is uploaded. Either way the client can upload again.
+func (s *ContentStore) keepMismatch(oid, tmpPath string) {
+	var dst string
+	switch Config.MismatchAction() {
+	case mismatchQuarantine:
+		if err := os.MkdirAll(Config.MismatchPath, 0750); err != nil {
+			logger.Log(kv{"fn": "keepMismatch", "oid": oid, "err": "Could not quarantine the upload: " + err.Error()})
+			return
+		}
+		dst = filepath.Join(Config.MismatchPath, fmt.Sprintf("%s.%d", oid, time.Now().UnixNano()))
+	case mismatchKeep:
+		dst = filepath.Join(s.basePath, transformKey(oid)) + mismatchSuffix
+	default:
+		return
+	}
+
+	err := os.Rename(tmpPath, dst)
+	if err != nil {
+		err = copyFile(tmpPath, dst)
+	}
+	if err != nil {
+		logger.Log(kv{"fn": "keepMismatch", "oid": oid, "err": "Could not keep the upload: " + err.Error()})
+		return
+	}
+	logger.Log(kv{"fn": "keepMismatch", "oid": oid, "path": dst, "msg": "kept upload that failed verification"})
+
+	if Config.MismatchAction() == mismatchQuarantine {
+		pruneMismatches(oid, Config.MismatchAttempts())
+	}
+}
+
+// pruneMismatches removes the oldest quarantined attempts of oid until at most
+// keep are left.
+func pruneMismatches(oid string, keep int) {
+	paths, err := filepath.Glob(filepath.Join(Config.MismatchPath, oid+".*"))
+	if err != nil || len(paths) <= keep {
+		return
+	}
+
+	// Attempts are named after the time they were made
+	attempt := func(path string) int64 {
+		n, _ := strconv.ParseInt(strings.TrimPrefix(filepath.Ext(path), "."), 10, 64)
+		return n
+	}
+	sort.Slice(paths, func(i, j int) bool { return attempt(paths[i]) < attempt(paths[j]) })
+
+	for _, path := range paths[:len(paths)-keep] {
+		if err := os.Remove(path); err != nil {
+			logger.Log(kv{"fn": "pruneMismatches", "oid": oid, "err": "Could not remove a quarantined upload: " + err.Error()})
+		}
+	}
+}
+
+// hashFile returns the digest of the file at path, using the oid's hash
+// algorithm.
+func hashFile(path, oid string) ([]byte, error) {
+	hash, err := newOidHash(oid)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(hash, f); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
+
+// Verify checks that the stored content of oid has the given size and hashes
+// to the oid.
+func (s *ContentStore) Verify(oid string, size int64) error {
+	if err := s.VerifySize(oid, size); err != nil {
+		return err
+	}
+
+	sum, err := hashFile(filepath.Join(s.basePath, transformKey(oid)), oid)
+	if err != nil {
+		return err
+	}
+	if hex.EncodeToString(sum) != oid {
+		return errHashMismatch
+	}
+	return nil
+}
+
+// VerifySize checks that the stored content of oid has the given size,
+// trusting that it hashes to the oid without reading it.
+func (s *ContentStore) VerifySize(oid string, size int64) error {
+	if _, err := newOidHash(oid); err != nil {
+		return err
+	}
+
+	stored, err := s.Size(oid)
+	if err != nil {
+		return err
+	}
+	if stored != size {
+		return errSizeMismatch
+	}
+	return nil
+}
+
+// Objects lists the content files in the store, skipping uploads that are
+// still in progress.
+func (s *ContentStore) Objects() ([]*MetaObject, error) {
+	var objects []*MetaObject
+
+	err := filepath.Walk(s.basePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || strings.HasSuffix(path, tmpSuffix) || strings.HasSuffix(path, mismatchSuffix) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.basePath, path)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, &MetaObject{
+			Oid:  strings.Replace(filepath.ToSlash(rel), "/", "", -1),
+			Size: info.Size(),
+		})
+		return nil
+	})
+
+	return objects, err
+}
+
+// Copy stores the file at srcKey, a path relative to the store, as the content
+// of dstOid without reading it through the server. The file is hard linked,
+// or copied within the store's file system if it cannot be linked. Nothing is
+// stored unless the file hashes to dstOid, and an oid that already has content
+// is left as it is.
+func (s *ContentStore) Copy(srcKey, dstOid string) error {
+	// dstOid names the destination path, so it must be an oid before anything
+	// is created.
+	if _, err := newOidHash(dstOid); err != nil {
+		return err
+	}
+
+	src := filepath.Join(s.basePath, filepath.FromSlash(srcKey))
+	if rel, err := filepath.Rel(s.basePath, src); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return errInvalidKey
+	}
+	if _, err := os.Stat(src); err != nil {
+		if os.IsNotExist(err) {
+			return errFileNotExist
+		}
+		return err
+	}
+
+	path := filepath.Join(s.basePath, transformKey(dstOid))
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return err
+	}
+
+	tmpPath := path + tmpSuffix
+	if err := os.Link(src, tmpPath); err != nil {
+		if err := copyFile(src, tmpPath); err != nil {
+			return err
+		}
+	}
+	defer os.Remove(tmpPath)
+
+	sum, err := hashFile(tmpPath, dstOid)
+	if err != nil {
+		return err
+	}
+	if hex.EncodeToString(sum) != dstOid {
+		return errHashMismatch
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0640)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
 // DeleteFile removes the file from the store.
 func (s *ContentStore) DeleteFile(oid string) error {
 	path := filepath.Join(s.basePath, transformKey(oid))
@@ -102,6 +385,28 @@ func (s *ContentStore) DeleteFile(oid string) error {
 	return nil
 }
 
+// Size returns the size of the object's content in the store.
+func (s *ContentStore) Size(oid string) (int64, error) {
+	info, err := os.Stat(filepath.Join(s.basePath, transformKey(oid)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, errFileNotExist
+		}
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// FileURL returns a file:// URL to the object's content, for clients that
+// share the content store's file system.
+func (s *ContentStore) FileURL(oid string) string {
+	path := filepath.Join(s.basePath, transformKey(oid))
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
 // Exists returns true if the object exists in the content store.
 func (s *ContentStore) Exists(meta *MetaObject) bool {
 	path := filepath.Join(s.basePath, transformKey(meta.Oid))
@@ -5,7 +5,11 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 )
 
 var contentStore *ContentStore
@@ -52,6 +56,134 @@ func TestContentStorePutHashMismatch(t *testing.T) {
 	}
 }
 
+func TestContentStorePutLeavesNoTempFile(t *testing.T) {
+	setup()
+	defer teardown()
+
+	m := &MetaObject{
+		Oid:  "6ae8a75555209fd6c44157c0aed8016e763ff435a19cf186f76863140143ff72",
+		Size: 12,
+	}
+
+	path := "content-store-test/6a/e8/a75555209fd6c44157c0aed8016e763ff435a19cf186f76863140143ff72"
+
+	if err := contentStore.Put(m, bytes.NewBuffer([]byte("bogus conten"))); err != errHashMismatch {
+		t.Fatalf("expected hash mismatch, got: %v", err)
+	}
+	if _, err := os.Stat(path + ".tmp"); err == nil {
+		t.Fatalf("expected temp file to be removed after a hash mismatch")
+	}
+
+	if err := contentStore.Put(m, bytes.NewBuffer([]byte("test content"))); err != nil {
+		t.Fatalf("expected put to succeed, got: %s", err)
+	}
+	if _, err := os.Stat(path + ".tmp"); err == nil {
+		t.Fatalf("expected temp file to be removed after promotion")
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("expected content to be promoted, got: %s", err)
+	}
+}
+
+func TestContentStorePutMismatchPolicy(t *testing.T) {
+	defer func(p, dir string) { Config.MismatchPolicy, Config.MismatchPath = p, dir }(Config.MismatchPolicy, Config.MismatchPath)
+	Config.MismatchPath = "content-store-mismatch-test"
+	defer os.RemoveAll(Config.MismatchPath)
+
+	m := &MetaObject{
+		Oid:  "6ae8a75555209fd6c44157c0aed8016e763ff435a19cf186f76863140143ff72",
+		Size: 12,
+	}
+	path := "content-store-test/6a/e8/a75555209fd6c44157c0aed8016e763ff435a19cf186f76863140143ff72"
+
+	quarantined := func() []os.FileInfo {
+		files, _ := ioutil.ReadDir(Config.MismatchPath)
+		return files
+	}
+
+	for _, policy := range []string{"delete", "quarantine", "keep", "bogus"} {
+		setup()
+		os.RemoveAll(Config.MismatchPath)
+		Config.MismatchPolicy = policy
+
+		for _, bogus := range []string{"bogus conten", "other conten"} {
+			if err := contentStore.Put(m, bytes.NewBufferString(bogus)); err != errHashMismatch {
+				t.Fatalf("%s: expected hash mismatch, got: %v", policy, err)
+			}
+		}
+
+		kept, err := ioutil.ReadFile(path + mismatchSuffix)
+		if policy == "keep" {
+			if string(kept) != "other conten" {
+				t.Errorf("keep: expected the last attempt to be kept, got %q %v", kept, err)
+			}
+		} else if err == nil {
+			t.Errorf("%s: expected no upload to be kept in the store", policy)
+		}
+
+		files := quarantined()
+		if policy == "quarantine" {
+			if len(files) != 2 {
+				t.Errorf("quarantine: expected every attempt to be quarantined, got %d", len(files))
+			}
+			for _, f := range files {
+				if !strings.HasPrefix(f.Name(), m.Oid+".") {
+					t.Errorf("quarantine: expected files named after the oid, got %s", f.Name())
+				}
+			}
+		} else if len(files) != 0 {
+			t.Errorf("%s: expected nothing to be quarantined, got %d", policy, len(files))
+		}
+
+		if objects, _ := contentStore.Objects(); len(objects) != 0 {
+			t.Errorf("%s: expected kept uploads to not be listed as objects, got %v", policy, objects)
+		}
+
+		// A retry with the right content is stored, and the kept attempt dropped
+		if err := contentStore.Put(m, bytes.NewBufferString("test content")); err != nil {
+			t.Fatalf("%s: expected a retry to succeed, got: %s", policy, err)
+		}
+		if _, err := os.Stat(path + mismatchSuffix); err == nil {
+			t.Errorf("%s: expected the kept attempt to be removed once the object is stored", policy)
+		}
+		teardown()
+	}
+}
+
+func TestContentStorePutMismatchAttempts(t *testing.T) {
+	defer func(p, dir, max string) {
+		Config.MismatchPolicy, Config.MismatchPath, Config.MismatchMax = p, dir, max
+	}(Config.MismatchPolicy, Config.MismatchPath, Config.MismatchMax)
+	Config.MismatchPolicy = "quarantine"
+	Config.MismatchPath = "content-store-mismatch-test"
+	Config.MismatchMax = "2"
+	defer os.RemoveAll(Config.MismatchPath)
+
+	setup()
+	defer teardown()
+
+	m := &MetaObject{
+		Oid:  "6ae8a75555209fd6c44157c0aed8016e763ff435a19cf186f76863140143ff72",
+		Size: 12,
+	}
+	for _, bogus := range []string{"first conten", "secon conten", "third conten"} {
+		if err := contentStore.Put(m, bytes.NewBufferString(bogus)); err != errHashMismatch {
+			t.Fatalf("expected hash mismatch, got: %v", err)
+		}
+	}
+
+	files, _ := ioutil.ReadDir(Config.MismatchPath)
+	var kept []string
+	for _, f := range files {
+		data, _ := ioutil.ReadFile(filepath.Join(Config.MismatchPath, f.Name()))
+		kept = append(kept, string(data))
+	}
+	sort.Strings(kept)
+	if len(kept) != 2 || kept[0] != "secon conten" || kept[1] != "third conten" {
+		t.Errorf("expected the last 2 attempts to be kept, got %q", kept)
+	}
+}
+
 func TestContentStorePutSizeMismatch(t *testing.T) {
 	setup()
 	defer teardown()
@@ -73,6 +205,83 @@ func TestContentStorePutSizeMismatch(t *testing.T) {
 	}
 }
 
+func TestContentStorePutIdentical(t *testing.T) {
+	setup()
+	defer teardown()
+
+	m := &MetaObject{
+		Oid:  "6ae8a75555209fd6c44157c0aed8016e763ff435a19cf186f76863140143ff72",
+		Size: 12,
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := contentStore.Put(m, bytes.NewBuffer([]byte("test content"))); err != nil {
+			t.Fatalf("expected put %d to succeed, got: %s", i, err)
+		}
+	}
+}
+
+func TestContentStorePutConflict(t *testing.T) {
+	setup()
+	defer teardown()
+
+	m := &MetaObject{
+		Oid:  "6ae8a75555209fd6c44157c0aed8016e763ff435a19cf186f76863140143ff72",
+		Size: 12,
+	}
+
+	if err := contentStore.Put(m, bytes.NewBuffer([]byte("test content"))); err != nil {
+		t.Fatalf("expected put to succeed, got: %s", err)
+	}
+	if err := contentStore.Put(m, bytes.NewBuffer([]byte("bogus conten"))); err != errContentExists {
+		t.Fatalf("expected errContentExists, got: %v", err)
+	}
+
+	path := "content-store-test/6a/e8/a75555209fd6c44157c0aed8016e763ff435a19cf186f76863140143ff72"
+	by, err := ioutil.ReadFile(path)
+	if err != nil || string(by) != "test content" {
+		t.Fatalf("expected stored content to be preserved, got: %q, %v", by, err)
+	}
+}
+
+func TestContentStorePutAllowOverwrite(t *testing.T) {
+	setup()
+	defer teardown()
+
+	m := &MetaObject{
+		Oid:  "6ae8a75555209fd6c44157c0aed8016e763ff435a19cf186f76863140143ff72",
+		Size: 12,
+	}
+
+	// Simulate content corrupted at rest
+	path := "content-store-test/6a/e8/a75555209fd6c44157c0aed8016e763ff435a19cf186f76863140143ff72"
+	if err := os.MkdirAll("content-store-test/6a/e8", 0750); err != nil {
+		t.Fatalf("error creating content dir: %s", err)
+	}
+	if err := ioutil.WriteFile(path, []byte("corrupted!!!"), 0640); err != nil {
+		t.Fatalf("error writing corrupted content: %s", err)
+	}
+
+	if err := contentStore.Put(m, bytes.NewBuffer([]byte("test content"))); err != errContentExists {
+		t.Fatalf("expected errContentExists, got: %v", err)
+	}
+
+	defer func(allow string) { Config.AllowOverwrite = allow }(Config.AllowOverwrite)
+	Config.AllowOverwrite = "true"
+
+	if err := contentStore.Put(m, bytes.NewBuffer([]byte("bogus conten"))); err != errHashMismatch {
+		t.Fatalf("expected hash mismatch, got: %v", err)
+	}
+	if err := contentStore.Put(m, bytes.NewBuffer([]byte("test content"))); err != nil {
+		t.Fatalf("expected put to succeed, got: %s", err)
+	}
+
+	by, err := ioutil.ReadFile(path)
+	if err != nil || string(by) != "test content" {
+		t.Fatalf("expected content to be overwritten, got: %q, %v", by, err)
+	}
+}
+
 func TestContentStoreGet(t *testing.T) {
 	setup()
 	defer teardown()
@@ -163,6 +372,155 @@ func TestContentStoreExists(t *testing.T) {
 	}
 }
 
+func TestContentStoreCopy(t *testing.T) {
+	setup()
+	defer teardown()
+
+	oid := "6ae8a75555209fd6c44157c0aed8016e763ff435a19cf186f76863140143ff72"
+	src := filepath.Join("content-store-test", "incoming", "old-name")
+	os.MkdirAll(filepath.Dir(src), 0750)
+	if err := ioutil.WriteFile(src, []byte("test content"), 0640); err != nil {
+		t.Fatalf("error placing content: %s", err)
+	}
+
+	if err := contentStore.Copy("incoming/old-name", oid); err != nil {
+		t.Fatalf("expected copy to succeed, got: %s", err)
+	}
+	if err := contentStore.Verify(oid, 12); err != nil {
+		t.Fatalf("expected the copy to be the object's content, got: %s", err)
+	}
+
+	// The content is linked rather than written again
+	srcInfo, _ := os.Stat(src)
+	dstInfo, err := os.Stat(filepath.Join("content-store-test", transformKey(oid)))
+	if err != nil || !os.SameFile(srcInfo, dstInfo) {
+		t.Errorf("expected the destination to be a link to the source, got: %v", err)
+	}
+
+	other := "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	if err := contentStore.Copy("incoming/old-name", other); err != errHashMismatch {
+		t.Errorf("expected a copy to the wrong oid to fail, got: %v", err)
+	}
+	if contentStore.Exists(&MetaObject{Oid: other}) {
+		t.Errorf("expected no content to be stored for the wrong oid")
+	}
+
+	if err := contentStore.Copy("incoming/missing", other); err != errFileNotExist {
+		t.Errorf("expected a missing source to fail, got: %v", err)
+	}
+	if err := contentStore.Copy("../outside", other); err != errInvalidKey {
+		t.Errorf("expected a key outside the store to fail, got: %v", err)
+	}
+	if err := contentStore.Copy("incoming/old-name", "../../outside"); err != errUnknownOidHash {
+		t.Errorf("expected a destination that is not an oid to fail, got: %v", err)
+	}
+	if _, err := os.Stat("outside"); err == nil {
+		os.RemoveAll("outside")
+		t.Errorf("expected nothing to be created outside the store")
+	}
+}
+
+func TestOidHash(t *testing.T) {
+	oid := "6ae8a75555209fd6c44157c0aed8016e763ff435a19cf186f76863140143ff72"
+
+	alg, err := oidAlgorithmFor(oid)
+	if err != nil {
+		t.Fatalf("expected sha256 oid to be recognized, got: %s", err)
+	}
+	if alg.Name != "sha256" {
+		t.Errorf("expected sha256 algorithm, got: %s", alg.Name)
+	}
+
+	h, err := newOidHash(oid)
+	if err != nil {
+		t.Fatalf("expected hash for oid, got: %s", err)
+	}
+	h.Write([]byte("test content"))
+	if !oidMatches(h, oid) {
+		t.Errorf("expected content to match oid")
+	}
+
+	h, _ = newOidHash(oid)
+	h.Write([]byte("bogus content"))
+	if oidMatches(h, oid) {
+		t.Errorf("expected bogus content to not match oid")
+	}
+
+	for _, bad := range []string{"", "6ae8a755", "zz" + oid[2:], oid + "00"} {
+		if _, err := newOidHash(bad); err != errUnknownOidHash {
+			t.Errorf("expected %q to be rejected, got: %v", bad, err)
+		}
+	}
+}
+
+func TestContentStoreUploads(t *testing.T) {
+	setup()
+	defer teardown()
+
+	oid := "6ae8a75555209fd6c44157c0aed8016e763ff435a19cf186f76863140143ff72"
+	tmpPath := "content-store-test/6a/e8/a75555209fd6c44157c0aed8016e763ff435a19cf186f76863140143ff72.tmp"
+
+	os.MkdirAll(filepath.Dir(tmpPath), 0750)
+	if err := ioutil.WriteFile(tmpPath, []byte("test"), 0640); err != nil {
+		t.Fatalf("error writing temp file: %s", err)
+	}
+
+	uploads, err := contentStore.Uploads()
+	if err != nil {
+		t.Fatalf("expected uploads to be listed, got: %s", err)
+	}
+	if len(uploads) != 1 {
+		t.Fatalf("expected 1 upload, got %d", len(uploads))
+	}
+	if uploads[0].Oid != oid {
+		t.Errorf("expected upload of %s, got %s", oid, uploads[0].Oid)
+	}
+	if uploads[0].Received != 4 {
+		t.Errorf("expected 4 bytes received, got %d", uploads[0].Received)
+	}
+
+	if err := contentStore.CancelUpload(oid); err != nil {
+		t.Fatalf("expected upload to be cancelled, got: %s", err)
+	}
+	if _, err := os.Stat(tmpPath); err == nil {
+		t.Errorf("expected temp file to be removed")
+	}
+	if err := contentStore.CancelUpload(oid); err != errFileNotExist {
+		t.Errorf("expected cancelling a missing upload to fail, got: %v", err)
+	}
+}
+
+func TestContentStorePurgeUploads(t *testing.T) {
+	setup()
+	defer teardown()
+
+	idle := "content-store-test/6a/e8/a75555209fd6c44157c0aed8016e763ff435a19cf186f76863140143ff72.tmp"
+	active := "content-store-test/ff/ff/ffff.tmp"
+
+	for _, path := range []string{idle, active} {
+		os.MkdirAll(filepath.Dir(path), 0750)
+		if err := ioutil.WriteFile(path, []byte("test"), 0640); err != nil {
+			t.Fatalf("error writing temp file: %s", err)
+		}
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(idle, old, old)
+
+	purged, err := contentStore.PurgeUploads(time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("expected idle uploads to be purged, got: %s", err)
+	}
+	if len(purged) != 1 || purged[0] != "6ae8a75555209fd6c44157c0aed8016e763ff435a19cf186f76863140143ff72" {
+		t.Errorf("expected only the idle upload to be purged, got %v", purged)
+	}
+	if _, err := os.Stat(idle); err == nil {
+		t.Errorf("expected idle temp file to be removed")
+	}
+	if _, err := os.Stat(active); err != nil {
+		t.Errorf("expected active temp file to be kept, got: %s", err)
+	}
+}
+
 func setup() {
 	store, err := NewContentStore("content-store-test")
 	if err != nil {
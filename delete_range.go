@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// RangeDeleteResult reports a delete of the objects created in a time
+// window. In a dry run Deleted lists the objects that would be deleted.
+type RangeDeleteResult struct {
+	DryRun  bool              `json:"dry_run"`
+	Matched int               `json:"matched"`
+	Deleted []string          `json:"deleted"`
+	Skipped []RangeDeleteSkip `json:"skipped"`
+}
+
+type RangeDeleteSkip struct {
+	Oid    string `json:"oid"`
+	Reason string `json:"reason"`
+}
+
+// ObjectsCreatedBetween returns the MetaObjects created at or after start and
+// before end. Objects stored before creation times were recorded never match.
+func (s *MetaStore) ObjectsCreatedBetween(start, end time.Time) ([]*MetaObject, error) {
+	var objects []*MetaObject
+
+	err := s.ForEachObject(func(meta *MetaObject) error {
+		if c := meta.CreatedAt; c != nil && !c.Before(start) && c.Before(end) {
+			objects = append(objects, meta)
+		}
+		return nil
+	})
+
+	return objects, err
+}
+
+// isLocked returns true if a lock in one of the repositories referencing the
+// object holds a path with the object's file name. This is a heuristic. The
+// name is the one the object was last uploaded as, a lock on another file with
+// the same name counts, and a file renamed since does not, so it may skip an
+// object that is not locked and miss one that is.
+func (a *App) isLocked(meta *MetaObject) (bool, error) {
+	if meta.Name == "" {
+		return false, nil
+	}
+
+	for _, ref := range meta.Refs {
+		repo := ref[strings.Index(ref, "/")+1:]
+		locks, err := a.metaStore.Locks(repo)
+		if err != nil {
+			return false, err
+		}
+		for _, l := range locks {
+			if path.Base(l.Path) == meta.Name {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// deleteRangeHandler deletes the objects created between the start and end
+// RFC 3339 times of the form, end excluded. Pinned, locked and shared objects
+// are skipped. Nothing is deleted unless confirm is set, the result only
+// shows what would be.
+func (a *App) deleteRangeHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// The messages are fixed, as parse errors quote the form values
+	message := "start and end must be RFC 3339 times"
+	if start, err := time.Parse(time.RFC3339, r.FormValue("start")); err == nil {
+		if end, err := time.Parse(time.RFC3339, r.FormValue("end")); err == nil {
+			if end.After(start) {
+				a.deleteRange(w, r, start, end)
+				return
+			}
+			message = "end must be after start"
+		}
+	}
+
+	w.WriteHeader(http.StatusBadRequest)
+	fmt.Fprintf(w, `{"message":"%s"}`, message)
+}
+
+func (a *App) deleteRange(w http.ResponseWriter, r *http.Request, start, end time.Time) {
+	objects, err := a.metaStore.ObjectsCreatedBetween(start, end)
+	if err != nil {
+		writeStatus(w, r, metaErrorStatus(err, 500), false)
+		return
+	}
+
+	result := RangeDeleteResult{
+		DryRun:  !isTrue(r.FormValue("confirm")),
+		Matched: len(objects),
+		Deleted: []string{},
+		Skipped: []RangeDeleteSkip{},
+	}
+
+	for _, meta := range objects {
+		err := a.checkRangeDelete(meta)
+		if err == nil && !result.DryRun {
+			err = a.deleteObject(meta, "")
+		}
+		if err != nil {
+			result.Skipped = append(result.Skipped, RangeDeleteSkip{meta.Oid, err.Error()})
+			continue
+		}
+		result.Deleted = append(result.Deleted, meta.Oid)
+	}
+
+	json.NewEncoder(w).Encode(result)
+}
+
+// checkRangeDelete returns the reason an object would not be deleted, so that
+// dry runs report the same skips as deletes.
+func (a *App) checkRangeDelete(meta *MetaObject) error {
+	if meta.Pinned {
+		return errObjectPinned
+	}
+	if meta.RefCount() > 1 {
+		return errObjectShared
+	}
+	locked, err := a.isLocked(meta)
+	if err != nil {
+		return err
+	}
+	if locked {
+		return errObjectLocked
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	mathrand "math/rand"
+	"net/http"
+	"time"
+)
+
+// injectFault delays or fails API requests at the rates of the fault flags,
+// so that client retries can be tested against the server. It does nothing
+// unless fault injection is enabled. It returns true if it answered the
+// request with a failure.
+func (a *App) injectFault(w http.ResponseWriter, r *http.Request) bool {
+	if !Config.IsFaultInjection() {
+		return false
+	}
+
+	failRate, delayRate, delay := a.flags.Faults()
+	if delay > 0 && delayRate > 0 && mathrand.Float64() < delayRate {
+		time.Sleep(delay)
+	}
+	if failRate <= 0 || mathrand.Float64() >= failRate {
+		return false
+	}
+
+	status := 500
+	if mathrand.Intn(2) == 0 {
+		status = 503
+	}
+
+	w.Header().Set("Content-Type", metaMediaType)
+	w.WriteHeader(status)
+	fmt.Fprint(w, `{"message":"Injected fault"}`)
+	logRequest(r, status)
+	return true
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFaultInjection(t *testing.T) {
+	defer func(v string) { Config.FaultInjection = v }(Config.FaultInjection)
+	defer setFlag(t, flagFaultRate, "")
+	defer setFlag(t, flagDelayRate, "")
+	defer setFlag(t, flagFaultDelay, "")
+
+	whoami := func() int {
+		res, err := api("GET", "/api/whoami", "", testUser, testPass, nil)
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+	failures := func(n int) int {
+		failed := 0
+		for i := 0; i < n; i++ {
+			switch status := whoami(); status {
+			case 200:
+			case 500, 503:
+				failed++
+			default:
+				t.Fatalf("expected requests to succeed or fail with 500 or 503, got %d", status)
+			}
+		}
+		return failed
+	}
+
+	if status := setFlag(t, flagFaultRate, "0.3"); status != 200 {
+		t.Fatalf("expected setting the flag to succeed, got %d", status)
+	}
+
+	Config.FaultInjection = "false"
+	if n := failures(100); n != 0 {
+		t.Errorf("expected no faults while fault injection is disabled, got %d", n)
+	}
+
+	Config.FaultInjection = "true"
+	if n := failures(1000); n < 220 || n > 380 {
+		t.Errorf("expected about 300 of 1000 requests to fail, got %d", n)
+	}
+
+	if status := setFlag(t, flagFaultRate, ""); status != 200 {
+		t.Fatalf("expected clearing the flag to succeed, got %d", status)
+	}
+	if status := setFlag(t, flagFaultDelay, "50ms"); status != 200 {
+		t.Fatalf("expected setting the flag to succeed, got %d", status)
+	}
+	if status := setFlag(t, flagDelayRate, "1"); status != 200 {
+		t.Fatalf("expected setting the flag to succeed, got %d", status)
+	}
+	start := time.Now()
+	if status := whoami(); status != 200 {
+		t.Errorf("expected a delayed request to succeed, got %d", status)
+	}
+	if d := time.Since(start); d < 50*time.Millisecond {
+		t.Errorf("expected the request to be delayed, took %s", d)
+	}
+
+	if status := setFlag(t, flagFaultDelay, "soon"); status != 400 {
+		t.Errorf("expected an invalid delay to be refused, got %d", status)
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	errUnknownFlag = errors.New("Unknown feature flag")
+	errInvalidFlag = errors.New("Invalid feature flag value")
+)
+
+// Feature flags can be toggled at runtime from the mgmt interface. They are
+// persisted in the meta store, and override the configuration they share a
+// name with until they are cleared.
+const (
+	flagReadOnly     = "read_only"
+	flagQuarantine   = "quarantine"
+	flagVerifySample = "verify_sample"
+	flagBanner       = "banner"
+	flagFaultRate    = "fault_rate"
+	flagFaultDelay   = "fault_delay"
+	flagDelayRate    = "fault_delay_rate"
+)
+
+type flagKind int
+
+const (
+	boolFlag flagKind = iota
+	rateFlag
+	textFlag
+	durationFlag
+)
+
+type flagDefinition struct {
+	Name        string
+	Description string
+	kind        flagKind
+}
+
+var flagDefinitions = []flagDefinition{
+	{flagReadOnly, "Refuse all uploads and lock changes", boolFlag},
+	{flagQuarantine, "Quarantine uploaded objects, overrides LFS_QUARANTINE", boolFlag},
+	{flagVerifySample, "Fraction of downloads to verify, overrides LFS_VERIFYSAMPLE", rateFlag},
+	{flagBanner, "Message shown on every mgmt page", textFlag},
+	{flagFaultRate, "Fraction of API requests failed with 500 or 503, with LFS_FAULTINJECTION", rateFlag},
+	{flagDelayRate, "Fraction of API requests delayed by fault_delay, with LFS_FAULTINJECTION", rateFlag},
+	{flagFaultDelay, "How long delayed API requests wait, with LFS_FAULTINJECTION", durationFlag},
+}
+
+func lookupFlag(name string) (flagDefinition, bool) {
+	for _, d := range flagDefinitions {
+		if d.Name == name {
+			return d, true
+		}
+	}
+	return flagDefinition{}, false
+}
+
+// validate returns errInvalidFlag if value cannot be set on the flag. An
+// empty value clears it.
+func (d flagDefinition) validate(value string) error {
+	if value == "" {
+		return nil
+	}
+	switch d.kind {
+	case boolFlag:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return errInvalidFlag
+		}
+	case rateFlag:
+		if rate, err := strconv.ParseFloat(value, 64); err != nil || rate < 0 || rate > 1 {
+			return errInvalidFlag
+		}
+	case durationFlag:
+		if d, err := time.ParseDuration(value); err != nil || d < 0 {
+			return errInvalidFlag
+		}
+	}
+	return nil
+}
+
+// FlagState is a feature flag as listed by the mgmt interface.
+type FlagState struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Value       string `json:"value"`
+	Set         bool   `json:"set"`
+}
+
+// featureFlags caches the flags stored in the meta store. The cache is
+// reloaded whenever a flag is changed through it.
+type featureFlags struct {
+	mu     sync.RWMutex
+	store  *MetaStore
+	values map[string]string
+}
+
+func newFeatureFlags(store *MetaStore) *featureFlags {
+	f := &featureFlags{store: store, values: make(map[string]string)}
+	if err := f.reload(); err != nil {
+		logger.Log(kv{"fn": "newFeatureFlags", "err": "Could not load feature flags: " + err.Error()})
+	}
+	return f
+}
+
+func (f *featureFlags) reload() error {
+	values, err := f.store.Flags()
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	f.values = values
+	f.mu.Unlock()
+	return nil
+}
+
+func (f *featureFlags) get(name string) (string, bool) {
+	if f == nil {
+		return "", false
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	v, ok := f.values[name]
+	return v, ok
+}
+
+// Set stores the value of a flag, or clears it if value is empty.
+func (f *featureFlags) Set(name, value string) error {
+	d, ok := lookupFlag(name)
+	if !ok {
+		return errUnknownFlag
+	}
+	if err := d.validate(value); err != nil {
+		return err
+	}
+
+	if err := f.store.SetFlag(name, value); err != nil {
+		return err
+	}
+	return f.reload()
+}
+
+// All returns the state of every known flag.
+func (f *featureFlags) All() []FlagState {
+	states := make([]FlagState, 0, len(flagDefinitions))
+	for _, d := range flagDefinitions {
+		v, ok := f.get(d.Name)
+		states = append(states, FlagState{Name: d.Name, Description: d.Description, Value: v, Set: ok})
+	}
+	return states
+}
+
+// IsReadOnly returns true if the server refuses every change.
+func (f *featureFlags) IsReadOnly() bool {
+	v, _ := f.get(flagReadOnly)
+	b, _ := strconv.ParseBool(v)
+	return b
+}
+
+// IsQuarantine returns true if uploaded objects are quarantined.
+func (f *featureFlags) IsQuarantine() bool {
+	if v, ok := f.get(flagQuarantine); ok {
+		b, _ := strconv.ParseBool(v)
+		return b
+	}
+	return Config.IsQuarantine()
+}
+
+// VerifySampleRate returns the fraction of complete downloads that are
+// verified.
+func (f *featureFlags) VerifySampleRate() float64 {
+	if v, ok := f.get(flagVerifySample); ok {
+		return parseRate(v)
+	}
+	return Config.VerifySampleRate()
+}
+
+// Faults returns the fraction of API requests to fail and the fraction to
+// delay, and how long to delay them for.
+func (f *featureFlags) Faults() (float64, float64, time.Duration) {
+	failRate, _ := f.get(flagFaultRate)
+	delayRate, _ := f.get(flagDelayRate)
+	v, _ := f.get(flagFaultDelay)
+	delay, _ := time.ParseDuration(v)
+	return parseRate(failRate), parseRate(delayRate), delay
+}
+
+// Banner returns the message shown on mgmt pages.
+func (f *featureFlags) Banner() string {
+	v, _ := f.get(flagBanner)
+	return v
+}
+
+func (a *App) flagsHandler(w http.ResponseWriter, r *http.Request) {
+	if err := a.render(w, "flags.tmpl", pageData{Name: "flags", Flags: a.flags.All()}); err != nil {
+		writeStatus(w, r, 404, false)
+	}
+}
+
+// setFlagHandler sets a flag from the name and value form fields, and returns
+// to the flags page.
+func (a *App) setFlagHandler(w http.ResponseWriter, r *http.Request) {
+	if err := a.flags.Set(r.FormValue("name"), r.FormValue("value")); err != nil {
+		w.WriteHeader(flagErrorStatus(err))
+		fmt.Fprintf(w, "Error setting flag: %s", err)
+		return
+	}
+
+	http.Redirect(w, r, Config.BasePrefix()+"/mgmt/flags", 302)
+}
+
+// flagsAPIHandler lists the flags as JSON. A POST sets the name and value in
+// its JSON body first.
+func (a *App) flagsAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method == "POST" {
+		var req struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"message":"%s"}`, err)
+			return
+		}
+		if err := a.flags.Set(req.Name, req.Value); err != nil {
+			w.WriteHeader(flagErrorStatus(err))
+			fmt.Fprintf(w, `{"message":"%s"}`, err)
+			return
+		}
+	}
+
+	json.NewEncoder(w).Encode(a.flags.All())
+}
+
+func flagErrorStatus(err error) int {
+	switch err {
+	case errUnknownFlag:
+		return 404
+	case errInvalidFlag:
+		return 400
+	}
+	return metaErrorStatus(err, 500)
+}
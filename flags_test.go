@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func setFlag(t *testing.T, name, value string) int {
+	buf := bytes.NewBufferString(fmt.Sprintf(`{"name":"%s","value":"%s"}`, name, value))
+	res, err := api("POST", "/mgmt/api/flags", "", testAdminUser, testAdminPass, buf)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	return res.StatusCode
+}
+
+func TestFlagReadOnly(t *testing.T) {
+	defer setFlag(t, flagReadOnly, "")
+
+	upload := func() int {
+		buf := bytes.NewBufferString(fmt.Sprintf(`{"operation":"upload","objects":[{"oid":"%s","size":%d}]}`, contentOid, contentSize))
+		res, err := api("POST", "/user/repo/objects/batch", metaMediaType, testUser, testPass, buf)
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		return res.StatusCode
+	}
+
+	if status := upload(); status != 200 {
+		t.Fatalf("expected status 200 before the flag is set, got %d", status)
+	}
+
+	if status := setFlag(t, flagReadOnly, "true"); status != 200 {
+		t.Fatalf("expected setting the flag to succeed, got %d", status)
+	}
+	if status := upload(); status != 403 {
+		t.Errorf("expected uploads to be refused while read-only, got %d", status)
+	}
+	if !newFeatureFlags(testMetaStore).IsReadOnly() {
+		t.Errorf("expected the flag to be persisted in the meta store")
+	}
+
+	if status := setFlag(t, flagReadOnly, ""); status != 200 {
+		t.Fatalf("expected clearing the flag to succeed, got %d", status)
+	}
+	if status := upload(); status != 200 {
+		t.Errorf("expected uploads to be accepted once the flag is cleared, got %d", status)
+	}
+}
+
+func TestFlagQuarantine(t *testing.T) {
+	defer setFlag(t, flagQuarantine, "")
+
+	if status := setFlag(t, flagQuarantine, "true"); status != 200 {
+		t.Fatalf("expected setting the flag to succeed, got %d", status)
+	}
+
+	data := "TestFlagQuarantine content"
+	oid := fmt.Sprintf("%x", sha256.Sum256([]byte(data)))
+	if _, err := testMetaStore.Put(&RequestVars{Oid: oid, Size: int64(len(data))}); err != nil {
+		t.Fatalf("error seeding meta store: %s", err)
+	}
+	res, err := api("PUT", "/user/repo/objects/"+oid, contentMediaType, testUser, testPass, bytes.NewBufferString(data))
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+
+	res, err = api("GET", "/user/repo/objects/"+oid, contentMediaType, testUser, testPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 409 {
+		t.Errorf("expected the upload to be quarantined, got %d", res.StatusCode)
+	}
+}
+
+func TestFlagBanner(t *testing.T) {
+	defer setFlag(t, flagBanner, "")
+
+	if status := setFlag(t, flagBanner, "Maintenance tonight"); status != 200 {
+		t.Fatalf("expected setting the flag to succeed, got %d", status)
+	}
+
+	res, err := api("GET", "/mgmt/users", "", testAdminUser, testAdminPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	if !strings.Contains(string(body), "Maintenance tonight") {
+		t.Errorf("expected the banner on mgmt pages")
+	}
+}
+
+func TestFlagsAPI(t *testing.T) {
+	if status := setFlag(t, "unknown", "true"); status != 404 {
+		t.Errorf("expected unknown flags to be refused with 404, got %d", status)
+	}
+	if status := setFlag(t, flagReadOnly, "sometimes"); status != 400 {
+		t.Errorf("expected invalid values to be refused with 400, got %d", status)
+	}
+	if status := setFlag(t, flagVerifySample, "2"); status != 400 {
+		t.Errorf("expected rates over 1 to be refused with 400, got %d", status)
+	}
+
+	defer setFlag(t, flagVerifySample, "")
+	if status := setFlag(t, flagVerifySample, "0.5"); status != 200 {
+		t.Fatalf("expected setting the flag to succeed, got %d", status)
+	}
+
+	res, err := api("GET", "/mgmt/api/flags", "", testAdminUser, testAdminPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	var flags []FlagState
+	if err := json.NewDecoder(res.Body).Decode(&flags); err != nil {
+		t.Fatalf("expected response body to be flags, got error: %s", err)
+	}
+	if len(flags) != len(flagDefinitions) {
+		t.Fatalf("expected every flag to be listed, got %+v", flags)
+	}
+	for _, f := range flags {
+		if set := f.Name == flagVerifySample; f.Set != set || (set && f.Value != "0.5") {
+			t.Errorf("unexpected flag state %+v", f)
+		}
+	}
+}
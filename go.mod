@@ -7,6 +7,7 @@ require (
 	github.com/gorilla/context v0.0.0-20141217160251-215affda49ad
 	github.com/gorilla/mux v0.0.0-20140926153814-e444e69cbd2e
 	github.com/kardianos/osext v0.0.0-20150317202929-efacde031546 // indirect
+	golang.org/x/crypto v0.0.0-20190308221718-c2843e01d9a2
 )
 
 go 1.11
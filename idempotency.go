@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxIdempotencyKeys caps how many responses the idempotency cache remembers.
+// Once it is full, the oldest are forgotten first.
+const maxIdempotencyKeys = 10000
+
+// idempotencyCache remembers the responses to requests made with an
+// Idempotency-Key header, so that a retried request gets the original
+// response without its side effects being repeated. Keys are kept in the
+// order they were stored, which as every key has the same ttl is also the
+// order they expire in, so expired keys are dropped from the front without
+// looking at the others.
+type idempotencyCache struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	max      int
+	entries  map[string]*list.Element
+	order    *list.List
+	inflight map[string]chan struct{}
+}
+
+type idempotentResponse struct {
+	key     string
+	status  int
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+func newIdempotencyCache(ttl time.Duration) *idempotencyCache {
+	return &idempotencyCache{
+		ttl:      ttl,
+		max:      maxIdempotencyKeys,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+		inflight: make(map[string]chan struct{}),
+	}
+}
+
+// expire drops the keys that expired by now. c.mu must be held.
+func (c *idempotencyCache) expire(now time.Time) {
+	for e := c.order.Front(); e != nil; e = c.order.Front() {
+		res := e.Value.(*idempotentResponse)
+		if now.Before(res.expires) {
+			return
+		}
+		c.order.Remove(e)
+		delete(c.entries, res.key)
+	}
+}
+
+func (c *idempotencyCache) get(key string, now time.Time) (*idempotentResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.expire(now)
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	return e.Value.(*idempotentResponse), true
+}
+
+// begin returns the remembered response for key. If there is none, key is
+// marked in flight and begin returns false, and the caller must call finish
+// once it handled the request. A request with a key that is already in
+// flight waits for it to finish first, so only one of them is handled.
+func (c *idempotencyCache) begin(key string) (*idempotentResponse, bool) {
+	for {
+		c.mu.Lock()
+		c.expire(time.Now())
+		if e, ok := c.entries[key]; ok {
+			c.mu.Unlock()
+			return e.Value.(*idempotentResponse), true
+		}
+		wait, ok := c.inflight[key]
+		if !ok {
+			c.inflight[key] = make(chan struct{})
+			c.mu.Unlock()
+			return nil, false
+		}
+		c.mu.Unlock()
+		<-wait
+	}
+}
+
+// finish remembers res for key, unless it is nil, and lets the requests
+// waiting for key go on.
+func (c *idempotencyCache) finish(key string, res *idempotentResponse) {
+	if res != nil {
+		c.put(key, res, time.Now())
+	}
+
+	c.mu.Lock()
+	if wait, ok := c.inflight[key]; ok {
+		close(wait)
+		delete(c.inflight, key)
+	}
+	c.mu.Unlock()
+}
+
+func (c *idempotencyCache) put(key string, res *idempotentResponse, now time.Time) {
+	res.key = key
+	res.expires = now.Add(c.ttl)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		c.order.Remove(e)
+	}
+	c.entries[key] = c.order.PushBack(res)
+	for c.order.Len() > c.max {
+		e := c.order.Front()
+		c.order.Remove(e)
+		delete(c.entries, e.Value.(*idempotentResponse).key)
+	}
+}
+
+// recordingWriter passes a response through while keeping a copy of it.
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// idempotent replays the response to an earlier request with the same path
+// and Idempotency-Key header instead of calling h again. Requests without the
+// header are always handled. Only successful responses are remembered, so
+// failed requests can be retried. A request arriving while another with the
+// same key is being handled waits for its response.
+func (a *App) idempotent(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			h(w, r)
+			return
+		}
+		key = r.URL.Path + " " + key
+
+		if res, ok := a.idempotency.begin(key); ok {
+			for k, v := range res.header {
+				w.Header()[k] = v
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(res.status)
+			w.Write(res.body)
+			logRequest(r, res.status)
+			return
+		}
+
+		var res *idempotentResponse
+		defer func() { a.idempotency.finish(key, res) }()
+
+		rec := &recordingWriter{ResponseWriter: w}
+		h(rec, r)
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		if rec.status < 300 {
+			res = &idempotentResponse{
+				status: rec.status,
+				header: cloneHeader(w.Header()),
+				body:   rec.body.Bytes(),
+			}
+		}
+	}
+}
+
+func cloneHeader(h http.Header) http.Header {
+	c := make(http.Header, len(h))
+	for k, v := range h {
+		c[k] = append([]string(nil), v...)
+	}
+	return c
+}
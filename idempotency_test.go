@@ -0,0 +1,154 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// tusUpload places data as a finished tus upload, as if it was sent to the
+// tus server, so that /verify can move it to the content store.
+func tusUpload(t *testing.T, data string) string {
+	oid := fmt.Sprintf("%x", sha256.Sum256([]byte(data)))
+
+	tusServer.serverMutex.Lock()
+	defer tusServer.serverMutex.Unlock()
+	if tusServer.oidToTusUrl == nil {
+		tusServer.dataPath = "lfs-tus-test"
+		tusServer.oidToTusUrl = make(map[string]string)
+	}
+	if err := os.MkdirAll(tusServer.dataPath, 0750); err != nil {
+		t.Fatalf("error creating tus dir: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(tusServer.dataPath, oid+".bin"), []byte(data), 0640); err != nil {
+		t.Fatalf("error placing tus upload: %s", err)
+	}
+	tusServer.oidToTusUrl[oid] = "http://tus.example.com/files/" + oid
+	return oid
+}
+
+func verify(t *testing.T, oid, key string) *http.Response {
+	req, err := http.NewRequest("POST", lfsServer.URL+"/verify/"+oid, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	res.Body.Close()
+	return res
+}
+
+func TestIdempotentVerify(t *testing.T) {
+	defer os.RemoveAll("lfs-tus-test")
+
+	data := "TestIdempotentVerify content"
+	oid := tusUpload(t, data)
+	key := fmt.Sprint(time.Now().UnixNano())
+
+	first := verify(t, oid, key)
+	if first.StatusCode != 200 || first.Header.Get("Idempotent-Replayed") != "" {
+		t.Fatalf("expected the first verify to be handled, got %d", first.StatusCode)
+	}
+	if !testContentStore.Exists(&MetaObject{Oid: oid, Size: int64(len(data))}) {
+		t.Fatalf("expected the upload to be moved to the content store")
+	}
+
+	// The upload is gone from the tus server, so finishing it again would fail
+	second := verify(t, oid, key)
+	if second.StatusCode != 200 || second.Header.Get("Idempotent-Replayed") != "true" {
+		t.Errorf("expected a retry to get the original response replayed, got %d %q", second.StatusCode, second.Header.Get("Idempotent-Replayed"))
+	}
+
+	other := tusUpload(t, "TestIdempotentVerify other")
+	if res := verify(t, other, key); res.Header.Get("Idempotent-Replayed") != "" {
+		t.Errorf("expected the same key for another path to be handled")
+	}
+}
+
+func TestIdempotentVerifyConcurrent(t *testing.T) {
+	defer os.RemoveAll("lfs-tus-test")
+
+	oid := tusUpload(t, "TestIdempotentVerifyConcurrent content")
+	key := fmt.Sprint(time.Now().UnixNano())
+
+	var wg sync.WaitGroup
+	replayed := make([]bool, 5)
+	for i := range replayed {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			res := verify(t, oid, key)
+			replayed[i] = res.StatusCode == 200 && res.Header.Get("Idempotent-Replayed") == "true"
+		}(i)
+	}
+	wg.Wait()
+
+	handled := 0
+	for _, r := range replayed {
+		if !r {
+			handled++
+		}
+	}
+	if handled != 1 {
+		t.Errorf("expected one of the concurrent retries to be handled and the others replayed, got %d handled", handled)
+	}
+}
+
+func TestIdempotentVerifySkipsFailures(t *testing.T) {
+	defer func(v string) { Config.Quarantine = v }(Config.Quarantine)
+	Config.Quarantine = "true"
+
+	// Without meta the upload cannot be quarantined, and verify fails
+	oid := fmt.Sprintf("%x", sha256.Sum256([]byte("TestIdempotentVerifySkipsFailures")))
+	for i := 0; i < 2; i++ {
+		if res := verify(t, oid, "key"); res.StatusCode < 300 || res.Header.Get("Idempotent-Replayed") != "" {
+			t.Errorf("expected failed requests to be handled again, got %d %q", res.StatusCode, res.Header.Get("Idempotent-Replayed"))
+		}
+	}
+}
+
+func TestIdempotentKeysExpire(t *testing.T) {
+	c := newIdempotencyCache(time.Minute)
+	now := time.Now()
+
+	c.put("key", &idempotentResponse{status: 200}, now)
+	c.put("later", &idempotentResponse{status: 200}, now.Add(time.Minute))
+	if _, ok := c.get("key", now.Add(30*time.Second)); !ok {
+		t.Fatalf("expected key to be remembered within its ttl")
+	}
+	if _, ok := c.get("key", now.Add(time.Minute)); ok {
+		t.Errorf("expected key to expire after its ttl")
+	}
+	if len(c.entries) != 1 || c.order.Len() != 1 {
+		t.Errorf("expected expired keys to be dropped, got %d", len(c.entries))
+	}
+}
+
+func TestIdempotentKeysBounded(t *testing.T) {
+	c := newIdempotencyCache(time.Hour)
+	c.max = 3
+	now := time.Now()
+
+	for i := 0; i < 5; i++ {
+		c.put(fmt.Sprint(i), &idempotentResponse{status: 200}, now)
+	}
+	if len(c.entries) != 3 || c.order.Len() != 3 {
+		t.Fatalf("expected 3 keys to be kept, got %d", len(c.entries))
+	}
+	for i, want := range []bool{false, false, true, true, true} {
+		if _, ok := c.get(fmt.Sprint(i), now); ok != want {
+			t.Errorf("expected key %d kept to be %v, got %v", i, want, ok)
+		}
+	}
+}
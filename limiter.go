@@ -0,0 +1,54 @@
+package main
+
+import (
+	"time"
+)
+
+// transferLimiter bounds the number of content transfers that run at once.
+// Requests over the limit wait for a free slot for up to timeout. A nil
+// transferLimiter does not limit anything.
+type transferLimiter struct {
+	slots   chan struct{}
+	timeout time.Duration
+}
+
+// newTransferLimiter creates a limiter allowing n concurrent transfers. It
+// returns nil, meaning unlimited, when n is not positive.
+func newTransferLimiter(n int, timeout time.Duration) *transferLimiter {
+	if n <= 0 {
+		return nil
+	}
+	return &transferLimiter{slots: make(chan struct{}, n), timeout: timeout}
+}
+
+// Acquire waits for a free slot and reports whether one was obtained before
+// the timeout. Every successful Acquire must be paired with a Release.
+func (l *transferLimiter) Acquire() bool {
+	if l == nil {
+		return true
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	timer := time.NewTimer(l.timeout)
+	defer timer.Stop()
+
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// Release frees a slot obtained by Acquire.
+func (l *transferLimiter) Release() {
+	if l == nil {
+		return
+	}
+	<-l.slots
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTransferLimiterBlocks(t *testing.T) {
+	l := newTransferLimiter(2, time.Second)
+
+	if !l.Acquire() || !l.Acquire() {
+		t.Fatalf("expected the first two transfers to acquire a slot")
+	}
+
+	acquired := make(chan bool)
+	go func() {
+		acquired <- l.Acquire()
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatalf("expected the third transfer to block while slots are taken")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	l.Release()
+
+	select {
+	case ok := <-acquired:
+		if !ok {
+			t.Fatalf("expected the third transfer to acquire the freed slot")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected the third transfer to proceed once a slot freed")
+	}
+}
+
+func TestTransferLimiterTimeout(t *testing.T) {
+	l := newTransferLimiter(1, 10*time.Millisecond)
+
+	if !l.Acquire() {
+		t.Fatalf("expected the first transfer to acquire a slot")
+	}
+	if l.Acquire() {
+		t.Fatalf("expected the second transfer to time out")
+	}
+
+	l.Release()
+	if !l.Acquire() {
+		t.Fatalf("expected a transfer to acquire the released slot")
+	}
+}
+
+func TestTransferLimiterUnlimited(t *testing.T) {
+	l := newTransferLimiter(0, 0)
+	if l != nil {
+		t.Fatalf("expected a zero limit to be unlimited")
+	}
+
+	for i := 0; i < 100; i++ {
+		if !l.Acquire() {
+			t.Fatalf("expected unlimited acquire to succeed")
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxLockBackoff caps how long clients are told to wait before trying to
+// create a contended lock again.
+const maxLockBackoff = time.Minute
+
+// lockContention counts the conflicting attempts each client made to lock a
+// path, so that clients hammering a path already locked by someone else are
+// told to back off for longer each time instead of getting conflicts.
+type lockContention struct {
+	mu      sync.Mutex
+	entries map[string]*contendedLock
+}
+
+type contendedLock struct {
+	conflicts int
+	expires   time.Time
+}
+
+func newLockContention() *lockContention {
+	return &lockContention{entries: make(map[string]*contendedLock)}
+}
+
+func contentionKey(repo, user, path string) string {
+	return repo + "\n" + user + "\n" + path
+}
+
+// conflict records a conflicting attempt and returns how long the client
+// should wait before trying again. The first conflict returns zero and is
+// answered as usual, each one after it waits twice as long as the last,
+// starting at base. A client that does not try again within twice its last
+// wait starts over.
+func (c *lockContention) conflict(key string, base time.Duration, now time.Time) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for k, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+
+	e, ok := c.entries[key]
+	if !ok {
+		e = &contendedLock{}
+		c.entries[key] = e
+	}
+	e.conflicts++
+
+	var wait time.Duration
+	if e.conflicts > 1 {
+		wait = base
+		for i := 2; i < e.conflicts && wait < maxLockBackoff; i++ {
+			wait *= 2
+		}
+		if wait > maxLockBackoff {
+			wait = maxLockBackoff
+		}
+	}
+
+	if wait > base {
+		e.expires = now.Add(2 * wait)
+	} else {
+		e.expires = now.Add(2 * base)
+	}
+	return wait
+}
+
+// reset forgets the conflicts of a client that got its lock.
+func (c *lockContention) reset(key string) {
+	c.mu.Lock()
+	delete(c.entries, key)
+	c.mu.Unlock()
+}
+
+// writeLockBackoff answers a repeated conflicting lock request with a 429 and
+// how many seconds to wait before trying again, rounded up.
+func writeLockBackoff(w http.ResponseWriter, r *http.Request, wait time.Duration) {
+	seconds := int((wait + time.Second - 1) / time.Second)
+	w.Header().Set("Retry-After", fmt.Sprint(seconds))
+	w.WriteHeader(http.StatusTooManyRequests)
+	fmt.Fprintf(w, `{"message":"lock already created, retry after %d seconds","retry_after":%d}`, seconds, seconds)
+	logRequest(r, http.StatusTooManyRequests)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestLockContentionEscalates(t *testing.T) {
+	c := newLockContention()
+	now := time.Now()
+	key := contentionKey("repo", "user", "file.psd")
+
+	for i, want := range []time.Duration{0, time.Second, 2 * time.Second, 4 * time.Second} {
+		if wait := c.conflict(key, time.Second, now); wait != want {
+			t.Errorf("expected conflict %d to wait %s, got %s", i+1, want, wait)
+		}
+	}
+
+	if wait := c.conflict(contentionKey("repo", "other", "file.psd"), time.Second, now); wait != 0 {
+		t.Errorf("expected other clients to be counted apart, got a wait of %s", wait)
+	}
+
+	for i := 0; i < 10; i++ {
+		c.conflict(key, time.Second, now)
+	}
+	if wait := c.conflict(key, time.Second, now); wait != maxLockBackoff {
+		t.Errorf("expected the wait to be capped at %s, got %s", maxLockBackoff, wait)
+	}
+}
+
+func TestLockContentionResets(t *testing.T) {
+	c := newLockContention()
+	now := time.Now()
+	key := contentionKey("repo", "user", "file.psd")
+
+	c.conflict(key, time.Second, now)
+	c.conflict(key, time.Second, now)
+	c.reset(key)
+	if wait := c.conflict(key, time.Second, now); wait != 0 {
+		t.Errorf("expected a reset to start over, got a wait of %s", wait)
+	}
+
+	c.conflict(key, time.Second, now)
+	if wait := c.conflict(key, time.Second, now.Add(3*time.Second)); wait != 0 {
+		t.Errorf("expected an attempt after twice the wait to start over, got a wait of %s", wait)
+	}
+}
+
+func TestLockBackoff(t *testing.T) {
+	defer func(v string) { Config.LockBackoff = v }(Config.LockBackoff)
+	Config.LockBackoff = "1s"
+
+	l, err := createLock(testUser, testPass, "TestLockBackoff")
+	if err != nil {
+		t.Fatalf("create lock error: %s", err)
+	}
+
+	var body struct {
+		RetryAfter int `json:"retry_after"`
+	}
+	attempt := func() (int, string) {
+		buf := bytes.NewBufferString(fmt.Sprintf(`{"path":"%s"}`, l.Path))
+		res, err := api("POST", "/user/repo/locks", metaMediaType, testUser1, testPass1, buf)
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		defer res.Body.Close()
+		body.RetryAfter = 0
+		json.NewDecoder(res.Body).Decode(&body)
+		return res.StatusCode, res.Header.Get("Retry-After")
+	}
+
+	if status, _ := attempt(); status != 409 {
+		t.Errorf("expected the first conflict to get 409, got %d", status)
+	}
+	for _, want := range []string{"1", "2", "4"} {
+		status, retryAfter := attempt()
+		if status != 429 || retryAfter != want || fmt.Sprint(body.RetryAfter) != want {
+			t.Errorf("expected 429 with a wait of %s seconds, got %d with %q and %d", want, status, retryAfter, body.RetryAfter)
+		}
+	}
+
+	buf := bytes.NewBufferString(`{"force": false}`)
+	if res, err := api("POST", "/user/repo/locks/"+l.Id+"/unlock", metaMediaType, testUser, testPass, buf); err != nil || res.StatusCode != 200 {
+		t.Fatalf("expected the lock to be released, got %v %v", res, err)
+	}
+
+	lock, err := createLock(testUser1, testPass1, l.Path)
+	if err != nil {
+		t.Fatalf("expected the lock to be created once released, got %s", err)
+	}
+	buf = bytes.NewBufferString(`{"force": false}`)
+	if res, err := api("POST", "/user/repo/locks/"+lock.Id+"/unlock", metaMediaType, testUser1, testPass1, buf); err != nil || res.StatusCode != 200 {
+		t.Fatalf("expected the lock to be released, got %v %v", res, err)
+	}
+
+	if l, err = createLock(testUser, testPass, l.Path); err != nil {
+		t.Fatalf("create lock error: %s", err)
+	}
+	if status, _ := attempt(); status != 409 {
+		t.Errorf("expected conflicts to start over after the lock was created, got %d", status)
+	}
+
+	buf = bytes.NewBufferString(`{"force": false}`)
+	if res, err := api("POST", "/user/repo/locks/"+l.Id+"/unlock", metaMediaType, testUser, testPass, buf); err != nil || res.StatusCode != 200 {
+		t.Fatalf("expected the lock to be released, got %v %v", res, err)
+	}
+}
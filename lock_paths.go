@@ -0,0 +1,70 @@
+package main
+
+import (
+	"path"
+	"strings"
+)
+
+// normalizeLockPath returns the form lock paths are stored and compared in,
+// so that variants a client may send for the same file map to one lock:
+// backslashes become slashes, the path is cleaned and a leading "./" dropped.
+// Paths are left as sent when normalization is disabled.
+func normalizeLockPath(p string) string {
+	if !Config.IsCleanLockPaths() || p == "" {
+		return p
+	}
+	return path.Clean(strings.Replace(p, "\\", "/", -1))
+}
+
+// isLockablePath returns true if p matches one of the configured lockable
+// path patterns. Every path is lockable when no patterns are configured.
+func isLockablePath(p string) bool {
+	patterns := Config.LockPathPatterns()
+	if len(patterns) == 0 {
+		return true
+	}
+
+	for _, pattern := range patterns {
+		if matchPathGlob(pattern, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPathGlob reports whether the slash separated path p matches pattern.
+// Segments are matched with path.Match, and a "**" segment matches any number
+// of segments, including none. Leading slashes and "./" are ignored.
+func matchPathGlob(pattern, p string) bool {
+	return matchSegments(splitPath(pattern), splitPath(p))
+}
+
+func splitPath(p string) []string {
+	p = strings.TrimPrefix(path.Clean("/"+p), "/")
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
+
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], segments[0]); err != nil || !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
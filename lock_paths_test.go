@@ -0,0 +1,67 @@
+package main
+
+import "testing"
+
+func TestMatchPathGlob(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, path string
+		match         bool
+	}{
+		{"assets/**", "assets/logo.png", true},
+		{"assets/**", "assets/icons/logo.png", true},
+		{"assets/**", "assets", true},
+		{"assets/**", "assetsx/logo.png", false},
+		{"assets/**", "src/assets/logo.png", false},
+		{"assets/*", "assets/logo.png", true},
+		{"assets/*", "assets/icons/logo.png", false},
+		{"**/*.psd", "art/cover.psd", true},
+		{"**/*.psd", "cover.psd", true},
+		{"**/*.psd", "art/cover.png", false},
+		{"assets/**/*.png", "assets/a/b/c.png", true},
+		{"assets/**/*.png", "assets/c.png", true},
+		{"assets/**", "/assets/logo.png", true},
+		{"assets/**", "./assets/logo.png", true},
+		{"assets/**", "assets/../secret.txt", false},
+		{"[", "[", false},
+	} {
+		if got := matchPathGlob(tc.pattern, tc.path); got != tc.match {
+			t.Errorf("matchPathGlob(%q, %q) = %t, expected %t", tc.pattern, tc.path, got, tc.match)
+		}
+	}
+}
+
+func TestNormalizeLockPath(t *testing.T) {
+	defer func(v string) { Config.CleanLockPaths = v }(Config.CleanLockPaths)
+
+	Config.CleanLockPaths = "true"
+	for _, p := range []string{"art/cover.psd", "./art/cover.psd", `art\cover.psd`, `.\art\cover.psd`, "art//cover.psd", "art/./cover.psd", "art/x/../cover.psd"} {
+		if got := normalizeLockPath(p); got != "art/cover.psd" {
+			t.Errorf("normalizeLockPath(%q) = %q, expected art/cover.psd", p, got)
+		}
+	}
+	if got := normalizeLockPath(""); got != "" {
+		t.Errorf("expected an empty path to stay empty, got %q", got)
+	}
+
+	Config.CleanLockPaths = "false"
+	if got := normalizeLockPath("./art/cover.psd"); got != "./art/cover.psd" {
+		t.Errorf("expected paths to be left as sent when disabled, got %q", got)
+	}
+}
+
+func TestIsLockablePath(t *testing.T) {
+	defer func(lockPaths string) { Config.LockPaths = lockPaths }(Config.LockPaths)
+
+	Config.LockPaths = ""
+	if !isLockablePath("anything/at/all.bin") {
+		t.Errorf("expected every path to be lockable without patterns")
+	}
+
+	Config.LockPaths = "assets/**, *.psd"
+	if !isLockablePath("assets/logo.png") || !isLockablePath("cover.psd") {
+		t.Errorf("expected paths matching any pattern to be lockable")
+	}
+	if isLockablePath("src/main.go") {
+		t.Errorf("expected paths matching no pattern to be refused")
+	}
+}
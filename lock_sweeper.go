@@ -0,0 +1,27 @@
+package main
+
+import "time"
+
+// sweepExpiredLocks removes expired locks from the store every interval until
+// stop is closed.
+func sweepExpiredLocks(s *MetaStore, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+
+		removed, err := s.DeleteExpiredLocks(time.Now())
+		if err != nil {
+			logger.Log(kv{"fn": "sweepExpiredLocks", "err": "Could not remove expired locks: " + err.Error()})
+			continue
+		}
+		if removed > 0 {
+			logger.Log(kv{"fn": "sweepExpiredLocks", "msg": "removed expired locks", "count": removed})
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+func TestSweepExpiredLocks(t *testing.T) {
+	setupMeta()
+	defer teardownMeta()
+
+	expires := time.Now().Add(50 * time.Millisecond)
+	lock := NewTestLock("sweep", "path-sweep", "user")
+	lock.ExpiresAt = &expires
+	if err := metaStoreTest.AddLocks(testRepo, lock); err != nil {
+		t.Fatalf("expected AddLocks to succeed, got : %s", err)
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		sweepExpiredLocks(metaStoreTest, 10*time.Millisecond, stop)
+		close(done)
+	}()
+	defer func() {
+		close(stop)
+		<-done
+	}()
+
+	// Locks() already hides expired locks, so check what is stored
+	stored := func() bool {
+		var data []byte
+		metaStoreTest.view(func(tx *bolt.Tx) error {
+			data = tx.Bucket(locksBucket).Get([]byte(testRepo))
+			return nil
+		})
+		return data != nil
+	}
+
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if !stored() {
+			return
+		}
+	}
+	t.Fatal("expected the sweeper to remove the expired lock")
+}
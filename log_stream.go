@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// logStream keeps the most recent log lines in a ring buffer and fans new
+// lines out to subscribers, so admins can watch the log live.
+type logStream struct {
+	mu    sync.Mutex
+	lines []string
+	next  int
+	full  bool
+	subs  map[chan string]struct{}
+}
+
+func newLogStream(size int) *logStream {
+	return &logStream{lines: make([]string, size), subs: make(map[chan string]struct{})}
+}
+
+// Write records a log line. Subscribers that are not keeping up miss lines
+// rather than holding up logging.
+func (s *logStream) Write(p []byte) (int, error) {
+	line := strings.TrimRight(string(p), "\n")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lines[s.next] = line
+	s.next = (s.next + 1) % len(s.lines)
+	if s.next == 0 {
+		s.full = true
+	}
+
+	for ch := range s.subs {
+		select {
+		case ch <- line:
+		default:
+		}
+	}
+	return len(p), nil
+}
+
+// Subscribe returns the buffered lines, oldest first, and a channel receiving
+// every line logged from then on. cancel must be called once done.
+func (s *logStream) Subscribe() (recent []string, lines <-chan string, cancel func()) {
+	ch := make(chan string, 64)
+
+	s.mu.Lock()
+	if s.full {
+		recent = append(recent, s.lines[s.next:]...)
+	}
+	recent = append(recent, s.lines[:s.next]...)
+	s.subs[ch] = struct{}{}
+	s.mu.Unlock()
+
+	return recent, ch, func() {
+		s.mu.Lock()
+		delete(s.subs, ch)
+		s.mu.Unlock()
+	}
+}
+
+// logStreamHandler streams the server log as server-sent events, starting
+// with the buffered recent lines.
+func (a *App) logStreamHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeStatus(w, r, 500, false)
+		return
+	}
+
+	recent, lines, cancel := logTail.Subscribe()
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(200)
+
+	for _, line := range recent {
+		fmt.Fprintf(w, "data: %s\n\n", line)
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case line := <-lines:
+			fmt.Fprintf(w, "data: %s\n\n", line)
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogStreamRing(t *testing.T) {
+	s := newLogStream(3)
+	for i := 1; i <= 4; i++ {
+		fmt.Fprintf(s, "line %d\n", i)
+	}
+
+	recent, lines, cancel := s.Subscribe()
+	defer cancel()
+
+	if strings.Join(recent, ",") != "line 2,line 3,line 4" {
+		t.Errorf("expected the 3 most recent lines oldest first, got: %v", recent)
+	}
+
+	fmt.Fprint(s, "line 5\n")
+	select {
+	case line := <-lines:
+		if line != "line 5" {
+			t.Errorf("expected line 5, got %q", line)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected new line to reach the subscriber")
+	}
+}
+
+func TestLogStreamHandler(t *testing.T) {
+	defer func(l *KVLogger) { logger = l }(logger)
+	logger = NewKVLogger(logTail)
+
+	req, err := http.NewRequest("GET", lfsServer.URL+"/mgmt/logs/stream", nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	req.SetBasicAuth(testAdminUser, testAdminPass)
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("response error: %s", err)
+	}
+	defer res.Body.Close()
+
+	if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected text/event-stream, got %q", ct)
+	}
+
+	events := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(res.Body)
+		for scanner.Scan() {
+			events <- scanner.Text()
+		}
+		close(events)
+	}()
+
+	marker := fmt.Sprintf("stream-%d", time.Now().UnixNano())
+	if _, err := api("GET", "/user/repo/objects/"+contentOid+"?"+marker, contentMediaType, testUser, testPass, nil); err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				t.Fatal("stream closed before the request was logged")
+			}
+			if strings.HasPrefix(event, "data: ") && strings.Contains(event, marker) {
+				return
+			}
+		case <-timeout:
+			t.Fatal("expected logged request to appear on the stream")
+		}
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"crypto/tls"
 	"fmt"
+	"io"
 	"net"
 	"os"
 	"os/signal"
@@ -17,7 +18,8 @@ const (
 )
 
 var (
-	logger = NewKVLogger(os.Stdout)
+	logTail = newLogStream(200)
+	logger  = NewKVLogger(io.MultiWriter(os.Stdout, logTail))
 )
 
 // tcpKeepAliveListener sets TCP keep-alive timeouts on accepted
@@ -38,14 +40,20 @@ func (ln tcpKeepAliveListener) Accept() (c net.Conn, err error) {
 	return tc, nil
 }
 
+// newTLSConfig returns the tls configuration for the configured minimum
+// version and cipher suites.
+func newTLSConfig() *tls.Config {
+	return &tls.Config{
+		NextProtos:   []string{"http/1.1"},
+		MinVersion:   Config.TLSMinimumVersion(),
+		CipherSuites: Config.TLSCipherSuiteIDs(),
+	}
+}
+
 func wrapHttps(l net.Listener, cert, key string) (net.Listener, error) {
 	var err error
 
-	config := &tls.Config{}
-
-	if config.NextProtos == nil {
-		config.NextProtos = []string{"http/1.1"}
-	}
+	config := newTLSConfig()
 
 	config.Certificates = make([]tls.Certificate, 1)
 	config.Certificates[0], err = tls.LoadX509KeyPair(cert, key)
@@ -59,27 +67,59 @@ func wrapHttps(l net.Listener, cert, key string) (net.Listener, error) {
 	return tlsListener, nil
 }
 
+// listen creates a tracking listener on addr, wrapping it in tls when the
+// server uses https.
+func listen(addr string) (*TrackingListener, net.Listener) {
+	tl, err := NewTrackingListener(addr)
+	if err != nil {
+		logger.Fatal(kv{"fn": "main", "err": "Could not create listener: " + err.Error()})
+	}
+
+	if !Config.IsHTTPS() {
+		return tl, tl
+	}
+
+	logger.Log(kv{"fn": "main", "msg": "Using https", "addr": addr})
+	listener, err := wrapHttps(tl, Config.Cert, Config.Key)
+	if err != nil {
+		logger.Fatal(kv{"fn": "main", "err": "Could not create https listener: " + err.Error()})
+	}
+	return tl, listener
+}
+
 func main() {
 	if len(os.Args) == 2 && os.Args[1] == "-v" {
 		fmt.Println(version)
 		os.Exit(0)
 	}
 
-	var listener net.Listener
+	if len(os.Args) == 2 && os.Args[1] == "selftest" {
+		os.Exit(runSelfTest())
+	}
 
-	tl, err := NewTrackingListener(Config.Listen)
-	if err != nil {
-		logger.Fatal(kv{"fn": "main", "err": "Could not create listener: " + err.Error()})
+	if len(os.Args) == 2 && os.Args[1] == "resetadmin" {
+		os.Exit(runResetAdmin())
 	}
 
-	listener = tl
+	if len(os.Args) == 2 && os.Args[1] == "compact" {
+		os.Exit(runCompact())
+	}
 
-	if Config.IsHTTPS() {
-		logger.Log(kv{"fn": "main", "msg": "Using https"})
-		listener, err = wrapHttps(tl, Config.Cert, Config.Key)
-		if err != nil {
-			logger.Fatal(kv{"fn": "main", "err": "Could not create https listener: " + err.Error()})
+	if len(os.Args) >= 2 && os.Args[1] == "verify-all" {
+		report := defaultVerifyReport
+		if len(os.Args) > 2 {
+			report = os.Args[2]
 		}
+		os.Exit(runVerifyAll(report))
+	}
+
+	tl, listener := listen(Config.Listen)
+
+	// The mgmt interface shares the listener unless it has its own address
+	var mgmtTl *TrackingListener
+	var mgmtListener net.Listener
+	if Config.MgmtListen != "" {
+		mgmtTl, mgmtListener = listen(Config.MgmtListen)
 	}
 
 	metaStore, err := NewMetaStore(Config.MetaDB)
@@ -87,11 +127,34 @@ func main() {
 		logger.Fatal(kv{"fn": "main", "err": "Could not open the meta store: " + err.Error()})
 	}
 
+	if err := loadAdmin(metaStore); err != nil {
+		logger.Fatal(kv{"fn": "main", "err": "Could not load the admin user: " + err.Error()})
+	}
+
+	metaStore.EnableCircuitBreaker(Config.MetaBreakerThreshold(), Config.MetaBreakerCooldown())
+
+	if size := Config.WriteBufferSize(); size > 0 {
+		metaStore.EnableWriteBuffer(size, Config.WriteFlushInterval())
+	}
+
+	if interval := Config.AccessFlushInterval(); interval > 0 {
+		metaStore.EnableAccessTracking(interval)
+	}
+
+	stopSweeper := make(chan struct{})
+	if ttl := Config.LockExpiry(); ttl > 0 {
+		go sweepExpiredLocks(metaStore, ttl, stopSweeper)
+	}
+
 	contentStore, err := NewContentStore(Config.ContentPath)
 	if err != nil {
 		logger.Fatal(kv{"fn": "main", "err": "Could not open the content store: " + err.Error()})
 	}
 
+	if maxIdle := Config.UploadIdleTimeout(); maxIdle > 0 {
+		go purgeIdleUploads(contentStore, maxIdle, stopSweeper)
+	}
+
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, syscall.SIGHUP)
 	go func(c chan os.Signal, listener net.Listener) {
@@ -100,6 +163,9 @@ func main() {
 			switch sig {
 			case syscall.SIGHUP: // Graceful shutdown
 				tl.Close()
+				if mgmtTl != nil {
+					mgmtTl.Close()
+				}
 			}
 		}
 	}(c, tl)
@@ -107,12 +173,26 @@ func main() {
 	logger.Log(kv{"fn": "main", "msg": "listening", "pid": os.Getpid(), "addr": Config.Listen, "version": version})
 
 	app := NewApp(contentStore, metaStore)
+	if deleted, err := app.recoverDeletes(); err != nil {
+		logger.Fatal(kv{"fn": "main", "err": "Could not finish interrupted deletes: " + err.Error()})
+	} else if len(deleted) > 0 {
+		logger.Log(kv{"fn": "main", "msg": "finished interrupted deletes", "count": len(deleted)})
+	}
 	if Config.IsUsingTus() {
 		tusServer.Start()
 	}
+	if mgmtListener != nil {
+		logger.Log(kv{"fn": "main", "msg": "mgmt listening", "addr": Config.MgmtListen})
+		go app.ServeMgmt(mgmtListener)
+	}
 	app.Serve(listener)
 	tl.WaitForChildren()
+	if mgmtTl != nil {
+		mgmtTl.WaitForChildren()
+	}
 	if Config.IsUsingTus() {
 		tusServer.Stop()
 	}
+	close(stopSweeper)
+	metaStore.Close()
 }
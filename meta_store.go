@@ -7,8 +7,11 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"path"
 	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/boltdb/bolt"
@@ -17,19 +20,39 @@ import (
 // MetaStore implements a metadata storage. It stores user credentials and Meta information
 // for objects. The storage is handled by boltdb.
 type MetaStore struct {
-	db *bolt.DB
+	db      *bolt.DB
+	buffer  *writeBuffer
+	access  *accessTracker
+	breaker *circuitBreaker
+
+	// compactMu is held by every transaction, and exclusively while the
+	// database file is swapped out by Compact.
+	compactMu sync.RWMutex
 }
 
 var (
 	errNoBucket       = errors.New("Bucket not found")
 	errObjectNotFound = errors.New("Object not found")
 	errNotOwner       = errors.New("Attempt to delete other user's lock")
+	errObjectPinned   = errors.New("Object is pinned")
+	errUserExists     = errors.New("User already exists")
+	errUserNotFound   = errors.New("User not found")
+	errPendingScan    = errors.New("Object is pending scan")
+	errObjectShared   = errors.New("Object is referenced by other repositories")
+	errObjectLocked   = errors.New("Object is locked")
 )
 
 var (
 	usersBucket   = []byte("users")
 	objectsBucket = []byte("objects")
 	locksBucket   = []byte("locks")
+	adminsBucket  = []byte("admins")
+
+	// uploadersBucket indexes objects by uploader. It holds a bucket of oids
+	// for each normalized user name.
+	uploadersBucket = []byte("uploaders")
+
+	flagsBucket = []byte("flags")
 )
 
 // NewMetaStore creates a new MetaStore using the boltdb database at dbFile.
@@ -52,12 +75,66 @@ func NewMetaStore(dbFile string) (*MetaStore, error) {
 			return err
 		}
 
+		if _, err := tx.CreateBucketIfNotExists(adminsBucket); err != nil {
+			return err
+		}
+
+		if _, err := tx.CreateBucketIfNotExists(uploadersBucket); err != nil {
+			return err
+		}
+
+		if _, err := tx.CreateBucketIfNotExists(flagsBucket); err != nil {
+			return err
+		}
+
 		return nil
 	})
 
 	return &MetaStore{db: db}, nil
 }
 
+// EnableCircuitBreaker makes the store fast-fail with errCircuitOpen for
+// cooldown once threshold consecutive transactions have failed.
+func (s *MetaStore) EnableCircuitBreaker(threshold int, cooldown time.Duration) {
+	s.breaker = newCircuitBreaker(threshold, cooldown)
+}
+
+// IsAvailable returns false while the circuit breaker is rejecting requests.
+func (s *MetaStore) IsAvailable() bool {
+	return !s.breaker.IsOpen()
+}
+
+// view runs fn in a read-only transaction guarded by the circuit breaker.
+func (s *MetaStore) view(fn func(*bolt.Tx) error) error {
+	return s.guard((*bolt.DB).View, fn)
+}
+
+// update runs fn in a read-write transaction guarded by the circuit breaker.
+func (s *MetaStore) update(fn func(*bolt.Tx) error) error {
+	return s.guard((*bolt.DB).Update, fn)
+}
+
+// guard runs fn in a transaction if the circuit breaker allows it. Errors
+// returned by fn itself, like errObjectNotFound, are results rather than store
+// failures and do not trip the breaker.
+func (s *MetaStore) guard(tx func(*bolt.DB, func(*bolt.Tx) error) error, fn func(*bolt.Tx) error) error {
+	if !s.breaker.Allow() {
+		return errCircuitOpen
+	}
+
+	s.compactMu.RLock()
+	defer s.compactMu.RUnlock()
+
+	var fnErr error
+	err := tx(s.db, func(t *bolt.Tx) error {
+		fnErr = fn(t)
+		return fnErr
+	})
+	s.breaker.Record(err == nil || err == fnErr)
+
+	return err
+}
+
 // Get retrieves the Meta information for an object given information in
 // RequestVars
 func (s *MetaStore) Get(v *RequestVars) (*MetaObject, error) {
@@ -69,9 +146,15 @@ func (s *MetaStore) Get(v *RequestVars) (*MetaObject, error) {
 // RequestVars
 // DO NOT CHECK authentication, as it is supposed to have been done before
 func (s *MetaStore) UnsafeGet(v *RequestVars) (*MetaObject, error) {
+	if s.buffer != nil {
+		if meta, ok := s.buffer.get(v.Oid); ok {
+			return meta, nil
+		}
+	}
+
 	var meta MetaObject
 
-	err := s.db.View(func(tx *bolt.Tx) error {
+	err := s.view(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket(objectsBucket)
 		if bucket == nil {
 			return errNoBucket
@@ -83,7 +166,16 @@ func (s *MetaStore) UnsafeGet(v *RequestVars) (*MetaObject, error) {
 		}
 
 		dec := gob.NewDecoder(bytes.NewBuffer(value))
-		return dec.Decode(&meta)
+		if err := dec.Decode(&meta); err != nil {
+			return err
+		}
+
+		// Objects being deleted are gone as far as clients are concerned,
+		// even if their delete was interrupted
+		if meta.PendingDelete {
+			return errObjectNotFound
+		}
+		return nil
 	})
 
 	if err != nil {
@@ -93,34 +185,317 @@ func (s *MetaStore) UnsafeGet(v *RequestVars) (*MetaObject, error) {
 	return &meta, nil
 }
 
-// Put writes meta information from RequestVars to the store.
+// Put writes meta information from RequestVars to the store. An object
+// pending delete is replaced, so that uploading it again undoes the delete.
 func (s *MetaStore) Put(v *RequestVars) (*MetaObject, error) {
 	// Check if it exists first
 	if meta, err := s.Get(v); err == nil {
+		if ref := refName(v); ref != "" && !meta.HasRef(ref) {
+			if meta, err = s.AddRef(v); err != nil {
+				return nil, err
+			}
+		}
+		if !meta.HasExtensions(v.Extensions) {
+			if meta, err = s.AddExtensions(v); err != nil {
+				return nil, err
+			}
+		}
 		meta.Existing = true
 		return meta, nil
 	}
 
+	now := time.Now()
+	meta := MetaObject{Oid: v.Oid, Size: v.Size, CreatedAt: &now, Uploader: v.Uploader, Extensions: v.Extensions}
+	if ref := refName(v); ref != "" {
+		meta.Refs = []string{ref}
+	}
+	if s.buffer != nil {
+		s.buffer.add(&meta)
+		return &meta, nil
+	}
+
 	var buf bytes.Buffer
 	enc := gob.NewEncoder(&buf)
-	meta := MetaObject{Oid: v.Oid, Size: v.Size}
 	err := enc.Encode(meta)
 	if err != nil {
 		return nil, err
 	}
 
-	err = s.db.Update(func(tx *bolt.Tx) error {
+	err = s.update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket(objectsBucket)
 		if bucket == nil {
 			return errNoBucket
 		}
 
+		if value := bucket.Get([]byte(v.Oid)); len(value) > 0 {
+			var pending MetaObject
+			if err := gob.NewDecoder(bytes.NewBuffer(value)).Decode(&pending); err != nil {
+				return err
+			}
+			if err := unindexUploader(tx, &pending); err != nil {
+				return err
+			}
+		}
+
 		err = bucket.Put([]byte(v.Oid), buf.Bytes())
 		if err != nil {
 			return err
 		}
 
-		return nil
+		return indexUploader(tx, &meta)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &meta, nil
+}
+
+// AddRef records that the repository of the request references the object.
+func (s *MetaStore) AddRef(v *RequestVars) (*MetaObject, error) {
+	ref := refName(v)
+	if ref == "" {
+		return s.Get(v)
+	}
+
+	return s.updateObject(v.Oid, func(meta *MetaObject) {
+		if !meta.HasRef(ref) {
+			meta.Refs = append(meta.Refs, ref)
+		}
+	})
+}
+
+// AddExtensions stores the extensions of the request on the object, replacing
+// the values of extensions it already has. Extensions are kept as the client
+// sent them, whether or not the server knows them.
+func (s *MetaStore) AddExtensions(v *RequestVars) (*MetaObject, error) {
+	return s.updateObject(v.Oid, func(meta *MetaObject) {
+		if meta.Extensions == nil {
+			meta.Extensions = make(map[string]json.RawMessage)
+		}
+		for name, value := range v.Extensions {
+			meta.Extensions[name] = value
+		}
+	})
+}
+
+// ReleaseRef removes a repository's reference to the object.
+func (s *MetaStore) ReleaseRef(oid, ref string) (*MetaObject, error) {
+	return s.updateObject(oid, func(meta *MetaObject) {
+		refs := meta.Refs[:0]
+		for _, r := range meta.Refs {
+			if r != ref {
+				refs = append(refs, r)
+			}
+		}
+		meta.Refs = refs
+	})
+}
+
+// refName returns the reference a request registers on objects, naming the
+// repository it was made for. Requests outside a repository register none.
+func refName(v *RequestVars) string {
+	if v.Repo == "" {
+		return ""
+	}
+	return v.User + "/" + v.Repo
+}
+
+// SetPinned sets or clears the pinned flag on an object. Pinned objects are
+// never removed by delete operations until they are unpinned.
+func (s *MetaStore) SetPinned(oid string, pinned bool) (*MetaObject, error) {
+	return s.updateObject(oid, func(meta *MetaObject) {
+		meta.Pinned = pinned
+	})
+}
+
+// SetExempt sets whether an object is served regardless of the download
+// policy.
+func (s *MetaStore) SetExempt(oid string, exempt bool) (*MetaObject, error) {
+	return s.updateObject(oid, func(meta *MetaObject) {
+		meta.Exempt = exempt
+	})
+}
+
+// MarkPendingDelete records that an object is being deleted, so that a delete
+// interrupted before its metadata is removed can be finished later.
+func (s *MetaStore) MarkPendingDelete(oid string) (*MetaObject, error) {
+	return s.updateObject(oid, func(meta *MetaObject) {
+		meta.PendingDelete = true
+	})
+}
+
+// ReleaseForDelete removes ref's reference to the object and, if no
+// references remain, marks it as pending delete, all in a single transaction
+// so that a reference added meanwhile keeps the object. Without a ref the
+// object is marked if no more than one repository references it. It returns
+// whether the object was marked; pinned and shared objects are left as they
+// are and errObjectPinned or errObjectShared is returned.
+func (s *MetaStore) ReleaseForDelete(oid, ref string) (bool, error) {
+	var refErr error
+	meta, err := s.updateObject(oid, func(meta *MetaObject) {
+		switch {
+		case meta.Pinned:
+			refErr = errObjectPinned
+		case ref != "" && meta.HasRef(ref):
+			refs := meta.Refs[:0]
+			for _, r := range meta.Refs {
+				if r != ref {
+					refs = append(refs, r)
+				}
+			}
+			meta.Refs = refs
+		case ref != "" && meta.RefCount() > 0, ref == "" && meta.RefCount() > 1:
+			refErr = errObjectShared
+		default:
+			meta.Refs = nil
+		}
+		if refErr == nil && meta.RefCount() == 0 {
+			meta.PendingDelete = true
+		}
+	})
+	if err != nil {
+		return false, err
+	}
+	if refErr != nil {
+		return false, refErr
+	}
+
+	return meta.PendingDelete, nil
+}
+
+// SetQuarantined sets whether an object is held back from downloads pending
+// a scan.
+func (s *MetaStore) SetQuarantined(oid string, quarantined bool) (*MetaObject, error) {
+	return s.updateObject(oid, func(meta *MetaObject) {
+		meta.Quarantined = quarantined
+	})
+}
+
+// Approve clears an object's quarantine and releases it before the end of the
+// grace period.
+func (s *MetaStore) Approve(oid string) (*MetaObject, error) {
+	return s.updateObject(oid, func(meta *MetaObject) {
+		meta.Quarantined = false
+		meta.Approved = true
+	})
+}
+
+// SetMD5 caches the base64 encoded MD5 digest of the object's content.
+func (s *MetaStore) SetMD5(oid, sum string) (*MetaObject, error) {
+	return s.updateObject(oid, func(meta *MetaObject) {
+		meta.MD5 = sum
+	})
+}
+
+// SetName records the file name the object was uploaded with. Directories
+// are stripped from the name.
+func (s *MetaStore) SetName(oid, name string) (*MetaObject, error) {
+	name = path.Base(strings.Replace(name, "\\", "/", -1))
+	if name == "." || name == "/" {
+		name = ""
+	}
+
+	return s.updateObject(oid, func(meta *MetaObject) {
+		meta.Name = name
+	})
+}
+
+// AddLabels adds labels to an object. Labels it already has are ignored.
+func (s *MetaStore) AddLabels(oid string, labels ...string) (*MetaObject, error) {
+	return s.updateObject(oid, func(meta *MetaObject) {
+		for _, l := range labels {
+			if !meta.HasLabel(l) {
+				meta.Labels = append(meta.Labels, l)
+			}
+		}
+	})
+}
+
+// RemoveLabels removes labels from an object.
+func (s *MetaStore) RemoveLabels(oid string, labels ...string) (*MetaObject, error) {
+	return s.updateObject(oid, func(meta *MetaObject) {
+		var kept []string
+		for _, l := range meta.Labels {
+			remove := false
+			for _, r := range labels {
+				if l == r {
+					remove = true
+					break
+				}
+			}
+			if !remove {
+				kept = append(kept, l)
+			}
+		}
+		meta.Labels = kept
+	})
+}
+
+// ObjectUpdate holds the fields of an object that may be corrected. Nil
+// fields are left unchanged.
+type ObjectUpdate struct {
+	Labels *[]string `json:"labels"`
+	Pinned *bool     `json:"pinned"`
+	Size   *int64    `json:"size"`
+}
+
+// UpdateObject applies u to an object. Checking that a new size matches the
+// object's content is up to the caller.
+func (s *MetaStore) UpdateObject(oid string, u *ObjectUpdate) (*MetaObject, error) {
+	return s.updateObject(oid, func(meta *MetaObject) {
+		if u.Labels != nil {
+			meta.Labels = nil
+			for _, l := range *u.Labels {
+				if !meta.HasLabel(l) {
+					meta.Labels = append(meta.Labels, l)
+				}
+			}
+		}
+		if u.Pinned != nil {
+			meta.Pinned = *u.Pinned
+		}
+		if u.Size != nil {
+			meta.Size = *u.Size
+		}
+	})
+}
+
+// updateObject loads the object, applies fn to it and writes it back in a
+// single transaction.
+func (s *MetaStore) updateObject(oid string, fn func(*MetaObject)) (*MetaObject, error) {
+	if err := s.Flush(); err != nil {
+		return nil, err
+	}
+
+	var meta MetaObject
+
+	err := s.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(objectsBucket)
+		if bucket == nil {
+			return errNoBucket
+		}
+
+		value := bucket.Get([]byte(oid))
+		if len(value) == 0 {
+			return errObjectNotFound
+		}
+
+		dec := gob.NewDecoder(bytes.NewBuffer(value))
+		if err := dec.Decode(&meta); err != nil {
+			return err
+		}
+
+		fn(&meta)
+
+		var buf bytes.Buffer
+		enc := gob.NewEncoder(&buf)
+		if err := enc.Encode(meta); err != nil {
+			return err
+		}
+
+		return bucket.Put([]byte(oid), buf.Bytes())
 	})
 
 	if err != nil {
@@ -132,12 +507,28 @@ func (s *MetaStore) Put(v *RequestVars) (*MetaObject, error) {
 
 // Delete removes the meta information from RequestVars to the store.
 func (s *MetaStore) Delete(v *RequestVars) error {
-	err := s.db.Update(func(tx *bolt.Tx) error {
+	if s.buffer != nil {
+		s.buffer.flushMu.Lock()
+		defer s.buffer.flushMu.Unlock()
+		s.buffer.remove(v.Oid)
+	}
+
+	err := s.update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket(objectsBucket)
 		if bucket == nil {
 			return errNoBucket
 		}
 
+		if value := bucket.Get([]byte(v.Oid)); len(value) > 0 {
+			var meta MetaObject
+			if err := gob.NewDecoder(bytes.NewBuffer(value)).Decode(&meta); err != nil {
+				return err
+			}
+			if err := unindexUploader(tx, &meta); err != nil {
+				return err
+			}
+		}
+
 		err := bucket.Delete([]byte(v.Oid))
 		if err != nil {
 			return err
@@ -151,7 +542,7 @@ func (s *MetaStore) Delete(v *RequestVars) error {
 
 // AddLocks write locks to the store for the repo.
 func (s *MetaStore) AddLocks(repo string, l ...Lock) error {
-	err := s.db.Update(func(tx *bolt.Tx) error {
+	err := s.update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket(locksBucket)
 		if bucket == nil {
 			return errNoBucket
@@ -176,84 +567,123 @@ func (s *MetaStore) AddLocks(repo string, l ...Lock) error {
 	return err
 }
 
-// Locks retrieves locks for the repo from the store
-func (s *MetaStore) Locks(repo string) ([]Lock, error) {
-	var locks []Lock
-	err := s.db.View(func(tx *bolt.Tx) error {
+// LockOutcome is what CreateLocks did with one of its locks. A lock that was
+// created has neither a Conflict nor OverLimit set.
+type LockOutcome struct {
+	// Conflict is the live lock holding the path of the refused lock.
+	Conflict *Lock
+	// OverLimit is set when the lock was refused as its owner already holds
+	// as many locks as they may.
+	OverLimit bool
+}
+
+// CreateLocks adds the locks that do not conflict with a live lock on the
+// same path and ref, including earlier locks in the same call, in a single
+// transaction. With a limit above zero, locks whose owner already holds that
+// many live locks across all repos are refused too, counted in the same
+// transaction. It returns what was done with each lock. If the store fails,
+// none are created.
+func (s *MetaStore) CreateLocks(repo string, limit int, l ...Lock) ([]LockOutcome, error) {
+	outcomes := make([]LockOutcome, len(l))
+	err := s.update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket(locksBucket)
 		if bucket == nil {
 			return errNoBucket
 		}
 
+		var locks []Lock
 		data := bucket.Get([]byte(repo))
 		if data != nil {
 			if err := json.Unmarshal(data, &locks); err != nil {
 				return err
 			}
 		}
-		return nil
-	})
-	return locks, err
-}
-
-// FilteredLocks return filtered locks for the repo
-func (s *MetaStore) FilteredLocks(repo, path, cursor, limit string) (locks []Lock, next string, err error) {
-	locks, err = s.Locks(repo)
-	if err != nil {
-		return
-	}
 
-	if cursor != "" {
-		lastSeen := -1
-		for i, l := range locks {
-			if l.Id == cursor {
-				lastSeen = i
-				break
+		now := time.Now()
+		counts := make(map[string]int)
+		held := liveLocks(append([]Lock(nil), locks...), now)
+		for i, lock := range l {
+			if conflict := lockConflict(held, lock); conflict != nil {
+				outcomes[i].Conflict = conflict
+				continue
+			}
+			if limit > 0 {
+				owner := lock.Owner.Name
+				if _, ok := counts[owner]; !ok {
+					n, err := countLocksByOwner(bucket, owner, now)
+					if err != nil {
+						return err
+					}
+					counts[owner] = n
+				}
+				if counts[owner] >= limit {
+					outcomes[i].OverLimit = true
+					continue
+				}
+				counts[owner]++
 			}
+			held = append(held, lock)
+			locks = append(locks, lock)
 		}
 
-		if lastSeen > -1 {
-			locks = locks[lastSeen:]
-		} else {
-			err = fmt.Errorf("cursor (%s) not found", cursor)
-			return
+		sort.Sort(LocksByCreatedAt(locks))
+		data, err := json.Marshal(&locks)
+		if err != nil {
+			return err
 		}
-	}
 
-	if path != "" {
-		var filtered []Lock
-		for _, l := range locks {
-			if l.Path == path {
-				filtered = append(filtered, l)
-			}
-		}
+		return bucket.Put([]byte(repo), data)
+	})
+	return outcomes, err
+}
 
-		locks = filtered
+// lockConflict returns the one of locks that holds the path of l on its ref,
+// or nil if there is none.
+func lockConflict(locks []Lock, l Lock) *Lock {
+	for i, held := range locks {
+		if normalizeLockPath(held.Path) == normalizeLockPath(l.Path) && held.AppliesTo(l.Ref) {
+			return &locks[i]
+		}
 	}
+	return nil
+}
 
-	if limit != "" {
-		var size int
-		size, err = strconv.Atoi(limit)
-		if err != nil || size < 0 {
-			locks = make([]Lock, 0)
-			err = fmt.Errorf("Invalid limit amount: %s", limit)
-			return
+// Locks retrieves locks for the repo from the store
+func (s *MetaStore) Locks(repo string) ([]Lock, error) {
+	var locks []Lock
+	err := s.view(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(locksBucket)
+		if bucket == nil {
+			return errNoBucket
 		}
 
-		size = int(math.Min(float64(size), float64(len(locks))))
-		if size+1 < len(locks) {
-			next = locks[size].Id
+		data := bucket.Get([]byte(repo))
+		if data != nil {
+			if err := json.Unmarshal(data, &locks); err != nil {
+				return err
+			}
 		}
-		locks = locks[:size]
-	}
+		return nil
+	})
+	return liveLocks(locks, time.Now()), err
+}
 
-	return locks, next, nil
+// liveLocks filters out the locks that have expired by now.
+func liveLocks(locks []Lock, now time.Time) []Lock {
+	live := locks[:0]
+	for _, l := range locks {
+		if !l.Expired(now) {
+			live = append(live, l)
+		}
+	}
+	return live
 }
 
-// DeleteLock removes lock for the repo by id from the store
-func (s *MetaStore) DeleteLock(repo, user, id string, force bool) (*Lock, error) {
-	var deleted *Lock
-	err := s.db.Update(func(tx *bolt.Tx) error {
+// RefreshLock moves the expiry of a lock held by user to expires. It returns
+// a nil lock if there is no such lock, or it has already expired.
+func (s *MetaStore) RefreshLock(repo, user, id string, expires time.Time) (*Lock, error) {
+	var refreshed *Lock
+	err := s.update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket(locksBucket)
 		if bucket == nil {
 			return errNoBucket
@@ -266,19 +696,190 @@ func (s *MetaStore) DeleteLock(repo, user, id string, force bool) (*Lock, error)
 				return err
 			}
 		}
-		newLocks := make([]Lock, 0, len(locks))
 
-		var lock Lock
-		for _, l := range locks {
-			if l.Id == id {
-				if l.Owner.Name != user && !force {
-					return errNotOwner
-				}
-				lock = l
-			} else if len(l.Id) > 0 {
-				newLocks = append(newLocks, l)
+		for i, l := range locks {
+			if l.Id != id || l.Expired(time.Now()) {
+				continue
 			}
-		}
+			if l.Owner.Name != user {
+				return errNotOwner
+			}
+
+			locks[i].ExpiresAt = &expires
+			refreshed = &locks[i]
+
+			data, err := json.Marshal(&locks)
+			if err != nil {
+				return err
+			}
+			return bucket.Put([]byte(repo), data)
+		}
+		return nil
+	})
+	return refreshed, err
+}
+
+// DeleteExpiredLocks removes the locks of every repo that have expired by
+// now, returning how many were removed.
+func (s *MetaStore) DeleteExpiredLocks(now time.Time) (int, error) {
+	removed := 0
+	err := s.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(locksBucket)
+		if bucket == nil {
+			return errNoBucket
+		}
+
+		updates := make(map[string][]Lock)
+		err := bucket.ForEach(func(k, v []byte) error {
+			var locks []Lock
+			if err := json.Unmarshal(v, &locks); err != nil {
+				return err
+			}
+
+			live := liveLocks(append([]Lock(nil), locks...), now)
+			if len(live) != len(locks) {
+				removed += len(locks) - len(live)
+				updates[string(k)] = live
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for repo, locks := range updates {
+			if len(locks) == 0 {
+				if err := bucket.Delete([]byte(repo)); err != nil {
+					return err
+				}
+				continue
+			}
+
+			data, err := json.Marshal(&locks)
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put([]byte(repo), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return removed, err
+}
+
+// LocksByPath returns the repo's locks that apply to ref indexed by their
+// normalized path.
+func (s *MetaStore) LocksByPath(repo, ref string) (map[string]Lock, error) {
+	locks, err := s.Locks(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	byPath := make(map[string]Lock, len(locks))
+	for _, l := range locks {
+		if l.AppliesTo(ref) {
+			byPath[normalizeLockPath(l.Path)] = l
+		}
+	}
+	return byPath, nil
+}
+
+// FilteredLocks return filtered locks for the repo
+func (s *MetaStore) FilteredLocks(repo, path, ref, cursor, limit string) (locks []Lock, next string, err error) {
+	locks, err = s.Locks(repo)
+	if err != nil {
+		return
+	}
+
+	if cursor != "" {
+		lastSeen := -1
+		for i, l := range locks {
+			if l.Id == cursor {
+				lastSeen = i
+				break
+			}
+		}
+
+		if lastSeen > -1 {
+			locks = locks[lastSeen:]
+		} else {
+			err = fmt.Errorf("cursor (%s) not found", cursor)
+			return
+		}
+	}
+
+	if path != "" {
+		path = normalizeLockPath(path)
+		var filtered []Lock
+		for _, l := range locks {
+			if normalizeLockPath(l.Path) == path {
+				filtered = append(filtered, l)
+			}
+		}
+
+		locks = filtered
+	}
+
+	if ref != "" {
+		var filtered []Lock
+		for _, l := range locks {
+			if l.AppliesTo(ref) {
+				filtered = append(filtered, l)
+			}
+		}
+
+		locks = filtered
+	}
+
+	if limit != "" {
+		var size int
+		size, err = strconv.Atoi(limit)
+		if err != nil || size < 0 {
+			locks = make([]Lock, 0)
+			err = fmt.Errorf("Invalid limit amount: %s", limit)
+			return
+		}
+
+		size = int(math.Min(float64(size), float64(len(locks))))
+		if size+1 < len(locks) {
+			next = locks[size].Id
+		}
+		locks = locks[:size]
+	}
+
+	return locks, next, nil
+}
+
+// DeleteLock removes lock for the repo by id from the store
+func (s *MetaStore) DeleteLock(repo, user, id string, force bool) (*Lock, error) {
+	var deleted *Lock
+	err := s.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(locksBucket)
+		if bucket == nil {
+			return errNoBucket
+		}
+
+		var locks []Lock
+		data := bucket.Get([]byte(repo))
+		if data != nil {
+			if err := json.Unmarshal(data, &locks); err != nil {
+				return err
+			}
+		}
+		newLocks := make([]Lock, 0, len(locks))
+
+		var lock Lock
+		for _, l := range locks {
+			if l.Id == id {
+				if l.Owner.Name != user && !force {
+					return errNotOwner
+				}
+				lock = l
+			} else if len(l.Id) > 0 {
+				newLocks = append(newLocks, l)
+			}
+		}
 		if lock.Id == "" {
 			return nil
 		}
@@ -303,20 +904,40 @@ func (c LocksByCreatedAt) Len() int           { return len(c) }
 func (c LocksByCreatedAt) Less(i, j int) bool { return c[i].LockedAt.Before(c[j].LockedAt) }
 func (c LocksByCreatedAt) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
 
-// Close closes the underlying boltdb.
+// Close flushes any buffered writes and closes the underlying boltdb.
 func (s *MetaStore) Close() {
+	if s.buffer != nil {
+		if err := s.buffer.close(); err != nil {
+			logger.Log(kv{"fn": "Close", "err": "Could not flush meta writes: " + err.Error()})
+		}
+	}
+	if s.access != nil {
+		if err := s.access.close(); err != nil {
+			logger.Log(kv{"fn": "Close", "err": "Could not flush access times: " + err.Error()})
+		}
+	}
+
+	// Wait for a running compaction, which swaps s.db
+	s.compactMu.Lock()
+	defer s.compactMu.Unlock()
 	s.db.Close()
 }
 
 // AddUser adds user credentials to the meta store.
+// User names are case insensitive and stored lower cased. It returns
+// errUserExists if a user with the same name in any case already exists.
 func (s *MetaStore) AddUser(user, pass string) error {
-	err := s.db.Update(func(tx *bolt.Tx) error {
+	err := s.update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket(usersBucket)
 		if bucket == nil {
 			return errNoBucket
 		}
 
-		err := bucket.Put([]byte(user), []byte(pass))
+		if userKey(bucket, user) != nil {
+			return errUserExists
+		}
+
+		err := bucket.Put([]byte(normalizeUser(user)), []byte(pass))
 		if err != nil {
 			return err
 		}
@@ -326,21 +947,364 @@ func (s *MetaStore) AddUser(user, pass string) error {
 	return err
 }
 
+// PutUser adds user credentials to the meta store, or changes the password of
+// the user if they already exist under the name in any case. It returns true
+// if the user was added.
+func (s *MetaStore) PutUser(user, pass string) (bool, error) {
+	var created bool
+	err := s.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(usersBucket)
+		if bucket == nil {
+			return errNoBucket
+		}
+
+		key := userKey(bucket, user)
+		if key == nil {
+			key = []byte(normalizeUser(user))
+			created = true
+		}
+		return bucket.Put(key, []byte(pass))
+	})
+
+	return created, err
+}
+
+// HasUser returns true if a user with the name in any case exists.
+func (s *MetaStore) HasUser(user string) (bool, error) {
+	var found bool
+	err := s.view(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(usersBucket)
+		if bucket == nil {
+			return errNoBucket
+		}
+
+		found = userKey(bucket, user) != nil
+		return nil
+	})
+
+	return found, err
+}
+
+// UserName returns the stored name of a user matching user in any case, or
+// "" if there is no such user.
+func (s *MetaStore) UserName(user string) (string, error) {
+	var name string
+	err := s.view(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(usersBucket)
+		if bucket == nil {
+			return errNoBucket
+		}
+
+		name = string(userKey(bucket, user))
+		return nil
+	})
+
+	return name, err
+}
+
 // DeleteUser removes user credentials from the meta store.
 func (s *MetaStore) DeleteUser(user string) error {
-	err := s.db.Update(func(tx *bolt.Tx) error {
+	err := s.update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket(usersBucket)
 		if bucket == nil {
 			return errNoBucket
 		}
 
-		err := bucket.Delete([]byte(user))
+		key := userKey(bucket, user)
+		if key == nil {
+			return nil
+		}
+
+		err := bucket.Delete(key)
 		return err
 	})
 
 	return err
 }
 
+// MergeDuplicateUsers merges users whose names only differ by case into a
+// single lower cased user, returning the merged names. The password of the
+// user already stored lower cased is kept, otherwise the first one in key
+// order. Locks owned by any of the duplicates are reassigned to the merged
+// user.
+func (s *MetaStore) MergeDuplicateUsers() ([]string, error) {
+	var merged []string
+
+	err := s.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(usersBucket)
+		if bucket == nil {
+			return errNoBucket
+		}
+
+		groups := make(map[string][][]byte)
+		var names []string
+		bucket.ForEach(func(k, v []byte) error {
+			name := normalizeUser(string(k))
+			if _, ok := groups[name]; !ok {
+				names = append(names, name)
+			}
+			groups[name] = append(groups[name], append([]byte(nil), k...))
+			return nil
+		})
+
+		for _, name := range names {
+			keys := groups[name]
+			if len(keys) == 1 && string(keys[0]) == name {
+				continue
+			}
+
+			pass := bucket.Get(keys[0])
+			if p := bucket.Get([]byte(name)); p != nil {
+				pass = p
+			}
+			pass = append([]byte(nil), pass...)
+
+			for _, k := range keys {
+				if err := bucket.Delete(k); err != nil {
+					return err
+				}
+			}
+			if err := bucket.Put([]byte(name), pass); err != nil {
+				return err
+			}
+			merged = append(merged, name)
+		}
+
+		if len(merged) == 0 {
+			return nil
+		}
+		return renameLockOwners(tx, func(owner string) (string, bool) {
+			name := normalizeUser(owner)
+			if name == owner {
+				return "", false
+			}
+			for _, m := range merged {
+				if m == name {
+					return name, true
+				}
+			}
+			return "", false
+		})
+	})
+
+	return merged, err
+}
+
+// RenameUser renames a user, keeping their password. Locks they own are
+// reassigned to the new name, and references to repositories under their name
+// are moved to the new name, in the same transaction. It returns
+// errUserExists if another user already has the new name.
+func (s *MetaStore) RenameUser(user, name string) error {
+	if err := s.Flush(); err != nil {
+		return err
+	}
+
+	old := normalizeUser(user)
+	name = normalizeUser(name)
+
+	return s.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(usersBucket)
+		if bucket == nil {
+			return errNoBucket
+		}
+
+		key := userKey(bucket, user)
+		if key == nil {
+			return errUserNotFound
+		}
+		if k := userKey(bucket, name); k != nil && !bytes.Equal(k, key) {
+			return errUserExists
+		}
+
+		pass := append([]byte(nil), bucket.Get(key)...)
+		if err := bucket.Delete(key); err != nil {
+			return err
+		}
+		if err := bucket.Put([]byte(name), pass); err != nil {
+			return err
+		}
+
+		err := renameLockOwners(tx, func(owner string) (string, bool) {
+			return name, normalizeUser(owner) == old
+		})
+		if err != nil {
+			return err
+		}
+		if err := renameObjectRefs(tx, old+"/", name+"/"); err != nil {
+			return err
+		}
+		return renameUploader(tx, old, name)
+	})
+}
+
+// renameUploader moves the objects uploaded by old to name.
+func renameUploader(tx *bolt.Tx, old, name string) error {
+	uploaders := tx.Bucket(uploadersBucket)
+	objects := tx.Bucket(objectsBucket)
+	if uploaders == nil || objects == nil {
+		return errNoBucket
+	}
+
+	from := uploaders.Bucket([]byte(old))
+	if from == nil || old == name {
+		return nil
+	}
+
+	var oids [][]byte
+	from.ForEach(func(k, v []byte) error {
+		oids = append(oids, append([]byte(nil), k...))
+		return nil
+	})
+
+	to, err := uploaders.CreateBucketIfNotExists([]byte(name))
+	if err != nil {
+		return err
+	}
+
+	for _, oid := range oids {
+		if err := to.Put(oid, []byte{}); err != nil {
+			return err
+		}
+
+		value := objects.Get(oid)
+		if len(value) == 0 {
+			continue
+		}
+		var meta MetaObject
+		if err := gob.NewDecoder(bytes.NewBuffer(value)).Decode(&meta); err != nil {
+			return err
+		}
+		meta.Uploader = name
+
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(meta); err != nil {
+			return err
+		}
+		if err := objects.Put(oid, buf.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	return uploaders.DeleteBucket([]byte(old))
+}
+
+// renameObjectRefs rewrites the references of every object to repositories
+// under prefix to be under replacement instead.
+func renameObjectRefs(tx *bolt.Tx, prefix, replacement string) error {
+	bucket := tx.Bucket(objectsBucket)
+	if bucket == nil {
+		return errNoBucket
+	}
+
+	updates := make(map[string][]byte)
+	err := bucket.ForEach(func(k, v []byte) error {
+		var meta MetaObject
+		if err := gob.NewDecoder(bytes.NewBuffer(v)).Decode(&meta); err != nil {
+			return err
+		}
+
+		changed := false
+		for i, ref := range meta.Refs {
+			if len(ref) > len(prefix) && strings.EqualFold(ref[:len(prefix)], prefix) {
+				meta.Refs[i] = replacement + ref[len(prefix):]
+				changed = true
+			}
+		}
+		if !changed {
+			return nil
+		}
+
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(meta); err != nil {
+			return err
+		}
+		updates[string(k)] = buf.Bytes()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for oid, data := range updates {
+		if err := bucket.Put([]byte(oid), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renameLockOwners rewrites the owner of every lock for which rename returns
+// true.
+func renameLockOwners(tx *bolt.Tx, rename func(string) (string, bool)) error {
+	bucket := tx.Bucket(locksBucket)
+	if bucket == nil {
+		return errNoBucket
+	}
+
+	updates := make(map[string][]byte)
+	err := bucket.ForEach(func(k, v []byte) error {
+		var locks []Lock
+		if err := json.Unmarshal(v, &locks); err != nil {
+			return err
+		}
+
+		changed := false
+		for i, l := range locks {
+			if name, ok := rename(l.Owner.Name); ok {
+				locks[i].Owner.Name = name
+				changed = true
+			}
+		}
+		if !changed {
+			return nil
+		}
+
+		data, err := json.Marshal(&locks)
+		if err != nil {
+			return err
+		}
+		updates[string(k)] = data
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for repo, data := range updates {
+		if err := bucket.Put([]byte(repo), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// userKey returns the key the user is stored under, or nil if there is no such
+// user. Users stored before names were normalized may have mixed case keys,
+// so those are matched too.
+func userKey(bucket *bolt.Bucket, user string) []byte {
+	if bucket.Get([]byte(user)) != nil {
+		return []byte(user)
+	}
+
+	name := normalizeUser(user)
+	if bucket.Get([]byte(name)) != nil {
+		return []byte(name)
+	}
+
+	var key []byte
+	bucket.ForEach(func(k, v []byte) error {
+		if key == nil && normalizeUser(string(k)) == name {
+			key = append([]byte(nil), k...)
+		}
+		return nil
+	})
+	return key
+}
+
+func normalizeUser(user string) string {
+	return strings.ToLower(user)
+}
+
 // MetaUser encapsulates information about a meta store user
 type MetaUser struct {
 	Name string
@@ -350,7 +1314,7 @@ type MetaUser struct {
 func (s *MetaStore) Users() ([]*MetaUser, error) {
 	var users []*MetaUser
 
-	err := s.db.View(func(tx *bolt.Tx) error {
+	err := s.view(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket(usersBucket)
 		if bucket == nil {
 			return errNoBucket
@@ -366,36 +1330,228 @@ func (s *MetaStore) Users() ([]*MetaUser, error) {
 	return users, err
 }
 
+// Flags returns the values of the feature flags that are set.
+func (s *MetaStore) Flags() (map[string]string, error) {
+	flags := make(map[string]string)
+
+	err := s.view(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(flagsBucket)
+		if bucket == nil {
+			return errNoBucket
+		}
+
+		return bucket.ForEach(func(k, v []byte) error {
+			flags[string(k)] = string(v)
+			return nil
+		})
+	})
+
+	return flags, err
+}
+
+// SetFlag stores the value of a feature flag, or clears it if value is empty.
+func (s *MetaStore) SetFlag(name, value string) error {
+	return s.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(flagsBucket)
+		if bucket == nil {
+			return errNoBucket
+		}
+
+		if value == "" {
+			return bucket.Delete([]byte(name))
+		}
+		return bucket.Put([]byte(name), []byte(value))
+	})
+}
+
+// ObjectsByUploader returns the MetaObjects uploaded by user, looked up in the
+// uploader index.
+func (s *MetaStore) ObjectsByUploader(user string) ([]*MetaObject, error) {
+	if err := s.Flush(); err != nil {
+		return nil, err
+	}
+
+	var objects []*MetaObject
+
+	err := s.view(func(tx *bolt.Tx) error {
+		uploaders := tx.Bucket(uploadersBucket)
+		bucket := tx.Bucket(objectsBucket)
+		if uploaders == nil || bucket == nil {
+			return errNoBucket
+		}
+
+		oids := uploaders.Bucket([]byte(normalizeUser(user)))
+		if oids == nil {
+			return nil
+		}
+
+		return oids.ForEach(func(k, v []byte) error {
+			value := bucket.Get(k)
+			if len(value) == 0 {
+				return nil
+			}
+			var meta MetaObject
+			if err := gob.NewDecoder(bytes.NewBuffer(value)).Decode(&meta); err != nil {
+				return err
+			}
+			objects = append(objects, &meta)
+			return nil
+		})
+	})
+
+	return objects, err
+}
+
+// indexUploader adds an object to the uploader index.
+func indexUploader(tx *bolt.Tx, meta *MetaObject) error {
+	if meta.Uploader == "" {
+		return nil
+	}
+
+	uploaders := tx.Bucket(uploadersBucket)
+	if uploaders == nil {
+		return errNoBucket
+	}
+
+	oids, err := uploaders.CreateBucketIfNotExists([]byte(normalizeUser(meta.Uploader)))
+	if err != nil {
+		return err
+	}
+	return oids.Put([]byte(meta.Oid), []byte{})
+}
+
+// unindexUploader removes an object from the uploader index.
+func unindexUploader(tx *bolt.Tx, meta *MetaObject) error {
+	if meta.Uploader == "" {
+		return nil
+	}
+
+	uploaders := tx.Bucket(uploadersBucket)
+	if uploaders == nil {
+		return errNoBucket
+	}
+
+	if oids := uploaders.Bucket([]byte(normalizeUser(meta.Uploader))); oids != nil {
+		return oids.Delete([]byte(meta.Oid))
+	}
+	return nil
+}
+
 // Objects returns all MetaObjects in the meta store
 func (s *MetaStore) Objects() ([]*MetaObject, error) {
 	var objects []*MetaObject
 
-	err := s.db.View(func(tx *bolt.Tx) error {
+	err := s.ForEachObject(func(meta *MetaObject) error {
+		objects = append(objects, meta)
+		return nil
+	})
+
+	return objects, err
+}
+
+// ForEachObject calls fn with each MetaObject in the meta store, decoding one
+// object at a time. Iteration stops at the first error fn returns, which is
+// then returned. All objects are read in a single read transaction, so fn
+// should not block for long.
+func (s *MetaStore) ForEachObject(fn func(*MetaObject) error) error {
+	if err := s.Flush(); err != nil {
+		return err
+	}
+
+	return s.view(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket(objectsBucket)
 		if bucket == nil {
 			return errNoBucket
 		}
 
-		bucket.ForEach(func(k, v []byte) error {
+		return bucket.ForEach(func(k, v []byte) error {
 			var meta MetaObject
 			dec := gob.NewDecoder(bytes.NewBuffer(v))
-			err := dec.Decode(&meta)
-			if err != nil {
+			if err := dec.Decode(&meta); err != nil {
 				return err
 			}
-			objects = append(objects, &meta)
-			return nil
+			return fn(&meta)
 		})
-		return nil
 	})
+}
 
+// ObjectsAfter returns up to n MetaObjects in oid order, starting with the
+// first oid after the given one, or with the first object when after is empty.
+// Objects pending delete are skipped, as they are by Get. Each call is its own
+// short read transaction, so callers that page through the store this way can
+// take their time with each page.
+func (s *MetaStore) ObjectsAfter(after string, n int) ([]*MetaObject, error) {
+	if err := s.Flush(); err != nil {
+		return nil, err
+	}
+
+	var objects []*MetaObject
+	err := s.view(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(objectsBucket)
+		if bucket == nil {
+			return errNoBucket
+		}
+
+		c := bucket.Cursor()
+		k, v := c.Seek([]byte(after))
+		if k != nil && string(k) == after {
+			k, v = c.Next()
+		}
+		for ; k != nil && len(objects) < n; k, v = c.Next() {
+			var meta MetaObject
+			dec := gob.NewDecoder(bytes.NewBuffer(v))
+			if err := dec.Decode(&meta); err != nil {
+				return err
+			}
+			if meta.PendingDelete {
+				continue
+			}
+			objects = append(objects, &meta)
+		}
+		return nil
+	})
 	return objects, err
 }
 
+// LockCountByOwner returns how many live locks user holds across all repos.
+func (s *MetaStore) LockCountByOwner(user string) (int, error) {
+	var count int
+	err := s.view(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(locksBucket)
+		if bucket == nil {
+			return errNoBucket
+		}
+
+		var err error
+		count, err = countLocksByOwner(bucket, user, time.Now())
+		return err
+	})
+	return count, err
+}
+
+// countLocksByOwner counts the locks of user in the locks bucket that are
+// live at now.
+func countLocksByOwner(bucket *bolt.Bucket, user string, now time.Time) (int, error) {
+	var count int
+	err := bucket.ForEach(func(k, v []byte) error {
+		var locks []Lock
+		if err := json.Unmarshal(v, &locks); err != nil {
+			return err
+		}
+		for _, l := range liveLocks(locks, now) {
+			if l.Owner.Name == user {
+				count++
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
 // AllLocks return all locks in the store, lock path is prepended with repo
 func (s *MetaStore) AllLocks() ([]Lock, error) {
 	var locks []Lock
-	err := s.db.View(func(tx *bolt.Tx) error {
+	err := s.view(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket(locksBucket)
 		if bucket == nil {
 			return errNoBucket
@@ -418,6 +1574,41 @@ func (s *MetaStore) AllLocks() ([]Lock, error) {
 	return locks, err
 }
 
+// SetAdmin stores the admin user with a bcrypt hash of their password,
+// replacing any previously stored admin.
+func (s *MetaStore) SetAdmin(user string, hash []byte) error {
+	return s.update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(adminsBucket); err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+
+		bucket, err := tx.CreateBucket(adminsBucket)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(user), hash)
+	})
+}
+
+// Admin returns the stored admin user and their password hash. The user is
+// empty if no admin has been stored.
+func (s *MetaStore) Admin() (user string, hash []byte, err error) {
+	err = s.view(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(adminsBucket)
+		if bucket == nil {
+			return errNoBucket
+		}
+
+		k, v := bucket.Cursor().First()
+		if k != nil {
+			user = string(k)
+			hash = append([]byte(nil), v...)
+		}
+		return nil
+	})
+	return
+}
+
 // Authenticate authorizes user with password and returns the user name
 func (s *MetaStore) Authenticate(user, password string) (string, bool) {
 	// check admin
@@ -429,13 +1620,16 @@ func (s *MetaStore) Authenticate(user, password string) (string, bool) {
 
 	value := ""
 
-	s.db.View(func(tx *bolt.Tx) error {
+	s.view(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket(usersBucket)
 		if bucket == nil {
 			return errNoBucket
 		}
 
-		value = string(bucket.Get([]byte(user)))
+		if key := userKey(bucket, user); key != nil {
+			user = string(key)
+			value = string(bucket.Get(key))
+		}
 		return nil
 	})
 
@@ -1,10 +1,15 @@
 package main
 
 import (
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/boltdb/bolt"
 )
 
 var (
@@ -65,6 +70,418 @@ func TestPutMeta(t *testing.T) {
 	}
 }
 
+func TestSetPinned(t *testing.T) {
+	setupMeta()
+	defer teardownMeta()
+
+	if _, err := metaStoreTest.SetPinned(contentOid, true); err != nil {
+		t.Fatalf("expected SetPinned to succeed, got : %s", err)
+	}
+
+	meta, err := metaStoreTest.Get(&RequestVars{Oid: contentOid})
+	if err != nil {
+		t.Fatalf("Error retreiving meta: %s", err)
+	}
+	if !meta.Pinned {
+		t.Errorf("expected meta to be pinned")
+	}
+	if meta.Size != contentSize {
+		t.Errorf("expected size to be preserved, got: %d", meta.Size)
+	}
+
+	if _, err := metaStoreTest.SetPinned(nonExistingOid, true); err != errObjectNotFound {
+		t.Errorf("expected errObjectNotFound, got : %v", err)
+	}
+}
+
+func TestRefs(t *testing.T) {
+	setupMeta()
+	defer teardownMeta()
+
+	oid := "7c0aed8016e763ff435a19cf186f76863140143ff726ae8a75555209fd6c4415"
+	for _, repo := range []string{"one", "two", "one"} {
+		if _, err := metaStoreTest.Put(&RequestVars{Oid: oid, Size: 12, User: "bilbo", Repo: repo}); err != nil {
+			t.Fatalf("expected put to succeed, got: %s", err)
+		}
+	}
+
+	meta, err := metaStoreTest.Get(&RequestVars{Oid: oid})
+	if err != nil {
+		t.Fatalf("error retrieving meta: %s", err)
+	}
+	if meta.RefCount() != 2 || !meta.HasRef("bilbo/one") || !meta.HasRef("bilbo/two") {
+		t.Fatalf("expected references from both repos, got: %v", meta.Refs)
+	}
+
+	meta, err = metaStoreTest.ReleaseRef(oid, "bilbo/one")
+	if err != nil {
+		t.Fatalf("expected release to succeed, got: %s", err)
+	}
+	if meta.RefCount() != 1 || !meta.HasRef("bilbo/two") {
+		t.Errorf("expected only bilbo/two to reference the object, got: %v", meta.Refs)
+	}
+}
+
+func TestSetName(t *testing.T) {
+	setupMeta()
+	defer teardownMeta()
+
+	for _, tc := range []struct{ name, expected string }{
+		{"logo.png", "logo.png"},
+		{"assets/logo.png", "logo.png"},
+		{`..\assets\logo.png`, "logo.png"},
+		{"/", ""},
+	} {
+		meta, err := metaStoreTest.SetName(contentOid, tc.name)
+		if err != nil {
+			t.Fatalf("expected name to be set, got: %s", err)
+		}
+		if meta.Name != tc.expected {
+			t.Errorf("expected name %q for %q, got %q", tc.expected, tc.name, meta.Name)
+		}
+	}
+
+	meta, err := metaStoreTest.Get(&RequestVars{Oid: contentOid})
+	if err != nil {
+		t.Fatalf("error retrieving meta: %s", err)
+	}
+	if meta.Filename() != contentOid {
+		t.Errorf("expected unnamed object's file name to be its oid, got %s", meta.Filename())
+	}
+}
+
+func TestLabels(t *testing.T) {
+	setupMeta()
+	defer teardownMeta()
+
+	if _, err := metaStoreTest.AddLabels(contentOid, "release-2.0", "asset-pack", "release-2.0"); err != nil {
+		t.Fatalf("expected AddLabels to succeed, got : %s", err)
+	}
+
+	meta, err := metaStoreTest.Get(&RequestVars{Oid: contentOid})
+	if err != nil {
+		t.Fatalf("Error retreiving meta: %s", err)
+	}
+	if len(meta.Labels) != 2 || !meta.HasLabel("release-2.0") || !meta.HasLabel("asset-pack") {
+		t.Errorf("expected both labels once, got: %v", meta.Labels)
+	}
+
+	if _, err := metaStoreTest.RemoveLabels(contentOid, "release-2.0"); err != nil {
+		t.Fatalf("expected RemoveLabels to succeed, got : %s", err)
+	}
+
+	meta, err = metaStoreTest.Get(&RequestVars{Oid: contentOid})
+	if err != nil {
+		t.Fatalf("Error retreiving meta: %s", err)
+	}
+	if len(meta.Labels) != 1 || meta.HasLabel("release-2.0") {
+		t.Errorf("expected label to be removed, got: %v", meta.Labels)
+	}
+}
+
+func TestObjectsByUploader(t *testing.T) {
+	setupMeta()
+	defer teardownMeta()
+
+	oids := make(map[string]string)
+	for _, data := range []string{"ring", "sword", "map"} {
+		oid := fmt.Sprintf("%x", sha256.Sum256([]byte(data)))
+		uploader := testUser
+		if data == "map" {
+			uploader = "Gandalf"
+		}
+		if _, err := metaStoreTest.Put(&RequestVars{Oid: oid, Size: int64(len(data)), Uploader: uploader}); err != nil {
+			t.Fatalf("error putting object: %s", err)
+		}
+		oids[data] = oid
+	}
+
+	uploaded := func(user string) map[string]bool {
+		objects, err := metaStoreTest.ObjectsByUploader(user)
+		if err != nil {
+			t.Fatalf("error listing objects by uploader: %s", err)
+		}
+		found := make(map[string]bool)
+		for _, meta := range objects {
+			if !strings.EqualFold(meta.Uploader, user) {
+				t.Errorf("expected objects uploaded by %s, got one by %s", user, meta.Uploader)
+			}
+			found[meta.Oid] = true
+		}
+		return found
+	}
+
+	if found := uploaded(testUser); len(found) != 2 || !found[oids["ring"]] || !found[oids["sword"]] {
+		t.Errorf("expected the objects uploaded by %s, got %v", testUser, found)
+	}
+	if found := uploaded("gandalf"); len(found) != 1 || !found[oids["map"]] {
+		t.Errorf("expected uploaders to match case insensitively, got %v", found)
+	}
+	if found := uploaded("frodo"); len(found) != 0 {
+		t.Errorf("expected no objects for an uploader without any, got %v", found)
+	}
+
+	if err := metaStoreTest.Delete(&RequestVars{Oid: oids["sword"]}); err != nil {
+		t.Fatalf("error deleting object: %s", err)
+	}
+	if found := uploaded(testUser); len(found) != 1 || !found[oids["ring"]] {
+		t.Errorf("expected deleted objects to leave the index, got %v", found)
+	}
+
+	if err := metaStoreTest.RenameUser(testUser, "baggins"); err != nil {
+		t.Fatalf("error renaming user: %s", err)
+	}
+	if found := uploaded(testUser); len(found) != 0 {
+		t.Errorf("expected no objects under the old name, got %v", found)
+	}
+	if found := uploaded("baggins"); len(found) != 1 || !found[oids["ring"]] {
+		t.Errorf("expected objects to follow the renamed user, got %v", found)
+	}
+}
+
+func TestObjectsCreatedBetween(t *testing.T) {
+	setupMeta()
+	defer teardownMeta()
+
+	start := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+	created := map[string]time.Time{
+		"before": start.Add(-time.Second),
+		"start":  start,
+		"last":   end.Add(-time.Nanosecond),
+		"end":    end,
+		"later":  end.Add(time.Hour),
+	}
+
+	oids := make(map[string]string)
+	for name, at := range created {
+		oid := fmt.Sprintf("%x", sha256.Sum256([]byte(name)))
+		if _, err := metaStoreTest.Put(&RequestVars{Oid: oid, Size: 1}); err != nil {
+			t.Fatalf("error putting object: %s", err)
+		}
+		at := at
+		if _, err := metaStoreTest.updateObject(oid, func(meta *MetaObject) { meta.CreatedAt = &at }); err != nil {
+			t.Fatalf("error setting creation time: %s", err)
+		}
+		oids[oid] = name
+	}
+
+	objects, err := metaStoreTest.ObjectsCreatedBetween(start, end)
+	if err != nil {
+		t.Fatalf("error querying objects: %s", err)
+	}
+	found := make(map[string]bool)
+	for _, meta := range objects {
+		found[oids[meta.Oid]] = true
+	}
+	if len(found) != 2 || !found["start"] || !found["last"] {
+		t.Errorf("expected the window to include its start and exclude its end, got %v", found)
+	}
+}
+
+func TestAccessTrackingCoalesces(t *testing.T) {
+	setupMeta()
+	defer teardownMeta()
+
+	// Long interval so nothing is flushed in the background
+	metaStoreTest.EnableAccessTracking(time.Hour)
+
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		metaStoreTest.Touch(contentOid, start.Add(time.Duration(i)*time.Millisecond))
+	}
+	metaStoreTest.Touch(nonExistingOid, start)
+
+	if n := len(metaStoreTest.access.pending); n != 2 {
+		t.Fatalf("expected accesses to be coalesced into 2 pending updates, got %d", n)
+	}
+
+	meta, err := metaStoreTest.Get(&RequestVars{Oid: contentOid})
+	if err != nil {
+		t.Fatalf("error getting object: %s", err)
+	}
+	if meta.LastAccessedAt != nil {
+		t.Errorf("expected access time to not be written before a flush")
+	}
+
+	if err := metaStoreTest.access.flush(); err != nil {
+		t.Fatalf("expected flush to succeed, got: %s", err)
+	}
+	if n := len(metaStoreTest.access.pending); n != 0 {
+		t.Errorf("expected no pending updates after a flush, got %d", n)
+	}
+
+	meta, err = metaStoreTest.Get(&RequestVars{Oid: contentOid})
+	if err != nil {
+		t.Fatalf("error getting object: %s", err)
+	}
+	last := start.Add(99 * time.Millisecond)
+	if meta.LastAccessedAt == nil || !meta.LastAccessedAt.Equal(last) {
+		t.Errorf("expected last access time %s, got %v", last, meta.LastAccessedAt)
+	}
+	if meta.Downloads != 100 {
+		t.Errorf("expected 100 downloads to be counted, got %d", meta.Downloads)
+	}
+
+	if _, err := metaStoreTest.Get(&RequestVars{Oid: nonExistingOid}); err != errObjectNotFound {
+		t.Errorf("expected accessing a missing object to not create it, got: %v", err)
+	}
+
+	// An older access does not move the time back
+	metaStoreTest.Touch(contentOid, start)
+	metaStoreTest.access.flush()
+	meta, _ = metaStoreTest.Get(&RequestVars{Oid: contentOid})
+	if meta.LastAccessedAt == nil || !meta.LastAccessedAt.Equal(last) {
+		t.Errorf("expected last access time to stay %s, got %v", last, meta.LastAccessedAt)
+	}
+	if meta.Downloads != 101 {
+		t.Errorf("expected the older access to be counted, got %d", meta.Downloads)
+	}
+}
+
+func TestForEachObject(t *testing.T) {
+	setupMeta()
+	defer teardownMeta()
+
+	for i := 0; i < 10; i++ {
+		if _, err := metaStoreTest.Put(&RequestVars{Oid: fmt.Sprintf("%064d", i), Size: int64(i)}); err != nil {
+			t.Fatalf("expected put to succeed, got : %s", err)
+		}
+	}
+
+	seen := make(map[string]bool)
+	err := metaStoreTest.ForEachObject(func(meta *MetaObject) error {
+		seen[meta.Oid] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected ForEachObject to succeed, got: %s", err)
+	}
+	// The seeded content object plus the ten above
+	if len(seen) != 11 {
+		t.Errorf("expected 11 objects, got %d", len(seen))
+	}
+
+	// Objects are handed over one by one, so iteration can stop early
+	stop := errors.New("stop")
+	calls := 0
+	err = metaStoreTest.ForEachObject(func(meta *MetaObject) error {
+		calls++
+		if calls == 3 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop {
+		t.Errorf("expected the callback error to be returned, got: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected iteration to stop after 3 objects, got %d", calls)
+	}
+}
+
+func TestObjectsAfter(t *testing.T) {
+	setupMeta()
+	defer teardownMeta()
+
+	for i := 0; i < 10; i++ {
+		if _, err := metaStoreTest.Put(&RequestVars{Oid: fmt.Sprintf("%064d", i), Size: int64(i)}); err != nil {
+			t.Fatalf("expected put to succeed, got : %s", err)
+		}
+	}
+	if _, err := metaStoreTest.MarkPendingDelete(fmt.Sprintf("%064d", 3)); err != nil {
+		t.Fatalf("expected mark to succeed, got: %s", err)
+	}
+
+	// The seeded content object plus the nine above not pending delete, four
+	// at a time
+	seen := make(map[string]bool)
+	after, pages := "", 0
+	for {
+		objects, err := metaStoreTest.ObjectsAfter(after, 4)
+		if err != nil {
+			t.Fatalf("expected ObjectsAfter to succeed, got: %s", err)
+		}
+		pages++
+		for _, meta := range objects {
+			if seen[meta.Oid] {
+				t.Errorf("expected each object once, got %s again", meta.Oid)
+			}
+			seen[meta.Oid] = true
+		}
+		if len(objects) < 4 {
+			break
+		}
+		after = objects[len(objects)-1].Oid
+	}
+	if len(seen) != 10 || pages != 3 || seen[fmt.Sprintf("%064d", 3)] {
+		t.Errorf("expected 10 objects in 3 pages, got %d in %d", len(seen), pages)
+	}
+}
+func TestWriteBufferFlush(t *testing.T) {
+	setupMeta()
+	defer teardownMeta()
+
+	// Large size and interval so nothing is flushed in the background
+	metaStoreTest.EnableWriteBuffer(1000, time.Hour)
+
+	var oids []string
+	for i := 0; i < 50; i++ {
+		oid := fmt.Sprintf("%064d", i)
+		if _, err := metaStoreTest.Put(&RequestVars{Oid: oid, Size: int64(i)}); err != nil {
+			t.Fatalf("expected put to succeed, got : %s", err)
+		}
+		oids = append(oids, oid)
+	}
+
+	meta, err := metaStoreTest.Get(&RequestVars{Oid: oids[10]})
+	if err != nil {
+		t.Fatalf("expected buffered write to be readable, got : %s", err)
+	}
+	if meta.Size != 10 {
+		t.Errorf("expected sizes to match, got: %d", meta.Size)
+	}
+
+	metaStoreTest.Close()
+
+	store, err := NewMetaStore("test-meta-store.db")
+	if err != nil {
+		t.Fatalf("error reopening meta store: %s", err)
+	}
+	metaStoreTest = store
+
+	for i, oid := range oids {
+		meta, err := metaStoreTest.Get(&RequestVars{Oid: oid})
+		if err != nil {
+			t.Fatalf("expected flushed write for %s to persist, got : %s", oid, err)
+		}
+		if meta.Size != int64(i) {
+			t.Errorf("expected sizes to match, got: %d", meta.Size)
+		}
+	}
+}
+
+func BenchmarkPutMeta(b *testing.B) {
+	setupMeta()
+	defer teardownMeta()
+
+	for i := 0; i < b.N; i++ {
+		metaStoreTest.Put(&RequestVars{Oid: fmt.Sprintf("%064d", i), Size: 42})
+	}
+}
+
+func BenchmarkPutMetaBuffered(b *testing.B) {
+	setupMeta()
+	defer teardownMeta()
+
+	metaStoreTest.EnableWriteBuffer(500, 50*time.Millisecond)
+
+	for i := 0; i < b.N; i++ {
+		metaStoreTest.Put(&RequestVars{Oid: fmt.Sprintf("%064d", i), Size: 42})
+	}
+	metaStoreTest.Flush()
+}
+
 func TestLocks(t *testing.T) {
 	setupMeta()
 	defer teardownMeta()
@@ -85,6 +502,52 @@ func TestLocks(t *testing.T) {
 	}
 }
 
+func TestExpiredLocks(t *testing.T) {
+	setupMeta()
+	defer teardownMeta()
+
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+	expired := NewTestLock("expired", "path-expired", "user")
+	expired.ExpiresAt = &past
+	live := NewTestLock("live", "path-live", "user")
+	live.ExpiresAt = &future
+	if err := metaStoreTest.AddLocks(testRepo, expired, live, NewTestLock("forever", "path-forever", "user")); err != nil {
+		t.Fatalf("expected AddLocks to succeed, got : %s", err)
+	}
+
+	locks, err := metaStoreTest.Locks(testRepo)
+	if err != nil {
+		t.Fatalf("expected Locks to succeed, got : %s", err)
+	}
+	if len(locks) != 2 {
+		t.Errorf("expected the expired lock to be skipped, got: %v", locks)
+	}
+
+	if l, err := metaStoreTest.RefreshLock(testRepo, "user", "expired", future); err != nil || l != nil {
+		t.Errorf("expected an expired lock not to be refreshed, got: %v, %v", l, err)
+	}
+	if _, err := metaStoreTest.RefreshLock(testRepo, "other", "live", future); err != errNotOwner {
+		t.Errorf("expected errNotOwner refreshing another user's lock, got: %v", err)
+	}
+
+	removed, err := metaStoreTest.DeleteExpiredLocks(time.Now())
+	if err != nil {
+		t.Fatalf("expected DeleteExpiredLocks to succeed, got: %s", err)
+	}
+	if removed != 1 {
+		t.Errorf("expected 1 expired lock to be removed, got %d", removed)
+	}
+
+	removed, err = metaStoreTest.DeleteExpiredLocks(future.Add(time.Second))
+	if err != nil || removed != 1 {
+		t.Errorf("expected the live lock to be removed once expired, got: %d, %v", removed, err)
+	}
+	if locks, _ := metaStoreTest.Locks(testRepo); len(locks) != 1 || locks[0].Id != "forever" {
+		t.Errorf("expected only the lock without expiry to remain, got: %v", locks)
+	}
+}
+
 func TestFilteredLocks(t *testing.T) {
 	setupMeta()
 	defer teardownMeta()
@@ -98,7 +561,7 @@ func TestFilteredLocks(t *testing.T) {
 		t.Errorf("expected AddLocks to succeed, got : %s", err)
 	}
 
-	locks, next, err := metaStoreTest.FilteredLocks(testRepo, "", "", "3")
+	locks, next, err := metaStoreTest.FilteredLocks(testRepo, "", "", "", "3")
 	if err != nil {
 		t.Errorf("expected FilteredLocks to succeed, got : %s", err)
 	}
@@ -109,7 +572,7 @@ func TestFilteredLocks(t *testing.T) {
 		t.Errorf("expected next to exist")
 	}
 
-	locks, next, err = metaStoreTest.FilteredLocks(testRepo, "", next, "2")
+	locks, next, err = metaStoreTest.FilteredLocks(testRepo, "", "", next, "2")
 	if err != nil {
 		t.Errorf("expected FilteredLocks to succeed, got : %s", err)
 	}
@@ -130,7 +593,7 @@ func TestAddLocks(t *testing.T) {
 		t.Errorf("expected AddLocks to succeed, got : %s", err)
 	}
 
-	locks, _, err := metaStoreTest.FilteredLocks(testRepo, lock.Path, "", "1")
+	locks, _, err := metaStoreTest.FilteredLocks(testRepo, lock.Path, "", "", "1")
 	if err != nil {
 		t.Errorf("expected FilteredLocks to succeed, got : %s", err)
 	}
@@ -142,6 +605,100 @@ func TestAddLocks(t *testing.T) {
 	}
 }
 
+func TestCreateLocks(t *testing.T) {
+	setupMeta()
+	defer teardownMeta()
+
+	held := NewTestLock(lockId, lockPath, testUser)
+	if err := metaStoreTest.AddLocks(testRepo, held); err != nil {
+		t.Fatalf("expected AddLocks to succeed, got : %s", err)
+	}
+
+	locks := []Lock{
+		NewTestLock("new", "new-path", testUser),
+		NewTestLock("held", lockPath, testUser),
+		NewTestLock("twice", "new-path", testUser),
+	}
+	outcomes, err := metaStoreTest.CreateLocks(testRepo, 0, locks...)
+	if err != nil {
+		t.Fatalf("expected CreateLocks to succeed, got : %s", err)
+	}
+	if c := outcomes[0].Conflict; c != nil {
+		t.Errorf("expected the lock on a free path to be created, got a conflict with %+v", c)
+	}
+	if c := outcomes[1].Conflict; c == nil || c.Id != lockId {
+		t.Errorf("expected the held lock to conflict, got %+v", c)
+	}
+	if c := outcomes[2].Conflict; c == nil || c.Id != "new" {
+		t.Errorf("expected the earlier lock in the call to conflict, got %+v", c)
+	}
+
+	all, err := metaStoreTest.Locks(testRepo)
+	if err != nil {
+		t.Fatalf("expected Locks to succeed, got : %s", err)
+	}
+	if len(all) != 2 {
+		t.Errorf("expected 2 locks, got %d", len(all))
+	}
+}
+
+func TestCreateLocksLimit(t *testing.T) {
+	setupMeta()
+	defer teardownMeta()
+
+	if err := metaStoreTest.AddLocks("other-repo", NewTestLock("held", "held-path", testUser)); err != nil {
+		t.Fatalf("expected AddLocks to succeed, got : %s", err)
+	}
+
+	// Conflicts do not count against the limit, only created locks do
+	locks := []Lock{
+		NewTestLock("a", "path-a", testUser),
+		NewTestLock("again", "path-a", testUser),
+		NewTestLock("b", "path-b", testUser),
+		NewTestLock("c", "path-c", testUser),
+		NewTestLock("other", "path-other", "other"),
+	}
+	outcomes, err := metaStoreTest.CreateLocks(testRepo, 3, locks...)
+	if err != nil {
+		t.Fatalf("expected CreateLocks to succeed, got : %s", err)
+	}
+	expected := []struct{ conflict, overLimit bool }{{false, false}, {true, false}, {false, false}, {false, true}, {false, false}}
+	for i, e := range expected {
+		if (outcomes[i].Conflict != nil) != e.conflict || outcomes[i].OverLimit != e.overLimit {
+			t.Errorf("expected lock %s to have conflict %t and over limit %t, got %+v", locks[i].Id, e.conflict, e.overLimit, outcomes[i])
+		}
+	}
+
+	count, err := metaStoreTest.LockCountByOwner(testUser)
+	if err != nil || count != 3 {
+		t.Errorf("expected the user to hold 3 locks, got %d %v", count, err)
+	}
+}
+
+func TestLockCountByOwner(t *testing.T) {
+	setupMeta()
+	defer teardownMeta()
+
+	expired := NewTestLock("expired", "path-expired", testUser)
+	past := time.Now().Add(-time.Second)
+	expired.ExpiresAt = &past
+	locks := []Lock{NewTestLock("a", "path-a", testUser), NewTestLock("b", "path-b", "other"), expired}
+	if err := metaStoreTest.AddLocks(testRepo, locks...); err != nil {
+		t.Fatalf("expected AddLocks to succeed, got : %s", err)
+	}
+	if err := metaStoreTest.AddLocks("other-repo", NewTestLock("c", "path-c", testUser)); err != nil {
+		t.Fatalf("expected AddLocks to succeed, got : %s", err)
+	}
+
+	count, err := metaStoreTest.LockCountByOwner(testUser)
+	if err != nil {
+		t.Fatalf("expected LockCountByOwner to succeed, got : %s", err)
+	}
+	if count != 2 {
+		t.Errorf("expected the live locks in both repos to be counted, got %d", count)
+	}
+}
+
 func TestDeleteLock(t *testing.T) {
 	setupMeta()
 	defer teardownMeta()
@@ -215,6 +772,194 @@ func TestDeleteLockNonExisting(t *testing.T) {
 	}
 }
 
+func TestAddUserCaseInsensitive(t *testing.T) {
+	setupMeta()
+	defer teardownMeta()
+
+	if err := metaStoreTest.AddUser("Frodo", "ring"); err != nil {
+		t.Fatalf("expected user to be added, got: %s", err)
+	}
+	if err := metaStoreTest.AddUser("FRODO", "other"); err != errUserExists {
+		t.Errorf("expected errUserExists, got: %v", err)
+	}
+
+	user, ok := metaStoreTest.Authenticate("fRoDo", "ring")
+	if !ok {
+		t.Fatalf("expected user to authenticate regardless of case")
+	}
+	if user != "frodo" {
+		t.Errorf("expected canonical user name frodo, got %s", user)
+	}
+
+	if err := metaStoreTest.DeleteUser("Frodo"); err != nil {
+		t.Fatalf("expected user to be deleted, got: %s", err)
+	}
+	if _, ok := metaStoreTest.Authenticate("frodo", "ring"); ok {
+		t.Errorf("expected deleted user to fail authentication")
+	}
+}
+
+func TestPutUser(t *testing.T) {
+	setupMeta()
+	defer teardownMeta()
+
+	created, err := metaStoreTest.PutUser("Sam", "rope")
+	if err != nil || !created {
+		t.Fatalf("expected user to be created, got %v %v", created, err)
+	}
+	if _, ok := metaStoreTest.Authenticate("sam", "rope"); !ok {
+		t.Errorf("expected created user to authenticate")
+	}
+
+	created, err = metaStoreTest.PutUser("SAM", "potatoes")
+	if err != nil || created {
+		t.Fatalf("expected user to be updated, got %v %v", created, err)
+	}
+	if _, ok := metaStoreTest.Authenticate("sam", "rope"); ok {
+		t.Errorf("expected the old password to stop working")
+	}
+	if _, ok := metaStoreTest.Authenticate("sam", "potatoes"); !ok {
+		t.Errorf("expected the new password to authenticate")
+	}
+
+	if err := metaStoreTest.AddUser("sam", "other"); err != errUserExists {
+		t.Errorf("expected AddUser to keep refusing existing users, got: %v", err)
+	}
+}
+
+func TestRenameUser(t *testing.T) {
+	setupMeta()
+	defer teardownMeta()
+
+	if err := metaStoreTest.AddLocks(testRepo, NewTestLock("bilbo-lock", "ring", testUser), NewTestLock("other-lock", "sword", "frodo")); err != nil {
+		t.Fatalf("error adding locks: %s", err)
+	}
+	if _, err := metaStoreTest.AddRef(&RequestVars{Oid: contentOid, User: testUser, Repo: "repo"}); err != nil {
+		t.Fatalf("error adding ref: %s", err)
+	}
+	if _, err := metaStoreTest.AddRef(&RequestVars{Oid: contentOid, User: "shire", Repo: "repo"}); err != nil {
+		t.Fatalf("error adding ref: %s", err)
+	}
+
+	if err := metaStoreTest.RenameUser(testUser, "Baggins"); err != nil {
+		t.Fatalf("expected rename to succeed, got: %s", err)
+	}
+
+	if _, ok := metaStoreTest.Authenticate("baggins", testPass); !ok {
+		t.Errorf("expected the renamed user to keep their password")
+	}
+	if _, ok := metaStoreTest.Authenticate(testUser, testPass); ok {
+		t.Errorf("expected the old name to be gone")
+	}
+
+	locks, err := metaStoreTest.Locks(testRepo)
+	if err != nil {
+		t.Fatalf("error listing locks: %s", err)
+	}
+	owners := make(map[string]string)
+	for _, l := range locks {
+		owners[l.Id] = l.Owner.Name
+	}
+	if owners["bilbo-lock"] != "baggins" {
+		t.Errorf("expected the user's lock to be reassigned, got owner %q", owners["bilbo-lock"])
+	}
+	if owners["other-lock"] != "frodo" {
+		t.Errorf("expected other locks to keep their owner, got %q", owners["other-lock"])
+	}
+
+	meta, err := metaStoreTest.Get(&RequestVars{Oid: contentOid})
+	if err != nil {
+		t.Fatalf("error getting object: %s", err)
+	}
+	if !meta.HasRef("baggins/repo") || meta.HasRef(testUser+"/repo") {
+		t.Errorf("expected the user's references to be renamed, got %v", meta.Refs)
+	}
+	if !meta.HasRef("shire/repo") {
+		t.Errorf("expected other references to be kept, got %v", meta.Refs)
+	}
+}
+
+func TestRenameUserCollision(t *testing.T) {
+	setupMeta()
+	defer teardownMeta()
+
+	if err := metaStoreTest.AddUser("frodo", "ring"); err != nil {
+		t.Fatalf("error adding user: %s", err)
+	}
+
+	if err := metaStoreTest.RenameUser(testUser, "Frodo"); err != errUserExists {
+		t.Errorf("expected renaming onto an existing user to fail, got: %v", err)
+	}
+	if _, ok := metaStoreTest.Authenticate(testUser, testPass); !ok {
+		t.Errorf("expected a failed rename to keep the user")
+	}
+	if err := metaStoreTest.RenameUser("nobody", "somebody"); err != errUserNotFound {
+		t.Errorf("expected renaming a missing user to fail, got: %v", err)
+	}
+}
+
+func TestMergeDuplicateUsers(t *testing.T) {
+	setupMeta()
+	defer teardownMeta()
+
+	// Users added before names were normalized
+	err := metaStoreTest.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(usersBucket)
+		for name, pass := range map[string]string{"Sam": "first", "sam": "kept", "SAM": "last", "Pippin": "took"} {
+			if err := bucket.Put([]byte(name), []byte(pass)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("error seeding users: %s", err)
+	}
+	if err := metaStoreTest.AddLocks("repo", NewTestLock("sam-lock", "a/path", "Sam")); err != nil {
+		t.Fatalf("error adding lock: %s", err)
+	}
+
+	merged, err := metaStoreTest.MergeDuplicateUsers()
+	if err != nil {
+		t.Fatalf("expected merge to succeed, got: %s", err)
+	}
+	if len(merged) != 2 || merged[0] != "pippin" || merged[1] != "sam" {
+		t.Fatalf("expected pippin and sam to be merged, got: %v", merged)
+	}
+
+	users, err := metaStoreTest.Users()
+	if err != nil {
+		t.Fatalf("error listing users: %s", err)
+	}
+	names := make(map[string]bool)
+	for _, u := range users {
+		names[u.Name] = true
+	}
+	if len(users) != 3 || !names["sam"] || !names["pippin"] || !names[testUser] {
+		t.Errorf("expected users %s, pippin and sam, got: %v", testUser, names)
+	}
+
+	if _, ok := metaStoreTest.Authenticate("sam", "kept"); !ok {
+		t.Errorf("expected the lower cased user's password to be kept")
+	}
+	if _, ok := metaStoreTest.Authenticate("Pippin", "took"); !ok {
+		t.Errorf("expected pippin to keep their password")
+	}
+
+	locks, _, err := metaStoreTest.FilteredLocks("repo", "", "", "", "")
+	if err != nil {
+		t.Fatalf("error listing locks: %s", err)
+	}
+	if len(locks) != 1 || locks[0].Owner.Name != "sam" {
+		t.Errorf("expected lock to be owned by sam, got: %v", locks)
+	}
+
+	merged, err = metaStoreTest.MergeDuplicateUsers()
+	if err != nil || len(merged) != 0 {
+		t.Errorf("expected nothing left to merge, got: %v, %v", merged, err)
+	}
+}
+
 func NewTestLock(id, path, user string) Lock {
 	return Lock{
 		Id:   id,
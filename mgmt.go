@@ -1,10 +1,13 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"html/template"
 	"io"
 	"net/http"
+	"sort"
 	"strings"
 
 	rice "github.com/GeertJohan/go.rice"
@@ -17,23 +20,70 @@ var (
 )
 
 type pageData struct {
-	Name    string
-	Config  *Configuration
-	Users   []*MetaUser
-	Objects []*MetaObject
-	Locks   []Lock
-	Oid     string
+	Name      string
+	BasePath  string
+	Config    *Configuration
+	Users     []*MetaUser
+	Objects   []*MetaObject
+	Locks     []Lock
+	Oid       string
+	Histogram []*sizeBucket
+	Uploads   []*Upload
+	Uploader  string
+	Flags     []FlagState
+	Banner    string
+}
+
+// sizeBucket counts the objects whose size falls in [Min, Max). Max is zero
+// for the last, unbounded bucket.
+type sizeBucket struct {
+	Label   string `json:"label"`
+	Min     int64  `json:"min"`
+	Max     int64  `json:"max,omitempty"`
+	Count   int    `json:"count"`
+	Percent int    `json:"-"`
 }
 
 func (a *App) addMgmt(r *mux.Router) {
 	r.HandleFunc("/mgmt", basicAuth(a.indexHandler)).Methods("GET")
 	r.HandleFunc("/mgmt/objects", basicAuth(a.objectsHandler)).Methods("GET")
+	r.HandleFunc("/mgmt/objects/del", basicAuth(a.deleteObjectsHandler)).Methods("POST")
+	r.HandleFunc("/mgmt/objects/del/range", basicAuth(a.deleteRangeHandler)).Methods("POST")
+	r.HandleFunc("/mgmt/api/object/{oid}", basicAuth(a.objectAPIHandler)).Methods("GET")
+	r.HandleFunc("/mgmt/object/{oid}", basicAuth(a.patchObjectHandler)).Methods("PATCH")
 	r.HandleFunc("/mgmt/object/del/{oid}", basicAuth(a.deleteObjectHandler)).Methods("GET")
+	r.HandleFunc("/mgmt/object/pin/{oid}", basicAuth(a.pinObjectHandler)).Methods("POST")
+	r.HandleFunc("/mgmt/object/unpin/{oid}", basicAuth(a.unpinObjectHandler)).Methods("POST")
+	r.HandleFunc("/mgmt/object/exempt/{oid}", basicAuth(a.exemptObjectHandler)).Methods("POST")
+	r.HandleFunc("/mgmt/object/unexempt/{oid}", basicAuth(a.unexemptObjectHandler)).Methods("POST")
+	r.HandleFunc("/mgmt/object/approve/{oid}", basicAuth(a.approveObjectHandler)).Methods("POST")
+	r.HandleFunc("/mgmt/object/share/{oid}", basicAuth(a.shareObjectHandler)).Methods("POST")
+	r.HandleFunc("/mgmt/object/label/{oid}", basicAuth(a.labelObjectHandler)).Methods("POST")
+	r.HandleFunc("/mgmt/object/unlabel/{oid}", basicAuth(a.unlabelObjectHandler)).Methods("POST")
 	r.HandleFunc("/mgmt/raw/{oid}", basicAuth(a.objectsRawHandler)).Methods("GET")
+	r.HandleFunc("/mgmt/histogram", basicAuth(a.histogramHandler)).Methods("GET")
+	r.HandleFunc("/mgmt/api/objects", basicAuth(a.objectsAPIHandler)).Methods("GET")
+	r.HandleFunc("/mgmt/api/objects/stream", basicAuth(a.objectsStreamHandler)).Methods("GET")
+	r.HandleFunc("/mgmt/api/register", basicAuth(a.registerHandler)).Methods("POST")
+	r.HandleFunc("/mgmt/api/compact", basicAuth(a.compactHandler)).Methods("POST")
+	r.HandleFunc("/mgmt/api/cleanup-candidates", basicAuth(a.cleanupCandidatesHandler)).Methods("GET")
+	r.HandleFunc("/mgmt/api/batch/diff", basicAuth(a.batchDiffHandler)).Methods("POST")
+	r.HandleFunc("/mgmt/api/histogram", basicAuth(a.histogramAPIHandler)).Methods("GET")
 	r.HandleFunc("/mgmt/locks", basicAuth(a.locksHandler)).Methods("GET")
 	r.HandleFunc("/mgmt/users", basicAuth(a.usersHandler)).Methods("GET")
 	r.HandleFunc("/mgmt/add", basicAuth(a.addUserHandler)).Methods("POST")
 	r.HandleFunc("/mgmt/del", basicAuth(a.delUserHandler)).Methods("POST")
+	r.HandleFunc("/mgmt/uploads", basicAuth(a.uploadsHandler)).Methods("GET")
+	r.HandleFunc("/mgmt/api/uploads", basicAuth(a.uploadsAPIHandler)).Methods("GET")
+	r.HandleFunc("/mgmt/upload/cancel/{oid}", basicAuth(a.cancelUploadHandler)).Methods("POST")
+	r.HandleFunc("/mgmt/flags", basicAuth(a.flagsHandler)).Methods("GET")
+	r.HandleFunc("/mgmt/flags", basicAuth(a.setFlagHandler)).Methods("POST")
+	r.HandleFunc("/mgmt/api/flags", basicAuth(a.flagsAPIHandler)).Methods("GET", "POST")
+	r.HandleFunc("/mgmt/logs/stream", basicAuth(a.logStreamHandler)).Methods("GET")
+	r.HandleFunc("/mgmt/users/rename", basicAuth(a.renameUserHandler)).Methods("POST")
+	r.HandleFunc("/mgmt/users/merge", basicAuth(a.mergeUsersHandler)).Methods("POST")
+	r.HandleFunc("/mgmt/users/{name}", basicAuth(a.putUserHandler)).Methods("PUT")
+	r.HandleFunc("/mgmt/api/users/{name}/impact", basicAuth(a.userImpactHandler)).Methods("GET")
 
 	cssBox = rice.MustFindBox("mgmt/css")
 	templateBox = rice.MustFindBox("mgmt/templates")
@@ -59,15 +109,12 @@ func checkBasicAuth(user string, pass string, ok bool) bool {
 		return false
 	}
 
-	if user != Config.AdminUser || pass != Config.AdminPass {
-		return false
-	}
-	return true
+	return checkAdmin(user, pass)
 }
 
 func basicAuth(h http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if Config.AdminUser == "" || Config.AdminPass == "" {
+		if !isAdminEnabled() {
 			writeStatus(w, r, 404, false)
 			return
 		}
@@ -91,23 +138,155 @@ func basicAuth(h http.HandlerFunc) http.HandlerFunc {
 }
 
 func (a *App) indexHandler(w http.ResponseWriter, r *http.Request) {
-	if err := render(w, "config.tmpl", pageData{Name: "index", Config: Config}); err != nil {
+	if err := a.render(w, "config.tmpl", pageData{Name: "index", Config: Config}); err != nil {
 		writeStatus(w, r, 404, false)
 	}
 }
 
 func (a *App) objectsHandler(w http.ResponseWriter, r *http.Request) {
-	objects, err := a.metaStore.Objects()
+	objects, err := a.filteredObjects(r)
 	if err != nil {
 		fmt.Fprintf(w, "Error retrieving objects: %s", err)
 		return
 	}
 
-	if err := render(w, "objects.tmpl", pageData{Name: "objects", Objects: objects}); err != nil {
+	users, err := a.metaStore.Users()
+	if err != nil {
+		fmt.Fprintf(w, "Error retrieving users: %s", err)
+		return
+	}
+
+	data := pageData{Name: "objects", Objects: objects, Users: users, Uploader: r.FormValue("uploader")}
+	if err := a.render(w, "objects.tmpl", data); err != nil {
 		writeStatus(w, r, 404, false)
 	}
 }
 
+// filteredObjects returns the objects uploaded by the ?uploader= user, or all
+// objects, keeping those with the ?label= label if one is given.
+func (a *App) filteredObjects(r *http.Request) ([]*MetaObject, error) {
+	var objects []*MetaObject
+	var err error
+	if uploader := r.FormValue("uploader"); uploader != "" {
+		objects, err = a.metaStore.ObjectsByUploader(uploader)
+	} else {
+		objects, err = a.metaStore.Objects()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if label := r.FormValue("label"); label != "" {
+		objects = filterObjectsByLabel(objects, label)
+	}
+	return objects, nil
+}
+
+// objectsAPIHandler lists objects as JSON. With ?sort=last_accessed the least
+// recently downloaded objects come first, starting with those never downloaded.
+func (a *App) objectsAPIHandler(w http.ResponseWriter, r *http.Request) {
+	objects, err := a.filteredObjects(r)
+	if err != nil {
+		writeStatus(w, r, metaErrorStatus(err, 500), false)
+		return
+	}
+
+	if objects == nil {
+		objects = []*MetaObject{}
+	}
+
+	if r.FormValue("sort") == "last_accessed" {
+		sort.SliceStable(objects, func(i, j int) bool {
+			ai, aj := objects[i].LastAccessedAt, objects[j].LastAccessedAt
+			if ai == nil || aj == nil {
+				return ai == nil && aj != nil
+			}
+			return ai.Before(*aj)
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(objects)
+}
+
+// objectsStreamPage is how many objects objectsStreamHandler reads from the
+// store at a time.
+const objectsStreamPage = 100
+
+// objectsStreamHandler writes every object as newline delimited JSON, so that
+// large stores are listed in constant memory. Objects are read a page at a
+// time and written once the page's transaction is done, so a slow client does
+// not hold a transaction open.
+func (a *App) objectsStreamHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+
+	after := ""
+	for {
+		objects, err := a.metaStore.ObjectsAfter(after, objectsStreamPage)
+		if err != nil {
+			logger.Log(kv{"fn": "objectsStreamHandler", "err": "Could not stream objects: " + err.Error()})
+			return
+		}
+		for _, meta := range objects {
+			if err := enc.Encode(meta); err != nil {
+				logger.Log(kv{"fn": "objectsStreamHandler", "err": "Could not stream objects: " + err.Error()})
+				return
+			}
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if len(objects) < objectsStreamPage {
+			return
+		}
+		after = objects[len(objects)-1].Oid
+	}
+}
+
+func (a *App) uploadsHandler(w http.ResponseWriter, r *http.Request) {
+	uploads, err := a.contentStore.Uploads()
+	if err != nil {
+		fmt.Fprintf(w, "Error retrieving uploads: %s", err)
+		return
+	}
+
+	if err := a.render(w, "uploads.tmpl", pageData{Name: "uploads", Uploads: uploads}); err != nil {
+		writeStatus(w, r, 404, false)
+	}
+}
+
+func (a *App) uploadsAPIHandler(w http.ResponseWriter, r *http.Request) {
+	uploads, err := a.contentStore.Uploads()
+	if err != nil {
+		writeStatus(w, r, 500, false)
+		return
+	}
+	if uploads == nil {
+		uploads = []*Upload{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(uploads)
+}
+
+func (a *App) cancelUploadHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	if err := a.contentStore.CancelUpload(vars["oid"]); err != nil {
+		if err == errFileNotExist {
+			writeStatus(w, r, 404, false)
+			return
+		}
+		writeStatus(w, r, 500, false)
+		return
+	}
+
+	writeSuccess(w)
+}
+
 func (a *App) objectsRawHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	rv := &RequestVars{Oid: vars["oid"]}
@@ -125,11 +304,108 @@ func (a *App) objectsRawHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer content.Close()
 
-	w.Header().Set("Content-Type", "application/octet-stream")
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s;", vars["oid"]))
+	var body io.Reader = content
+	contentType := "application/octet-stream"
+	disposition := "attachment"
+
+	// ?inline=1 lets admins preview images and text in the browser. Only
+	// types that cannot run script are shown inline, anything else, like
+	// HTML, is downloaded as usual.
+	if r.FormValue("inline") == "1" {
+		sniff := make([]byte, 512)
+		n, err := io.ReadFull(content, sniff)
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			writeStatus(w, r, 500, false)
+			return
+		}
+
+		body = io.MultiReader(bytes.NewReader(sniff[:n]), content)
+		if sniffed := http.DetectContentType(sniff[:n]); isInlinePreview(sniffed) {
+			contentType = sniffed
+			disposition = "inline"
+		}
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	w.Header().Set("Content-Disposition", contentDisposition(disposition, meta))
 	w.Header().Set("Content-Transfer-Encoding", "binary")
 	w.Header().Set("Content-Length", fmt.Sprintf("%d", meta.Size))
-	io.Copy(w, content)
+	io.Copy(w, body)
+}
+
+// isInlinePreview returns true if content of the sniffed type may be shown
+// inline by objectsRawHandler.
+func isInlinePreview(contentType string) bool {
+	switch strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0]) {
+	case "image/png", "image/jpeg", "image/gif", "image/webp", "text/plain":
+		return true
+	}
+	return false
+}
+
+func (a *App) histogramHandler(w http.ResponseWriter, r *http.Request) {
+	objects, err := a.metaStore.Objects()
+	if err != nil {
+		fmt.Fprintf(w, "Error retrieving objects: %s", err)
+		return
+	}
+
+	histogram := sizeHistogram(objects, Config.SizeBucketBounds())
+	if err := a.render(w, "histogram.tmpl", pageData{Name: "histogram", Histogram: histogram}); err != nil {
+		writeStatus(w, r, 404, false)
+	}
+}
+
+func (a *App) histogramAPIHandler(w http.ResponseWriter, r *http.Request) {
+	objects, err := a.metaStore.Objects()
+	if err != nil {
+		writeStatus(w, r, 500, false)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sizeHistogram(objects, Config.SizeBucketBounds()))
+}
+
+// sizeHistogram counts objects into buckets split at the given sorted bounds,
+// in a single pass over objects.
+func sizeHistogram(objects []*MetaObject, bounds []int64) []*sizeBucket {
+	buckets := make([]*sizeBucket, len(bounds)+1)
+	var min int64
+	for i, max := range bounds {
+		label := fmt.Sprintf("%s-%s", formatBytes(min), formatBytes(max))
+		if i == 0 {
+			label = "<" + formatBytes(max)
+		}
+		buckets[i] = &sizeBucket{Label: label, Min: min, Max: max}
+		min = max
+	}
+	buckets[len(bounds)] = &sizeBucket{Label: ">=" + formatBytes(min), Min: min}
+
+	for _, o := range objects {
+		i := sort.Search(len(bounds), func(i int) bool { return o.Size < bounds[i] })
+		buckets[i].Count++
+	}
+
+	for _, b := range buckets {
+		if len(objects) > 0 {
+			b.Percent = b.Count * 100 / len(objects)
+		}
+	}
+
+	return buckets
+}
+
+func formatBytes(n int64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	i := 0
+	for n >= 1024 && n%1024 == 0 && i < len(units)-1 {
+		n /= 1024
+		i++
+	}
+	return fmt.Sprintf("%d%s", n, units[i])
 }
 
 func (a *App) locksHandler(w http.ResponseWriter, r *http.Request) {
@@ -139,7 +415,7 @@ func (a *App) locksHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := render(w, "locks.tmpl", pageData{Name: "locks", Locks: locks}); err != nil {
+	if err := a.render(w, "locks.tmpl", pageData{Name: "locks", Locks: locks}); err != nil {
 		writeStatus(w, r, 404, false)
 	}
 }
@@ -151,7 +427,7 @@ func (a *App) usersHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := render(w, "users.tmpl", pageData{Name: "users", Users: users}); err != nil {
+	if err := a.render(w, "users.tmpl", pageData{Name: "users", Users: users}); err != nil {
 		writeStatus(w, r, 404, false)
 	}
 }
@@ -165,11 +441,84 @@ func (a *App) addUserHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := a.metaStore.AddUser(user, pass); err != nil {
+		if err == errUserExists {
+			w.WriteHeader(409)
+		}
 		fmt.Fprintf(w, "Error adding user: %s", err)
 		return
 	}
 
-	http.Redirect(w, r, "/mgmt/users", 302)
+	http.Redirect(w, r, Config.BasePrefix()+"/mgmt/users", 302)
+}
+
+// putUserHandler creates the user in the path with the password in the form,
+// or changes their password if they already exist. It responds 201 when the
+// user was created and 200 when they were updated.
+func (a *App) putUserHandler(w http.ResponseWriter, r *http.Request) {
+	user := mux.Vars(r)["name"]
+	pass := r.FormValue("password")
+	if pass == "" {
+		w.WriteHeader(400)
+		fmt.Fprint(w, "Invalid username or password")
+		return
+	}
+
+	created, err := a.metaStore.PutUser(user, pass)
+	if err != nil {
+		w.WriteHeader(metaErrorStatus(err, 500))
+		fmt.Fprintf(w, "Error saving user: %s", err)
+		return
+	}
+
+	if created {
+		w.WriteHeader(201)
+	}
+}
+
+// UserImpact summarizes what is tied to a user, for admins to review before
+// deleting them. Objects and locks are kept when a user is deleted, but lose
+// the user they were uploaded and held by.
+type UserImpact struct {
+	User    string `json:"user"`
+	Objects int    `json:"objects"`
+	Size    int64  `json:"size"`
+	Locks   int    `json:"locks"`
+}
+
+// userImpactHandler returns the UserImpact of deleting the user in the path.
+func (a *App) userImpactHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	user := normalizeUser(mux.Vars(r)["name"])
+	found, err := a.metaStore.HasUser(user)
+	if err == nil && !found {
+		err = errUserNotFound
+	}
+
+	impact := UserImpact{User: user}
+	var objects []*MetaObject
+	if err == nil {
+		objects, err = a.metaStore.ObjectsByUploader(user)
+	}
+	if err == nil {
+		impact.Locks, err = a.metaStore.LockCountByOwner(user)
+	}
+	if err == errUserNotFound {
+		w.WriteHeader(404)
+		fmt.Fprintf(w, `{"message":"%s"}`, err)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(metaErrorStatus(err, 500))
+		fmt.Fprintf(w, `{"message":"%s"}`, err)
+		return
+	}
+
+	for _, meta := range objects {
+		impact.Objects++
+		impact.Size += meta.Size
+	}
+	json.NewEncoder(w).Encode(impact)
 }
 
 func (a *App) delUserHandler(w http.ResponseWriter, r *http.Request) {
@@ -184,7 +533,50 @@ func (a *App) delUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	http.Redirect(w, r, "/mgmt/users", 302)
+	http.Redirect(w, r, Config.BasePrefix()+"/mgmt/users", 302)
+}
+
+// renameUserHandler renames a user, along with the locks and repository
+// references under their name.
+func (a *App) renameUserHandler(w http.ResponseWriter, r *http.Request) {
+	user := r.FormValue("name")
+	name := r.FormValue("new_name")
+	if user == "" || name == "" {
+		w.WriteHeader(400)
+		fmt.Fprint(w, "Invalid username")
+		return
+	}
+
+	if err := a.metaStore.RenameUser(user, name); err != nil {
+		switch err {
+		case errUserNotFound:
+			w.WriteHeader(404)
+		case errUserExists:
+			w.WriteHeader(409)
+		default:
+			w.WriteHeader(metaErrorStatus(err, 500))
+		}
+		fmt.Fprintf(w, "Error renaming user: %s", err)
+		return
+	}
+
+	http.Redirect(w, r, Config.BasePrefix()+"/mgmt/users", 302)
+}
+
+func (a *App) mergeUsersHandler(w http.ResponseWriter, r *http.Request) {
+	merged, err := a.metaStore.MergeDuplicateUsers()
+	if err != nil {
+		writeStatus(w, r, 500, false)
+		return
+	}
+	if merged == nil {
+		merged = []string{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Merged []string `json:"merged"`
+	}{merged})
 }
 
 // assumes there are no locks on the object
@@ -193,7 +585,7 @@ func (a *App) deleteObjectHandler(w http.ResponseWriter, r *http.Request) {
 	rv := &RequestVars{Oid: vars["oid"]}
 
 	// make sure object exists
-	_, err := a.metaStore.UnsafeGet(rv) // first param is meta
+	meta, err := a.metaStore.UnsafeGet(rv)
 	if err != nil {
 		writeStatus(w, r, 404, false)
 		return
@@ -201,24 +593,270 @@ func (a *App) deleteObjectHandler(w http.ResponseWriter, r *http.Request) {
 
 	// TODO: maybe delete lock on this file, if exists? see server.go::CreateLockHandler
 
-	err = a.contentStore.DeleteFile(rv.Oid)
+	if err := a.deleteObject(meta, r.FormValue("repo")); err != nil {
+		if err == errObjectPinned || err == errObjectShared {
+			writeStatus(w, r, 409, false)
+			return
+		}
+		writeStatus(w, r, 500, false)
+		return
+	}
+
+	writeSuccess(w)
+}
+
+// deleteObject removes the repository ref's reference to an object, and the
+// object's content and metadata once no references remain. Without a ref the
+// object is only removed if no more than one repository references it,
+// otherwise errObjectShared is returned, as it is for a ref the object is not
+// referenced by while other repositories reference it. Pinned objects are left
+// untouched and errObjectPinned is returned, so every delete path should go
+// through here. The checks are made against the stored object rather than
+// meta, so that references added since meta was read are respected.
+func (a *App) deleteObject(meta *MetaObject, ref string) error {
+	marked, err := a.metaStore.ReleaseForDelete(meta.Oid, ref)
+	if err != nil || !marked {
+		return err
+	}
+
+	return a.finishDelete(meta.Oid)
+}
+
+// finishDelete removes the content and then the metadata of an object marked
+// as pending delete. Content that is already gone was removed by an earlier,
+// interrupted attempt.
+func (a *App) finishDelete(oid string) error {
+	if err := a.contentStore.DeleteFile(oid); err != nil && err != errFileNotExist {
+		return err
+	}
+
+	return a.metaStore.Delete(&RequestVars{Oid: oid})
+}
+
+// recoverDeletes finishes the deletes that were interrupted after their
+// objects were marked as pending delete, returning the oids it deleted.
+func (a *App) recoverDeletes() ([]string, error) {
+	var pending []string
+	err := a.metaStore.ForEachObject(func(meta *MetaObject) error {
+		if meta.PendingDelete {
+			pending = append(pending, meta.Oid)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var deleted []string
+	for _, oid := range pending {
+		if err := a.finishDelete(oid); err != nil {
+			return deleted, err
+		}
+		deleted = append(deleted, oid)
+	}
+	return deleted, nil
+}
+
+// deleteObjectsHandler deletes every object carrying the label given in the
+// form. Pinned objects are skipped, as are objects shared by several
+// repositories.
+func (a *App) deleteObjectsHandler(w http.ResponseWriter, r *http.Request) {
+	label := r.FormValue("label")
+	if label == "" {
+		writeStatus(w, r, 400, false)
+		return
+	}
+
+	objects, err := a.metaStore.Objects()
 	if err != nil {
 		writeStatus(w, r, 500, false)
 		return
 	}
 
-	// delete the metadata
-	err = a.metaStore.Delete(rv)
+	result := struct {
+		Deleted []string `json:"deleted"`
+		Skipped []string `json:"skipped"`
+	}{Deleted: []string{}, Skipped: []string{}}
+
+	for _, meta := range filterObjectsByLabel(objects, label) {
+		if err := a.deleteObject(meta, ""); err != nil {
+			result.Skipped = append(result.Skipped, meta.Oid)
+			continue
+		}
+		result.Deleted = append(result.Deleted, meta.Oid)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func filterObjectsByLabel(objects []*MetaObject, label string) []*MetaObject {
+	var filtered []*MetaObject
+	for _, o := range objects {
+		if o.HasLabel(label) {
+			filtered = append(filtered, o)
+		}
+	}
+	return filtered
+}
+
+func (a *App) labelObjectHandler(w http.ResponseWriter, r *http.Request) {
+	a.updateLabels(w, r, a.metaStore.AddLabels)
+}
+
+func (a *App) unlabelObjectHandler(w http.ResponseWriter, r *http.Request) {
+	a.updateLabels(w, r, a.metaStore.RemoveLabels)
+}
+
+func (a *App) updateLabels(w http.ResponseWriter, r *http.Request, update func(string, ...string) (*MetaObject, error)) {
+	vars := mux.Vars(r)
+
+	label := strings.TrimSpace(r.FormValue("label"))
+	if label == "" {
+		writeStatus(w, r, 400, false)
+		return
+	}
+
+	if _, err := update(vars["oid"], label); err != nil {
+		if err == errObjectNotFound {
+			writeStatus(w, r, 404, false)
+			return
+		}
+		writeStatus(w, r, 500, false)
+		return
+	}
+
+	writeSuccess(w)
+}
+
+// objectAPIHandler returns an object's metadata as JSON.
+func (a *App) objectAPIHandler(w http.ResponseWriter, r *http.Request) {
+	meta, err := a.metaStore.UnsafeGet(&RequestVars{Oid: mux.Vars(r)["oid"]})
 	if err != nil {
+		writeStatus(w, r, metaErrorStatus(err, 404), false)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(meta)
+}
+
+// patchObjectHandler corrects an object's recorded labels, pin state or size.
+// A size is only accepted if it matches the stored content.
+func (a *App) patchObjectHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	oid := vars["oid"]
+
+	w.Header().Set("Content-Type", "application/json")
+
+	var update ObjectUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"message":"%s"}`, err)
+		return
+	}
+
+	if update.Size != nil {
+		size, err := a.contentStore.Size(oid)
+		if err != nil && err != errFileNotExist {
+			writeStatus(w, r, 500, false)
+			return
+		}
+		if err == errFileNotExist || size != *update.Size {
+			w.WriteHeader(http.StatusConflict)
+			fmt.Fprintf(w, `{"message":"%s"}`, errSizeMismatch)
+			return
+		}
+	}
+
+	meta, err := a.metaStore.UpdateObject(oid, &update)
+	if err != nil {
+		if err == errObjectNotFound {
+			writeStatus(w, r, 404, false)
+			return
+		}
+		writeStatus(w, r, metaErrorStatus(err, 500), false)
+		return
+	}
+
+	json.NewEncoder(w).Encode(meta)
+}
+
+func (a *App) pinObjectHandler(w http.ResponseWriter, r *http.Request) {
+	a.setPinned(w, r, true)
+}
+
+func (a *App) unpinObjectHandler(w http.ResponseWriter, r *http.Request) {
+	a.setPinned(w, r, false)
+}
+
+func (a *App) setPinned(w http.ResponseWriter, r *http.Request, pinned bool) {
+	vars := mux.Vars(r)
+
+	if _, err := a.metaStore.SetPinned(vars["oid"], pinned); err != nil {
+		if err == errObjectNotFound {
+			writeStatus(w, r, 404, false)
+			return
+		}
 		writeStatus(w, r, 500, false)
 		return
 	}
 
+	writeSuccess(w)
+}
+
+func (a *App) exemptObjectHandler(w http.ResponseWriter, r *http.Request) {
+	a.setExempt(w, r, true)
+}
+
+func (a *App) unexemptObjectHandler(w http.ResponseWriter, r *http.Request) {
+	a.setExempt(w, r, false)
+}
+
+func (a *App) setExempt(w http.ResponseWriter, r *http.Request, exempt bool) {
+	vars := mux.Vars(r)
+
+	if _, err := a.metaStore.SetExempt(vars["oid"], exempt); err != nil {
+		if err == errObjectNotFound {
+			writeStatus(w, r, 404, false)
+			return
+		}
+		writeStatus(w, r, metaErrorStatus(err, 500), false)
+		return
+	}
+
+	writeSuccess(w)
+}
+
+// approveObjectHandler clears an object's quarantine, or its grace period,
+// once it has been scanned
+func (a *App) approveObjectHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	if _, err := a.metaStore.Approve(vars["oid"]); err != nil {
+		if err == errObjectNotFound {
+			writeStatus(w, r, 404, false)
+			return
+		}
+		writeStatus(w, r, metaErrorStatus(err, 500), false)
+		return
+	}
+
+	writeSuccess(w)
+}
+
+func writeSuccess(w http.ResponseWriter) {
 	json := "{\"success\": \"true\"}"
 
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(json)))
-	fmt.Fprintf(w, json)
+	fmt.Fprint(w, json)
+}
+
+// render renders a mgmt page, showing the banner if one is set.
+func (a *App) render(w http.ResponseWriter, tmpl string, data pageData) error {
+	data.Banner = a.flags.Banner()
+	return render(w, tmpl, data)
 }
 
 func render(w http.ResponseWriter, tmpl string, data pageData) error {
@@ -232,6 +870,8 @@ func render(w http.ResponseWriter, tmpl string, data pageData) error {
 		return err
 	}
 
+	data.BasePath = Config.BasePrefix()
+
 	t := template.Must(template.New("main").Parse(bodyString))
 	t.New("content").Parse(contentString)
 
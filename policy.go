@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/context"
+)
+
+var errContentWithheld = errors.New("Object content is withheld, upgrade required")
+
+// isWithheld returns true if the download policy holds back the content of an
+// object at now. Objects over the size limit, or older than the age limit,
+// keep their metadata but are not served unless an admin exempted them.
+func isWithheld(meta *MetaObject, now time.Time) bool {
+	if meta.Exempt {
+		return false
+	}
+	if limit := Config.WithholdSizeLimit(); limit > 0 && meta.Size > limit {
+		return true
+	}
+	if age := Config.WithholdAgeLimit(); age > 0 && meta.CreatedAt != nil && now.Sub(*meta.CreatedAt) > age {
+		return true
+	}
+	return false
+}
+
+// isPendingScan returns true if an object is held back from downloads at now
+// until it is scanned, either because it is quarantined or because it was
+// uploaded less than the grace period ago and has not been approved yet.
+func isPendingScan(meta *MetaObject, now time.Time) bool {
+	if meta.Quarantined {
+		return true
+	}
+	grace := Config.GracePeriodDuration()
+	return grace > 0 && !meta.Approved && meta.CreatedAt != nil && now.Sub(*meta.CreatedAt) < grace
+}
+
+// writeWithheld answers a download of an object withheld by the policy.
+func writeWithheld(w http.ResponseWriter, r *http.Request) {
+	requestID, _ := context.Get(r, "RequestID").(string)
+
+	w.Header().Set("Content-Type", metaMediaType)
+	w.WriteHeader(402)
+	json.NewEncoder(w).Encode(struct {
+		Message   string `json:"message"`
+		RequestID string `json:"request_id,omitempty"`
+	}{errContentWithheld.Error(), requestID})
+
+	logRequest(r, 402)
+}
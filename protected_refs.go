@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/context"
+)
+
+var errRefProtected = errors.New("You do not have permission to push to this ref")
+
+// isProtectedRef returns true if ref matches one of the configured protected
+// ref patterns.
+func isProtectedRef(ref string) bool {
+	for _, pattern := range Config.ProtectedRefPatterns() {
+		if matchPathGlob(pattern, ref) {
+			return true
+		}
+	}
+	return false
+}
+
+// canPushRef returns true if the user of r may upload objects for ref. Only
+// the configured ref writers may push to protected refs, or push without
+// naming a ref while any ref is protected.
+func canPushRef(r *http.Request, ref string) bool {
+	if len(Config.ProtectedRefPatterns()) == 0 || ref != "" && !isProtectedRef(ref) {
+		return true
+	}
+	user, _ := context.Get(r, "USER").(string)
+	return Config.IsRefWriter(user)
+}
+
+// writeRefProtected answers an upload for a ref the user may not push to.
+func writeRefProtected(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", metaMediaType)
+	w.WriteHeader(403)
+	json.NewEncoder(w).Encode(struct {
+		Message string `json:"message"`
+	}{errRefProtected.Error()})
+	logRequest(r, 403)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// RegisterRequest lists content files that were placed in the content store
+// out of band and need metadata. With no objects the whole store is scanned.
+type RegisterRequest struct {
+	Objects []*RegisterEntry `json:"objects"`
+}
+
+// RegisterEntry is a content file to register. With a source the file is
+// first copied within the content store from that key, a path relative to the
+// store, e.g. when content is re-homed under its oid.
+type RegisterEntry struct {
+	Oid    string `json:"oid"`
+	Size   int64  `json:"size"`
+	Source string `json:"source,omitempty"`
+}
+
+// RegisterResult reports what happened to one entry of a RegisterRequest.
+type RegisterResult struct {
+	Oid     string `json:"oid"`
+	Size    int64  `json:"size"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// registerHandler creates metadata for content files already in the store.
+// Each file is checked against its oid and size first, or only its size if
+// RegisterVerify is "size", and entries whose content is missing or does not
+// match are skipped.
+func (a *App) registerHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req RegisterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"message":"%s"}`, err)
+		return
+	}
+
+	if len(req.Objects) == 0 {
+		objects, err := a.contentStore.Objects()
+		if err != nil {
+			writeStatus(w, r, 500, false)
+			return
+		}
+		for _, o := range objects {
+			req.Objects = append(req.Objects, &RegisterEntry{Oid: o.Oid, Size: o.Size})
+		}
+	}
+
+	results := make([]*RegisterResult, 0, len(req.Objects))
+	for _, e := range req.Objects {
+		results = append(results, a.register(e))
+	}
+
+	json.NewEncoder(w).Encode(results)
+}
+
+func (a *App) register(e *RegisterEntry) *RegisterResult {
+	result := &RegisterResult{Oid: e.Oid, Size: e.Size}
+
+	if e.Source != "" {
+		if err := a.contentStore.Copy(e.Source, e.Oid); err != nil {
+			result.Status = "skipped"
+			result.Message = err.Error()
+			return result
+		}
+	}
+
+	verify := a.contentStore.Verify
+	if Config.IsRegisterSizeOnly() {
+		verify = a.contentStore.VerifySize
+	}
+	if err := verify(e.Oid, e.Size); err != nil {
+		result.Status = "skipped"
+		result.Message = err.Error()
+		return result
+	}
+
+	meta, err := a.metaStore.Put(&RequestVars{Oid: e.Oid, Size: e.Size})
+	if err != nil {
+		result.Status = "failed"
+		result.Message = err.Error()
+		return result
+	}
+
+	if meta.Existing {
+		result.Status = "exists"
+		return result
+	}
+
+	if err := a.quarantine(e.Oid); err != nil {
+		a.metaStore.Delete(&RequestVars{Oid: e.Oid})
+		result.Status = "failed"
+		result.Message = err.Error()
+		return result
+	}
+
+	result.Status = "registered"
+	return result
+}
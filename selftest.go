@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+const selfTestRepo = "selftest"
+
+// runSelfTest opens the configured stores and runs SelfTest against them,
+// returning the process exit code.
+func runSelfTest() int {
+	metaStore, err := NewMetaStore(Config.MetaDB)
+	if err != nil {
+		fmt.Printf("FAIL open meta store: %s\n", err)
+		return 1
+	}
+	defer metaStore.Close()
+
+	contentStore, err := NewContentStore(Config.ContentPath)
+	if err != nil {
+		fmt.Printf("FAIL open content store: %s\n", err)
+		return 1
+	}
+
+	if !SelfTest(os.Stdout, metaStore, contentStore) {
+		return 1
+	}
+	return 0
+}
+
+type selfTestStep struct {
+	name string
+	run  func() error
+}
+
+// SelfTest runs a smoke test against the stores: it uploads a small object,
+// downloads and verifies it, creates and releases a lock, then deletes the
+// object. Each step is reported to w. It returns false if any step failed.
+func SelfTest(w io.Writer, metaStore *MetaStore, contentStore *ContentStore) bool {
+	data := []byte(fmt.Sprintf("lfs-test-server selftest %d", time.Now().UnixNano()))
+	rv := &RequestVars{Oid: fmt.Sprintf("%x", sha256.Sum256(data)), Size: int64(len(data))}
+	lock := Lock{Id: randomLockId(), Path: "selftest", Owner: User{Name: "selftest"}, LockedAt: time.Now()}
+
+	var meta *MetaObject
+	steps := []selfTestStep{
+		{"upload", func() error {
+			var err error
+			if meta, err = metaStore.Put(rv); err != nil {
+				return err
+			}
+			return contentStore.Put(meta, bytes.NewReader(data))
+		}},
+		{"download", func() error {
+			content, err := contentStore.Get(meta, 0)
+			if err != nil {
+				return err
+			}
+			defer content.Close()
+
+			by, err := ioutil.ReadAll(content)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(by, data) {
+				return errHashMismatch
+			}
+			return nil
+		}},
+		{"lock", func() error {
+			return metaStore.AddLocks(selfTestRepo, lock)
+		}},
+		{"unlock", func() error {
+			deleted, err := metaStore.DeleteLock(selfTestRepo, lock.Owner.Name, lock.Id, false)
+			if err == nil && deleted == nil {
+				err = fmt.Errorf("lock %s not found", lock.Id)
+			}
+			return err
+		}},
+		{"delete", func() error {
+			if err := contentStore.DeleteFile(rv.Oid); err != nil {
+				return err
+			}
+			return metaStore.Delete(rv)
+		}},
+	}
+
+	for _, step := range steps {
+		if err := step.run(); err != nil {
+			fmt.Fprintf(w, "FAIL %s: %s\n", step.name, err)
+			return false
+		}
+		fmt.Fprintf(w, "PASS %s\n", step.name)
+	}
+	return true
+}
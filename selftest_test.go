@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSelfTest(t *testing.T) {
+	metaStore, err := NewMetaStore("selftest-test.db")
+	if err != nil {
+		t.Fatalf("error creating meta store: %s", err)
+	}
+	defer os.Remove("selftest-test.db")
+	defer metaStore.Close()
+
+	contentStore, err := NewContentStore("selftest-content-test")
+	if err != nil {
+		t.Fatalf("error creating content store: %s", err)
+	}
+	defer os.RemoveAll("selftest-content-test")
+
+	var out bytes.Buffer
+	if !SelfTest(&out, metaStore, contentStore) {
+		t.Fatalf("expected selftest to pass, got:\n%s", out.String())
+	}
+
+	for _, step := range []string{"upload", "download", "lock", "unlock", "delete"} {
+		if !strings.Contains(out.String(), "PASS "+step+"\n") {
+			t.Errorf("expected %s step to pass, got:\n%s", step, out.String())
+		}
+	}
+
+	objects, err := metaStore.Objects()
+	if err != nil {
+		t.Fatalf("error listing objects: %s", err)
+	}
+	if len(objects) != 0 {
+		t.Errorf("expected selftest to clean up its object, got %d objects", len(objects))
+	}
+}
@@ -1,12 +1,22 @@
 package main
 
 import (
+	"bytes"
+	"crypto/md5"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"hash"
 	"io"
+	mathrand "math/rand"
+	"mime"
+	"mime/multipart"
 	"net"
 	"net/http"
+	"net/textproto"
+	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
@@ -25,19 +35,83 @@ type RequestVars struct {
 	Password      string
 	Repo          string
 	Authorization string
+	Uploader      string                     `json:"-"`
+	GitRef        string                     `json:"-"`
+	Extensions    map[string]json.RawMessage `json:"extensions,omitempty"`
 }
 
 type BatchVars struct {
 	Transfers []string       `json:"transfers,omitempty"`
 	Operation string         `json:"operation"`
 	Objects   []*RequestVars `json:"objects"`
+	Ref       *Ref           `json:"ref,omitempty"`
 }
 
 // MetaObject is object metadata as seen by the object and metadata stores.
 type MetaObject struct {
-	Oid      string `json:"oid"`
-	Size     int64  `json:"size"`
-	Existing bool
+	Oid            string                     `json:"oid"`
+	Size           int64                      `json:"size"`
+	Pinned         bool                       `json:"pinned"`
+	Labels         []string                   `json:"labels,omitempty"`
+	Name           string                     `json:"name,omitempty"`
+	Quarantined    bool                       `json:"quarantined"`
+	Refs           []string                   `json:"refs,omitempty"`
+	MD5            string                     `json:"md5,omitempty"`
+	LastAccessedAt *time.Time                 `json:"last_accessed_at,omitempty"`
+	Downloads      int64                      `json:"downloads"`
+	CreatedAt      *time.Time                 `json:"created_at,omitempty"`
+	Exempt         bool                       `json:"exempt"`
+	Uploader       string                     `json:"uploader,omitempty"`
+	PendingDelete  bool                       `json:"pending_delete,omitempty"`
+	Approved       bool                       `json:"approved,omitempty"`
+	Extensions     map[string]json.RawMessage `json:"extensions,omitempty"`
+	Existing       bool
+}
+
+// Filename returns the name the object was uploaded with, or its oid if it
+// was uploaded without one.
+func (m *MetaObject) Filename() string {
+	if m.Name != "" {
+		return m.Name
+	}
+	return m.Oid
+}
+
+// HasRef returns true if the repository ref references the object.
+func (m *MetaObject) HasRef(ref string) bool {
+	for _, r := range m.Refs {
+		if r == ref {
+			return true
+		}
+	}
+	return false
+}
+
+// HasExtensions returns true if the object already stores every extension in
+// ext with the same value.
+func (m *MetaObject) HasExtensions(ext map[string]json.RawMessage) bool {
+	for name, value := range ext {
+		if stored, ok := m.Extensions[name]; !ok || !bytes.Equal(stored, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// RefCount returns the number of repositories referencing the object. Objects
+// stored before references were recorded have none.
+func (m *MetaObject) RefCount() int {
+	return len(m.Refs)
+}
+
+// HasLabel returns true if the object is labeled with label.
+func (m *MetaObject) HasLabel(label string) bool {
+	for _, l := range m.Labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
 }
 
 type BatchResponse struct {
@@ -47,10 +121,11 @@ type BatchResponse struct {
 
 // Representation is object medata as seen by clients of the lfs server.
 type Representation struct {
-	Oid     string           `json:"oid"`
-	Size    int64            `json:"size"`
-	Actions map[string]*link `json:"actions"`
-	Error   *ObjectError     `json:"error,omitempty"`
+	Oid        string                     `json:"oid"`
+	Size       int64                      `json:"size"`
+	Actions    map[string]*link           `json:"actions"`
+	Extensions map[string]json.RawMessage `json:"extensions,omitempty"`
+	Error      *ObjectError               `json:"error,omitempty"`
 }
 
 type ObjectError struct {
@@ -63,14 +138,33 @@ type User struct {
 }
 
 type Lock struct {
-	Id       string    `json:"id"`
-	Path     string    `json:"path"`
-	Owner    User      `json:"owner"`
-	LockedAt time.Time `json:"locked_at"`
+	Id        string     `json:"id"`
+	Path      string     `json:"path"`
+	Owner     User       `json:"owner"`
+	LockedAt  time.Time  `json:"locked_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Ref       string     `json:"ref,omitempty"`
+}
+
+// Expired returns true if the lock has an expiry that has passed by now.
+func (l *Lock) Expired(now time.Time) bool {
+	return l.ExpiresAt != nil && !now.Before(*l.ExpiresAt)
+}
+
+// AppliesTo returns true if the lock holds on ref. Locks created without a
+// ref hold on every ref, and an empty ref matches every lock.
+func (l *Lock) AppliesTo(ref string) bool {
+	return l.Ref == "" || ref == "" || l.Ref == ref
+}
+
+// Ref is the git ref a lock request is made for.
+type Ref struct {
+	Name string `json:"name"`
 }
 
 type LockRequest struct {
 	Path string `json:"path"`
+	Ref  *Ref   `json:"ref,omitempty"`
 }
 
 type LockResponse struct {
@@ -78,6 +172,24 @@ type LockResponse struct {
 	Message string `json:"message,omitempty"`
 }
 
+// BatchLockRequest asks for several locks to be created at once.
+type BatchLockRequest struct {
+	Locks []LockRequest `json:"locks"`
+}
+
+// BatchLockResult is the lock created for a path, or the error that kept it
+// from being created.
+type BatchLockResult struct {
+	Path  string       `json:"path"`
+	Lock  *Lock        `json:"lock,omitempty"`
+	Error *ObjectError `json:"error,omitempty"`
+}
+
+type BatchLockResponse struct {
+	Locks   []BatchLockResult `json:"locks"`
+	Message string            `json:"message,omitempty"`
+}
+
 type UnlockRequest struct {
 	Force bool `json:"force"`
 }
@@ -93,11 +205,44 @@ type LockList struct {
 	Message    string `json:"message,omitempty"`
 }
 
+type WhoamiResponse struct {
+	Name  string       `json:"name"`
+	Role  string       `json:"role"`
+	Usage *WhoamiUsage `json:"usage,omitempty"`
+}
+
+// WhoamiUsage is how much a user has uploaded, counted from the objects the
+// uploader index records for them.
+type WhoamiUsage struct {
+	Objects int   `json:"objects"`
+	Size    int64 `json:"size"`
+}
+
 type VerifiableLockRequest struct {
+	Ref    *Ref   `json:"ref,omitempty"`
 	Cursor string `json:"cursor,omitempty"`
 	Limit  int    `json:"limit,omitempty"`
 }
 
+// VerifyPathsRequest asks which of a set of paths are locked, e.g. by a
+// pre-receive hook checking the paths a push touches.
+type VerifyPathsRequest struct {
+	Paths []string `json:"paths"`
+	Ref   *Ref     `json:"ref,omitempty"`
+}
+
+type PathLockStatus struct {
+	Path   string `json:"path"`
+	Locked bool   `json:"locked"`
+	Ours   bool   `json:"ours"`
+	Lock   *Lock  `json:"lock,omitempty"`
+}
+
+type VerifyPathsResponse struct {
+	Paths   []PathLockStatus `json:"paths"`
+	Message string           `json:"message,omitempty"`
+}
+
 type VerifiableLockList struct {
 	Ours       []Lock `json:"ours"`
 	Theirs     []Lock `json:"theirs"`
@@ -110,11 +255,15 @@ func (v *RequestVars) DownloadLink() string {
 	return v.internalLink("objects")
 }
 
-// UploadLink builds a URL to upload the object.
+// UploadLink builds a URL to upload the object. Links for a batch naming a
+// ref carry it, so that the upload is checked against protected refs too.
 func (v *RequestVars) UploadLink(useTus bool) string {
 	if useTus {
 		return v.tusLink()
 	}
+	if v.GitRef != "" {
+		return v.internalLink("objects") + "?ref=" + url.QueryEscape(v.GitRef)
+	}
 	return v.internalLink("objects")
 }
 
@@ -130,6 +279,7 @@ func (v *RequestVars) internalLink(subpath string) string {
 	}
 
 	path += fmt.Sprintf("/%s/%s", subpath, v.Oid)
+	path = Config.BasePrefix() + path
 
 	if Config.IsHTTPS() {
 		return fmt.Sprintf("%s://%s%s", Config.Scheme, Config.Host, path)
@@ -146,8 +296,14 @@ func (v *RequestVars) tusLink() string {
 	return link
 }
 
+// VerifyLink builds a URL to verify the upload of the object, at the
+// external verifier when one is configured.
 func (v *RequestVars) VerifyLink() string {
-	path := fmt.Sprintf("/verify/%s", v.Oid)
+	if Config.VerifyURL != "" {
+		return strings.TrimRight(Config.VerifyURL, "/") + "/" + v.Oid
+	}
+
+	path := Config.BasePrefix() + fmt.Sprintf("/verify/%s", v.Oid)
 
 	if Config.IsHTTPS() {
 		return fmt.Sprintf("%s://%s%s", Config.Scheme, Config.Host, path)
@@ -168,54 +324,131 @@ type App struct {
 	router       *mux.Router
 	contentStore *ContentStore
 	metaStore    *MetaStore
+	readLimit    *transferLimiter
+	writeLimit   *transferLimiter
+	mgmtRouter   *mux.Router
+	idempotency  *idempotencyCache
+	authorizer   Authorizer
+	flags        *featureFlags
+	shareKey     []byte
+	upstream     *upstreamCache
+	fills        fillGroup
+	contention   *lockContention
 }
 
 // NewApp creates a new App using the ContentStore and MetaStore provided
 func NewApp(content *ContentStore, meta *MetaStore) *App {
-	app := &App{contentStore: content, metaStore: meta}
+	app := &App{
+		contentStore: content,
+		metaStore:    meta,
+		readLimit:    newTransferLimiter(Config.MaxReadTransfers(), Config.TransferWaitTimeout()),
+		writeLimit:   newTransferLimiter(Config.MaxWriteTransfers(), Config.TransferWaitTimeout()),
+		idempotency:  newIdempotencyCache(Config.IdempotencyKeyTTL()),
+		authorizer:   newAuthorizer(),
+		flags:        newFeatureFlags(meta),
+		shareKey:     newShareKey(),
+		upstream:     newUpstreamCache(),
+		contention:   newLockContention(),
+	}
 
-	r := mux.NewRouter()
+	root := mux.NewRouter()
+	r := withBasePath(root)
 
 	r.HandleFunc("/{user}/{repo}/objects/batch", app.requireAuth(app.BatchHandler)).Methods("POST").MatcherFunc(MetaMatcher)
 
 	route := "/{user}/{repo}/objects/{oid}"
 	r.HandleFunc(route, app.requireAuth(app.GetContentHandler)).Methods("GET", "HEAD").MatcherFunc(ContentMatcher)
 	r.HandleFunc(route, app.requireAuth(app.GetMetaHandler)).Methods("GET", "HEAD").MatcherFunc(MetaMatcher)
-	r.HandleFunc(route, app.requireAuth(app.PutHandler)).Methods("PUT").MatcherFunc(ContentMatcher)
+	r.HandleFunc(route, app.requireAuth(app.GetMultipartHandler)).Methods("GET").MatcherFunc(MultipartMatcher)
+	r.HandleFunc(route, app.requireWrite(app.PutHandler)).Methods("PUT").MatcherFunc(ContentMatcher)
 
-	r.HandleFunc("/{user}/{repo}/objects", app.requireAuth(app.PostHandler)).Methods("POST").MatcherFunc(MetaMatcher)
+	r.HandleFunc("/{user}/{repo}/objects", app.requireWrite(app.PostHandler)).Methods("POST").MatcherFunc(MetaMatcher)
 
 	r.HandleFunc("/{user}/{repo}/locks", app.requireAuth(app.LocksHandler)).Methods("GET").MatcherFunc(MetaMatcher)
-	r.HandleFunc("/{user}/{repo}/locks/verify", app.requireAuth(app.LocksVerifyHandler)).Methods("POST").MatcherFunc(MetaMatcher)
-	r.HandleFunc("/{user}/{repo}/locks", app.requireAuth(app.CreateLockHandler)).Methods("POST").MatcherFunc(MetaMatcher)
-	r.HandleFunc("/{user}/{repo}/locks/{id}/unlock", app.requireAuth(app.DeleteLockHandler)).Methods("POST").MatcherFunc(MetaMatcher)
+	r.HandleFunc("/{user}/{repo}/locks/verify", app.requireWrite(app.LocksVerifyHandler)).Methods("POST").MatcherFunc(MetaMatcher)
+	r.HandleFunc("/{user}/{repo}/locks/verify-paths", app.requireAuth(app.VerifyPathsHandler)).Methods("POST").MatcherFunc(MetaMatcher)
+	r.HandleFunc("/{user}/{repo}/locks", app.requireWrite(app.CreateLockHandler)).Methods("POST").MatcherFunc(MetaMatcher)
+	r.HandleFunc("/{user}/{repo}/locks/batch", app.requireWrite(app.BatchLockHandler)).Methods("POST").MatcherFunc(MetaMatcher)
+	r.HandleFunc("/{user}/{repo}/locks/{id}/unlock", app.requireWrite(app.DeleteLockHandler)).Methods("POST").MatcherFunc(MetaMatcher)
+	r.HandleFunc("/{user}/{repo}/locks/{id}/refresh", app.requireWrite(app.RefreshLockHandler)).Methods("POST").MatcherFunc(MetaMatcher)
 
 	r.HandleFunc("/objects/batch", app.requireAuth(app.BatchHandler)).Methods("POST").MatcherFunc(MetaMatcher)
 
 	route = "/objects/{oid}"
 	r.HandleFunc(route, app.requireAuth(app.GetContentHandler)).Methods("GET", "HEAD").MatcherFunc(ContentMatcher)
 	r.HandleFunc(route, app.requireAuth(app.GetMetaHandler)).Methods("GET", "HEAD").MatcherFunc(MetaMatcher)
-	r.HandleFunc(route, app.requireAuth(app.PutHandler)).Methods("PUT").MatcherFunc(ContentMatcher)
+	r.HandleFunc(route, app.requireAuth(app.GetMultipartHandler)).Methods("GET").MatcherFunc(MultipartMatcher)
+	r.HandleFunc(route, app.requireWrite(app.PutHandler)).Methods("PUT").MatcherFunc(ContentMatcher)
 
-	r.HandleFunc("/objects", app.requireAuth(app.PostHandler)).Methods("POST").MatcherFunc(MetaMatcher)
+	r.HandleFunc("/objects", app.requireWrite(app.PostHandler)).Methods("POST").MatcherFunc(MetaMatcher)
 
-	r.HandleFunc("/verify/{oid}", app.VerifyHandler).Methods("POST")
+	r.HandleFunc("/verify/{oid}", app.idempotent(app.VerifyHandler)).Methods("POST")
 
-	app.addMgmt(r)
+	r.HandleFunc("/share/{oid}", app.ShareHandler).Methods("GET")
+
+	r.HandleFunc("/api/whoami", app.requireAuth(app.WhoamiHandler)).Methods("GET")
+
+	// The mgmt interface gets its own router when it listens separately
+	if Config.MgmtListen != "" {
+		app.mgmtRouter = mux.NewRouter()
+		app.addMgmt(withBasePath(app.mgmtRouter))
+	} else {
+		app.addMgmt(r)
+	}
 
-	app.router = r
+	app.router = root
 
 	return app
 }
 
+// withBasePath returns a router for the routes of r that are served under the
+// configured base path.
+func withBasePath(r *mux.Router) *mux.Router {
+	if base := Config.BasePrefix(); base != "" {
+		return r.PathPrefix(base).Subrouter()
+	}
+	return r
+}
+
 func (a *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.serve(w, r, a.router)
+}
+
+// MgmtHandler returns the handler for the mgmt interface when it listens on
+// its own address. It is nil otherwise, as the app's router serves it.
+func (a *App) MgmtHandler() http.Handler {
+	if a.mgmtRouter == nil {
+		return nil
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.serve(w, r, a.mgmtRouter)
+	})
+}
+
+func (a *App) serve(w http.ResponseWriter, r *http.Request, router *mux.Router) {
 	b := make([]byte, 16)
 	_, err := rand.Read(b)
 	if err == nil {
 		context.Set(r, "RequestID", fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]))
 	}
 
-	a.router.ServeHTTP(w, r)
+	if r.TLS != nil {
+		if hsts := Config.HSTSHeader(); hsts != "" {
+			w.Header().Set("Strict-Transport-Security", hsts)
+		}
+	}
+
+	if Config.IsTraceContext() {
+		span := startSpan(r.Header.Get("traceparent"))
+		context.Set(r, "Trace", span)
+		w.Header().Set("traceparent", span.String())
+	}
+
+	if threshold := Config.SlowRequestThreshold(); threshold > 0 {
+		defer startTiming(r, router).logIfSlow(r, threshold)
+	}
+
+	router.ServeHTTP(w, r)
 }
 
 // Serve calls http.Serve with the provided Listener and the app's router
@@ -223,12 +456,41 @@ func (a *App) Serve(l net.Listener) error {
 	return http.Serve(l, a)
 }
 
+// ServeMgmt calls http.Serve with the provided Listener and the mgmt router
+func (a *App) ServeMgmt(l net.Listener) error {
+	return http.Serve(l, a.MgmtHandler())
+}
+
 // GetContentHandler gets the content from the content store
 func (a *App) GetContentHandler(w http.ResponseWriter, r *http.Request) {
 	rv := unpack(r)
+	done := timePhase(r, "meta")
 	meta, err := a.metaStore.Get(rv)
+	done()
+	if err != nil && err != errObjectNotFound {
+		meta, err = a.degradedMeta(rv.Oid, err)
+	}
 	if err != nil {
-		writeStatus(w, r, 404, false)
+		writeStatus(w, r, metaErrorStatus(err, 404), false)
+		return
+	}
+
+	// Only users allowed to download the object learn why it is held back
+	if !a.authorizeDownload(w, r, rv) {
+		return
+	}
+
+	if isPendingScan(meta, time.Now()) {
+		writePendingScan(w, r)
+		return
+	}
+
+	if isWithheld(meta, time.Now()) {
+		writeWithheld(w, r)
+		return
+	}
+
+	if !a.checkSignedSize(w, r, meta) {
 		return
 	}
 
@@ -245,24 +507,301 @@ func (a *App) GetContentHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	done = timePhase(r, "wait")
+	acquired := a.readLimit.Acquire()
+	done()
+	if !acquired {
+		writeStatus(w, r, 503, false)
+		return
+	}
+	defer a.readLimit.Release()
+
+	done = timePhase(r, "content")
 	content, err := a.contentStore.Get(meta, fromByte)
+	if err != nil && a.upstream != nil {
+		content, err = a.upstream.Get(meta, fromByte)
+	}
+	if err != nil && Config.Upstream != "" {
+		// Objects are served from the store once they are cached and
+		// verified
+		if err = a.fillUpstream(r, meta); err == nil {
+			content, err = a.cachedUpstream(meta, fromByte)
+		} else if _, fetching := err.(*upstreamError); !fetching && fromByte == 0 {
+			logger.Log(kv{"fn": "GetContentHandler", "oid": meta.Oid, "err": "Could not cache upstream content: " + err.Error()})
+			done()
+			a.proxyUpstream(w, r, meta)
+			return
+		}
+	}
+	done()
 	if err != nil {
 		writeStatus(w, r, 404, false)
 		return
 	}
 	defer content.Close()
 
+	if r.Method == "GET" {
+		a.metaStore.Touch(meta.Oid, time.Now())
+	}
+
+	// Verify complete downloads against the oid while streaming them. The
+	// result can only be reported in a trailer as the body is already sent.
+	// A sample of the other complete downloads is verified too, only logging
+	// mismatches.
+	var digest hash.Hash
+	requested := r.URL.Query().Get("verify") == "1"
+	if fromByte == 0 && (requested || a.sampleVerify()) {
+		if digest, err = newOidHash(meta.Oid); err == nil && requested {
+			w.Header().Set("Trailer", "X-LFS-Integrity")
+		}
+	}
+
+	if Config.IsContentMD5() && fromByte == 0 {
+		if sum, err := a.contentMD5(meta); err == nil {
+			w.Header().Set("Content-MD5", sum)
+		}
+	}
+
+	setLabelHeaders(w, meta)
+	w.Header().Set("Content-Disposition", contentDisposition("attachment", meta))
 	w.WriteHeader(statusCode)
-	io.Copy(w, content)
+	defer timePhase(r, "transfer")()
+	if digest == nil {
+		io.Copy(w, content)
+		logRequest(r, statusCode)
+		return
+	}
+
+	// A download the client aborted says nothing about the content. Content
+	// of the wrong size fails without its digest being checked.
+	n, err := io.Copy(w, io.TeeReader(content, digest))
+	if err != nil {
+		logRequest(r, statusCode)
+		return
+	}
+	if n == meta.Size && oidMatches(digest, meta.Oid) {
+		w.Header().Set("X-LFS-Integrity", "ok")
+	} else {
+		logger.Log(kv{"fn": "GetContentHandler", "oid": meta.Oid, "requested": requested, "err": errHashMismatch.Error()})
+		w.Header().Set("X-LFS-Integrity", "failed")
+	}
 	logRequest(r, statusCode)
 }
 
+// sampleVerify draws whether a download is verified, for the configured
+// fraction of downloads.
+func (a *App) sampleVerify() bool {
+	rate := a.flags.VerifySampleRate()
+	return rate > 0 && mathrand.Float64() < rate
+}
+
+// upstreamError is a failure to fetch content from the upstream server, as
+// opposed to one storing it.
+type upstreamError struct {
+	err error
+}
+
+func (e *upstreamError) Error() string {
+	return e.err.Error()
+}
+
+// proxyUpstream passes the content of an object through from the upstream
+// server without caching it, for when it cannot be cached.
+func (a *App) proxyUpstream(w http.ResponseWriter, r *http.Request, meta *MetaObject) {
+	upstream, err := openUpstream(r, meta)
+	if err != nil {
+		logger.Log(kv{"fn": "proxyUpstream", "oid": meta.Oid, "err": err.Error()})
+		writeStatus(w, r, 404, false)
+		return
+	}
+	defer upstream.Close()
+
+	setLabelHeaders(w, meta)
+	w.Header().Set("Content-Disposition", contentDisposition("attachment", meta))
+	w.WriteHeader(200)
+	io.Copy(w, upstream)
+	logRequest(r, 200)
+}
+
+// fillUpstream copies an object missing from the content store from the
+// upstream server into the store, where its content is verified. Concurrent
+// downloads of the object wait for the same copy. Failures to fetch the
+// content are returned as an *upstreamError.
+func (a *App) fillUpstream(r *http.Request, meta *MetaObject) error {
+	return a.fills.do(meta.Oid, func() error {
+		if a.cachedExists(meta) {
+			return nil
+		}
+
+		upstream, err := openUpstream(r, meta)
+		if err != nil {
+			return &upstreamError{err}
+		}
+		defer upstream.Close()
+
+		return a.cacheContent(meta, upstream)
+	})
+}
+
+// cacheContent stores content fetched from the upstream server in the upstream
+// cache, or in the content store when there is no separate cache.
+func (a *App) cacheContent(meta *MetaObject, r io.Reader) error {
+	if a.upstream != nil {
+		return a.upstream.Put(meta, r)
+	}
+	return a.contentStore.Put(meta, r)
+}
+
+// cachedExists returns true if the object was cached by cacheContent.
+func (a *App) cachedExists(meta *MetaObject) bool {
+	if a.upstream != nil {
+		return a.upstream.Exists(meta)
+	}
+	return a.contentStore.Exists(meta)
+}
+
+// cachedUpstream returns the content of an object cached by cacheContent.
+func (a *App) cachedUpstream(meta *MetaObject, fromByte int64) (io.ReadCloser, error) {
+	if a.upstream != nil {
+		return a.upstream.Get(meta, fromByte)
+	}
+	return a.contentStore.Get(meta, fromByte)
+}
+
+// openUpstream requests an object's content from the upstream server, on
+// behalf of the request r.
+func openUpstream(r *http.Request, meta *MetaObject) (io.ReadCloser, error) {
+	req, err := http.NewRequest("GET", strings.TrimRight(Config.Upstream, "/")+"/objects/"+meta.Oid, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", contentMediaType)
+	propagateTrace(req, r)
+	if Config.UpstreamUser != "" {
+		req.SetBasicAuth(Config.UpstreamUser, Config.UpstreamPass)
+	}
+
+	client := &http.Client{Timeout: Config.UpstreamFetchTimeout()}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != 200 {
+		res.Body.Close()
+		return nil, fmt.Errorf("upstream responded with status %d", res.StatusCode)
+	}
+	return res.Body, nil
+}
+
+// contentMD5 returns the base64 encoded MD5 digest of the object's content.
+// It is computed on first use and cached on the MetaObject, as content never
+// changes.
+func (a *App) contentMD5(meta *MetaObject) (string, error) {
+	if meta.MD5 != "" {
+		return meta.MD5, nil
+	}
+
+	content, err := a.contentStore.Get(meta, 0)
+	if err != nil {
+		return "", err
+	}
+	defer content.Close()
+
+	h := md5.New()
+	if _, err := io.Copy(h, content); err != nil {
+		return "", err
+	}
+	sum := base64.StdEncoding.EncodeToString(h.Sum(nil))
+
+	if _, err := a.metaStore.SetMD5(meta.Oid, sum); err != nil {
+		logger.Log(kv{"fn": "contentMD5", "oid": meta.Oid, "err": "Could not cache MD5: " + err.Error()})
+	}
+	return sum, nil
+}
+
+// GetMultipartHandler streams a multipart/mixed response holding the object's
+// recorded oid and size as JSON, followed by the object content
+func (a *App) GetMultipartHandler(w http.ResponseWriter, r *http.Request) {
+	rv := unpack(r)
+	meta, err := a.metaStore.Get(rv)
+	if err != nil && err != errObjectNotFound {
+		meta, err = a.degradedMeta(rv.Oid, err)
+	}
+	if err != nil {
+		writeStatus(w, r, metaErrorStatus(err, 404), false)
+		return
+	}
+
+	// Only users allowed to download the object learn why it is held back
+	if !a.authorizeDownload(w, r, rv) {
+		return
+	}
+
+	if isPendingScan(meta, time.Now()) {
+		writePendingScan(w, r)
+		return
+	}
+
+	if isWithheld(meta, time.Now()) {
+		writeWithheld(w, r)
+		return
+	}
+
+	if !a.checkSignedSize(w, r, meta) {
+		return
+	}
+
+	if !a.readLimit.Acquire() {
+		writeStatus(w, r, 503, false)
+		return
+	}
+	defer a.readLimit.Release()
+
+	content, err := a.contentStore.Get(meta, 0)
+	if err != nil {
+		writeStatus(w, r, 404, false)
+		return
+	}
+	defer content.Close()
+
+	a.metaStore.Touch(meta.Oid, time.Now())
+
+	mw := multipart.NewWriter(w)
+	setLabelHeaders(w, meta)
+	w.Header().Set("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+	w.WriteHeader(200)
+
+	part, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/json"}})
+	if err == nil {
+		err = json.NewEncoder(part).Encode(struct {
+			Oid  string `json:"oid"`
+			Size int64  `json:"size"`
+		}{meta.Oid, meta.Size})
+	}
+	if err == nil {
+		part, err = mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":   {contentMediaType},
+			"Content-Length": {strconv.FormatInt(meta.Size, 10)},
+		})
+	}
+	if err == nil {
+		_, err = io.Copy(part, content)
+	}
+	if err == nil {
+		err = mw.Close()
+	}
+	if err != nil {
+		logger.Log(kv{"fn": "GetMultipartHandler", "oid": meta.Oid, "err": err.Error()})
+	}
+	logRequest(r, 200)
+}
+
 // GetMetaHandler retrieves metadata about the object
 func (a *App) GetMetaHandler(w http.ResponseWriter, r *http.Request) {
 	rv := unpack(r)
 	meta, err := a.metaStore.Get(rv)
 	if err != nil {
-		writeStatus(w, r, 404, false)
+		writeStatus(w, r, metaErrorStatus(err, 404), false)
 		return
 	}
 
@@ -279,9 +818,14 @@ func (a *App) GetMetaHandler(w http.ResponseWriter, r *http.Request) {
 // PostHandler instructs the client how to upload data
 func (a *App) PostHandler(w http.ResponseWriter, r *http.Request) {
 	rv := unpack(r)
+	if !canPushRef(r, rv.GitRef) {
+		writeRefProtected(w, r)
+		return
+	}
+
 	meta, err := a.metaStore.Put(rv)
 	if err != nil {
-		writeStatus(w, r, 404, false)
+		writeStatus(w, r, metaErrorStatus(err, 404), false)
 		return
 	}
 
@@ -302,82 +846,348 @@ func (a *App) PostHandler(w http.ResponseWriter, r *http.Request) {
 func (a *App) BatchHandler(w http.ResponseWriter, r *http.Request) {
 	bv := unpackBatch(r)
 
-	var responseObjects []*Representation
+	if bv.Operation == "upload" && !a.canWrite(r) {
+		writeForbidden(w, r)
+		return
+	}
+
+	transfer := negotiateTransfer(bv, r)
 
-	var useTus bool
-	if bv.Operation == "upload" && Config.IsUsingTus() {
-		for _, t := range bv.Transfers {
-			if t == "tus" {
-				useTus = true
-				break
+	// Clients that list adapters are told which one was picked, even when it
+	// is basic. Older clients not listing any assume basic.
+	respobj := &BatchResponse{}
+	if len(bv.Transfers) > 0 || transfer != basicTransfer {
+		respobj.Transfer = transfer
+	}
+
+	if n := Config.BatchStreamSize(); n > 0 && len(bv.Objects) >= n {
+		a.streamBatch(w, r, bv, transfer, respobj)
+		return
+	}
+
+	for _, object := range bv.Objects {
+		rep, status := a.batchObject(r, bv, object, transfer)
+		if status != 0 {
+			writeStatus(w, r, status, false)
+			return
+		}
+		if rep != nil {
+			respobj.Objects = append(respobj.Objects, rep)
+		}
+	}
+
+	w.Header().Set("Content-Type", metaMediaType)
+
+	if Config.IsPreloadHints() {
+		for _, rep := range respobj.Objects {
+			if download, ok := rep.Actions["download"]; ok {
+				w.Header().Add("Link", fmt.Sprintf("<%s>; rel=preload", download.Href))
 			}
 		}
 	}
 
-	// Create a response object
+	var body bytes.Buffer
+	json.NewEncoder(&body).Encode(respobj)
+
+	// Download results only change with the states of the objects, so clients
+	// holding an unchanged result can skip it. Uploads are never conditional
+	// as they register objects.
+	if bv.Operation == "download" {
+		etag := weakETag(body.Bytes())
+		w.Header().Set("ETag", etag)
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			logRequest(r, http.StatusNotModified)
+			return
+		}
+	}
+
+	w.Write(body.Bytes())
+	logRequest(r, 200)
+}
+
+// streamBatch writes the response to a large batch one object at a time, so
+// that it is never held in memory whole. Streamed responses have no ETag or
+// preload hints, as those need the whole response before it is sent. A
+// failure after the response started cannot change its status, so it is
+// logged and the response is cut short, leaving JSON that clients reject.
+func (a *App) streamBatch(w http.ResponseWriter, r *http.Request, bv *BatchVars, transfer string, respobj *BatchResponse) {
+	w.Header().Set("Content-Type", metaMediaType)
+	w.WriteHeader(200)
+
+	if respobj.Transfer != "" {
+		fmt.Fprintf(w, `{"transfer":%q,"objects":[`, respobj.Transfer)
+	} else {
+		fmt.Fprint(w, `{"objects":[`)
+	}
+
+	written := 0
 	for _, object := range bv.Objects {
-		meta, err := a.metaStore.Get(object)
-		if err == nil && a.contentStore.Exists(meta) { // Object is found and exists
-			responseObjects = append(responseObjects, a.Represent(object, meta, true, false, false))
+		rep, status := a.batchObject(r, bv, object, transfer)
+		if status != 0 {
+			logger.Log(kv{"fn": "streamBatch", "oid": object.Oid, "status": status, "request_id": context.Get(r, "RequestID"), "err": "Batch response cut short after " + strconv.Itoa(written) + " objects"})
+			return
+		}
+		if rep == nil {
 			continue
 		}
+		data, err := json.Marshal(rep)
+		if err == nil {
+			if written > 0 {
+				w.Write([]byte(","))
+			}
+			_, err = w.Write(data)
+		}
+		if err != nil {
+			logger.Log(kv{"fn": "streamBatch", "request_id": context.Get(r, "RequestID"), "err": "Could not write batch response: " + err.Error()})
+			return
+		}
+		written++
+	}
 
-		// Object is not found
-		if bv.Operation == "upload" {
-			meta, err = a.metaStore.Put(object)
-			if err == nil {
-				responseObjects = append(responseObjects, a.Represent(object, meta, false, true, useTus))
+	fmt.Fprint(w, "]}\n")
+	logRequest(r, 200)
+}
+
+// batchObject returns the representation of one object of a batch, or nil if
+// it is left out of the response. A non-zero status means the whole batch
+// failed with it.
+func (a *App) batchObject(r *http.Request, bv *BatchVars, object *RequestVars, transfer string) (*Representation, int) {
+	meta, objErr, status := a.decideBatchObject(r, bv, object)
+	if status != 0 {
+		return nil, status
+	}
+	if objErr != nil {
+		rep := &Representation{Oid: object.Oid, Size: object.Size, Error: objErr}
+		if meta != nil {
+			rep.Oid, rep.Size = meta.Oid, meta.Size
+		}
+		return rep, 0
+	}
+
+	if meta != nil { // Object is found and exists
+		if bv.Operation == "upload" && !meta.HasRef(refName(object)) {
+			// Pushing an existing object still adds a reference to it
+			if _, err := a.metaStore.AddRef(object); err != nil {
+				return nil, metaErrorStatus(err, 500)
 			}
-		} else {
-			rep := &Representation{
-				Oid:  object.Oid,
-				Size: object.Size,
-				Error: &ObjectError{
-					Code:    404,
-					Message: "Not found",
-				},
+		}
+		if bv.Operation == "upload" && !meta.HasExtensions(object.Extensions) {
+			updated, err := a.metaStore.AddExtensions(object)
+			if err != nil {
+				return nil, metaErrorStatus(err, 500)
 			}
-			responseObjects = append(responseObjects, rep)
+			meta = updated
 		}
+
+		rep := a.Represent(object, meta, true, false, false)
+		if transfer == standaloneTransfer {
+			rep.Actions["download"] = &link{Href: a.contentStore.FileURL(meta.Oid)}
+		}
+		return rep, 0
 	}
 
-	w.Header().Set("Content-Type", metaMediaType)
+	// Object is not found and is to be uploaded
+	meta, err := a.metaStore.Put(object)
+	if err != nil {
+		return nil, 0
+	}
+	return a.Represent(object, meta, false, true, transfer == tusTransfer), 0
+}
 
-	respobj := &BatchResponse{Objects: responseObjects}
-	// Respond with TUS support if advertised
-	if useTus {
-		respobj.Transfer = "tus"
+// decideBatchObject decides what a batch does with one object, without
+// changing anything. It returns the stored object if it is found and exists,
+// which uploads skip and downloads fetch, or nil if it is to be uploaded. An
+// object error refuses the object alone, while a non-zero status fails the
+// whole batch.
+func (a *App) decideBatchObject(r *http.Request, bv *BatchVars, object *RequestVars) (*MetaObject, *ObjectError, int) {
+	if bv.Operation == "upload" && !canPushRef(r, object.GitRef) {
+		return nil, &ObjectError{Code: 403, Message: errRefProtected.Error()}, 0
 	}
 
-	enc := json.NewEncoder(w)
-	enc.Encode(respobj)
-	logRequest(r, 200)
+	done := timePhase(r, "meta")
+	meta, err := a.metaStore.Get(object)
+	done()
+	if err == errCircuitOpen {
+		return nil, nil, 503
+	}
+	// Downloads of objects missing locally may still be fetched from the
+	// upstream server, which GetContentHandler falls back to.
+	fromUpstream := bv.Operation != "upload" && Config.Upstream != ""
+	if err == nil && (a.contentStore.Exists(meta) || a.upstream != nil && a.upstream.Exists(meta) || fromUpstream) { // Object is found and exists
+		if bv.Operation != "upload" {
+			allowed, err := a.canDownload(r, object)
+			if err != nil {
+				return nil, nil, 503
+			}
+			if !allowed {
+				return meta, &ObjectError{Code: 403, Message: errDownloadDenied.Error()}, 0
+			}
+		}
+
+		if bv.Operation != "upload" && isPendingScan(meta, time.Now()) {
+			return meta, &ObjectError{Code: 409, Message: errPendingScan.Error()}, 0
+		}
+
+		if bv.Operation != "upload" && isWithheld(meta, time.Now()) {
+			return meta, &ObjectError{Code: 402, Message: errContentWithheld.Error()}, 0
+		}
+
+		return meta, nil, 0
+	}
+
+	// Object is not found
+	if bv.Operation == "upload" {
+		if limit := Config.MaxUploadSize(); limit > 0 && object.Size > limit {
+			return nil, &ObjectError{Code: 413, Message: errUploadTooLarge.Error()}, 0
+		}
+		return nil, nil, 0
+	}
+
+	return nil, &ObjectError{Code: 404, Message: "Not found"}, 0
+}
+
+// weakETag returns a weak entity tag for a response body.
+func weakETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return fmt.Sprintf(`W/"%x"`, sum[:16])
+}
+
+// etagMatches returns true if the If-None-Match header value lists etag,
+// comparing weakly, or is "*".
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, t := range strings.Split(ifNoneMatch, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
 }
 
 // PutHandler receives data from the client and puts it into the content store
 func (a *App) PutHandler(w http.ResponseWriter, r *http.Request) {
+	if Config.IsStrictContentType() && !isOctetStream(r.Header.Get("Content-Type")) {
+		w.Header().Set("Content-Type", metaMediaType)
+		w.WriteHeader(415)
+		fmt.Fprint(w, `{"message":"Content-Type must be application/octet-stream"}`)
+		logRequest(r, 415)
+		return
+	}
+
 	rv := unpack(r)
+	if !canPushRef(r, rv.GitRef) {
+		writeRefProtected(w, r)
+		return
+	}
+
+	done := timePhase(r, "meta")
 	meta, err := a.metaStore.Get(rv)
+	done()
 	if err != nil {
-		writeStatus(w, r, 404, false)
+		writeStatus(w, r, metaErrorStatus(err, 404), false)
 		return
 	}
 
-	if err := a.contentStore.Put(meta, r.Body); err != nil {
+	// Everything that can refuse the upload is checked before the body is
+	// read. Clients sending Expect: 100-continue are only told to continue
+	// on the first read, so a refused upload is never sent.
+	if status, err := checkUpload(r, meta); err != nil {
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"message":"%s"}`, err)
+		logRequest(r, status)
+		return
+	}
+
+	done = timePhase(r, "wait")
+	acquired := a.writeLimit.Acquire()
+	done()
+	if !acquired {
+		writeStatus(w, r, 503, false)
+		return
+	}
+	defer a.writeLimit.Release()
+
+	name := r.Header.Get("X-LFS-Filename")
+	if !a.contentStore.Exists(meta) {
+		if err := a.quarantine(meta.Oid); err != nil {
+			w.WriteHeader(500)
+			fmt.Fprintf(w, `{"message":"%s"}`, err)
+			logRequest(r, 500)
+			return
+		}
+	}
+
+	var body io.Reader = r.Body
+	var diag *uploadDiagnostics
+	if Config.IsUploadDiagnostics() {
+		diag = newUploadDiagnostics(r.Body, meta.Oid)
+		body = diag
+	}
+	if checksum := r.Header.Get("X-LFS-Checksum"); checksum != "" {
+		if body, err = newChecksumReader(body, checksum); err != nil {
+			w.WriteHeader(400)
+			fmt.Fprintf(w, `{"message":"%s"}`, err)
+			logRequest(r, 400)
+			return
+		}
+	}
+
+	done = timePhase(r, "content")
+	err = a.contentStore.Put(meta, body)
+	done()
+	if err != nil {
+		if diag != nil {
+			diag.log(r, meta, err)
+		}
+		if err == errChecksumMismatch {
+			w.WriteHeader(400)
+			fmt.Fprintf(w, `{"message":"%s"}`, err)
+			logRequest(r, 400)
+			return
+		}
+		if err == errContentExists {
+			w.WriteHeader(409)
+			fmt.Fprintf(w, `{"message":"%s"}`, err)
+			logRequest(r, 409)
+			return
+		}
 		a.metaStore.Delete(rv)
 		w.WriteHeader(500)
 		fmt.Fprintf(w, `{"message":"%s"}`, err)
 		return
 	}
 
+	if name != "" {
+		if _, err := a.metaStore.SetName(meta.Oid, name); err != nil {
+			logger.Log(kv{"fn": "PutHandler", "oid": meta.Oid, "err": "Could not record file name: " + err.Error()})
+		}
+	}
+
 	logRequest(r, 200)
 }
 
+// checkUpload returns the status and error to refuse an upload of meta with,
+// judging by its headers alone, or a nil error if the body should be read.
+func checkUpload(r *http.Request, meta *MetaObject) (int, error) {
+	if limit := Config.MaxUploadSize(); limit > 0 && meta.Size > limit {
+		return 413, errUploadTooLarge
+	}
+	if r.ContentLength >= 0 && r.ContentLength != meta.Size {
+		return 400, errSizeMismatch
+	}
+	return 0, nil
+}
+
 func (a *App) VerifyHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	oid := vars["oid"]
-	err := tusServer.Finish(oid, a.contentStore)
+	if err := a.quarantine(oid); err != nil {
+		writeStatus(w, r, metaErrorStatus(err, 500), false)
+		return
+	}
 
+	err := tusServer.Finish(oid, a.contentStore)
 	if err != nil {
 		logger.Fatal(kv{"fn": "VerifyHandler", "err": fmt.Sprintf("Failed to verify %s: %v", oid, err)})
 	}
@@ -385,6 +1195,43 @@ func (a *App) VerifyHandler(w http.ResponseWriter, r *http.Request) {
 	logRequest(r, 200)
 }
 
+// WhoamiHandler reports the identity the request authenticated as
+func (a *App) WhoamiHandler(w http.ResponseWriter, r *http.Request) {
+	who := &WhoamiResponse{Role: "anonymous"}
+
+	if user, ok := context.Get(r, "USER").(string); ok {
+		who.Name = user
+		who.Role = "user"
+
+		_, password, _ := r.BasicAuth()
+		if checkBasicAuth(user, password, true) {
+			who.Role = "admin"
+		} else if !a.canWrite(r) {
+			who.Role = "read-only"
+		}
+
+		objects, err := a.metaStore.ObjectsByUploader(user)
+		if err != nil {
+			writeStatus(w, r, metaErrorStatus(err, 500), false)
+			return
+		}
+		who.Usage = &WhoamiUsage{}
+		for _, meta := range objects {
+			if !meta.PendingDelete {
+				who.Usage.Objects++
+				who.Usage.Size += meta.Size
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	enc := json.NewEncoder(w)
+	enc.Encode(who)
+
+	logRequest(r, 200)
+}
+
 func (a *App) LocksHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	repo := vars["repo"]
@@ -396,6 +1243,7 @@ func (a *App) LocksHandler(w http.ResponseWriter, r *http.Request) {
 
 	locks, nextCursor, err := a.metaStore.FilteredLocks(repo,
 		r.FormValue("path"),
+		r.FormValue("refspec"),
 		r.FormValue("cursor"),
 		r.FormValue("limit"))
 
@@ -436,6 +1284,7 @@ func (a *App) LocksVerifyHandler(w http.ResponseWriter, r *http.Request) {
 
 	ll := &VerifiableLockList{}
 	locks, nextCursor, err := a.metaStore.FilteredLocks(repo, "",
+		lockRef(reqBody.Ref),
 		reqBody.Cursor,
 		strconv.Itoa(limit))
 	if err != nil {
@@ -457,6 +1306,48 @@ func (a *App) LocksVerifyHandler(w http.ResponseWriter, r *http.Request) {
 	logRequest(r, 200)
 }
 
+// VerifyPathsHandler reports for each requested path whether it is locked,
+// and whether by the user or someone else.
+func (a *App) VerifyPathsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	repo := vars["repo"]
+	user := context.Get(r, "USER")
+
+	dec := json.NewDecoder(r.Body)
+	enc := json.NewEncoder(w)
+
+	w.Header().Set("Content-Type", metaMediaType)
+
+	var req VerifyPathsRequest
+	if err := dec.Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		enc.Encode(&VerifyPathsResponse{Message: err.Error()})
+		return
+	}
+
+	locks, err := a.metaStore.LocksByPath(repo, lockRef(req.Ref))
+	if err != nil {
+		w.WriteHeader(metaErrorStatus(err, http.StatusInternalServerError))
+		enc.Encode(&VerifyPathsResponse{Message: err.Error()})
+		return
+	}
+
+	res := &VerifyPathsResponse{Paths: make([]PathLockStatus, 0, len(req.Paths))}
+	for _, path := range req.Paths {
+		status := PathLockStatus{Path: path}
+		if l, ok := locks[normalizeLockPath(path)]; ok {
+			status.Locked = true
+			status.Ours = l.Owner.Name == user
+			status.Lock = &l
+		}
+		res.Paths = append(res.Paths, status)
+	}
+
+	enc.Encode(res)
+
+	logRequest(r, 200)
+}
+
 func (a *App) CreateLockHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	repo := vars["repo"]
@@ -473,16 +1364,12 @@ func (a *App) CreateLockHandler(w http.ResponseWriter, r *http.Request) {
 		enc.Encode(&LockResponse{Message: err.Error()})
 		return
 	}
+	lockRequest.Path = normalizeLockPath(lockRequest.Path)
 
-	locks, _, err := a.metaStore.FilteredLocks(repo, lockRequest.Path, "", "1")
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		enc.Encode(&LockResponse{Message: err.Error()})
-		return
-	}
-	if len(locks) > 0 {
-		w.WriteHeader(http.StatusConflict)
-		enc.Encode(&LockResponse{Message: "lock already created"})
+	if !isLockablePath(lockRequest.Path) {
+		w.WriteHeader(http.StatusForbidden)
+		enc.Encode(&LockResponse{Message: fmt.Sprintf("path %q may not be locked, lockable paths: %s", lockRequest.Path, strings.Join(Config.LockPathPatterns(), ", "))})
+		logRequest(r, http.StatusForbidden)
 		return
 	}
 
@@ -491,14 +1378,48 @@ func (a *App) CreateLockHandler(w http.ResponseWriter, r *http.Request) {
 		Path:     lockRequest.Path,
 		Owner:    User{Name: user},
 		LockedAt: time.Now(),
+		Ref:      lockRef(lockRequest.Ref),
+	}
+	if ttl := Config.LockExpiry(); ttl > 0 {
+		expires := lock.LockedAt.Add(ttl)
+		lock.ExpiresAt = &expires
 	}
 
-	if err := a.metaStore.AddLocks(repo, *lock); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+	outcomes, err := a.metaStore.CreateLocks(repo, Config.LockLimit(), *lock)
+	if err != nil {
+		w.WriteHeader(metaErrorStatus(err, http.StatusInternalServerError))
 		enc.Encode(&LockResponse{Message: err.Error()})
 		return
 	}
 
+	if conflict := outcomes[0].Conflict; conflict != nil {
+		// A retried request gets the lock it already created
+		if isRelock(conflict, user) {
+			enc.Encode(&LockResponse{Lock: conflict})
+			logRequest(r, 200)
+			return
+		}
+		if base := Config.LockBackoffBase(); base > 0 {
+			key := contentionKey(repo, user, lock.Path)
+			if wait := a.contention.conflict(key, base, time.Now()); wait > 0 {
+				writeLockBackoff(w, r, wait)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusConflict)
+		enc.Encode(&LockResponse{Message: "lock already created"})
+		return
+	}
+
+	if outcomes[0].OverLimit {
+		w.WriteHeader(http.StatusForbidden)
+		enc.Encode(&LockResponse{Message: lockLimitMessage()})
+		logRequest(r, http.StatusForbidden)
+		return
+	}
+
+	a.contention.reset(contentionKey(repo, user, lock.Path))
+
 	w.WriteHeader(http.StatusCreated)
 	enc.Encode(&LockResponse{
 		Lock: lock,
@@ -507,6 +1428,147 @@ func (a *App) CreateLockHandler(w http.ResponseWriter, r *http.Request) {
 	logRequest(r, 200)
 }
 
+// BatchLockHandler creates a lock for each requested path in one store
+// transaction. It answers 201 if every lock was created, and 207 with the error
+// for each path that was not, such as a 409 for a path that another user has
+// locked or a 403 for paths past the user's lock limit.
+func (a *App) BatchLockHandler(w http.ResponseWriter, r *http.Request) {
+	repo := mux.Vars(r)["repo"]
+	user := context.Get(r, "USER").(string)
+
+	dec := json.NewDecoder(r.Body)
+	enc := json.NewEncoder(w)
+
+	w.Header().Set("Content-Type", metaMediaType)
+
+	var req BatchLockRequest
+	if err := dec.Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		enc.Encode(&BatchLockResponse{Message: err.Error()})
+		return
+	}
+
+	res := &BatchLockResponse{Locks: make([]BatchLockResult, len(req.Locks))}
+	var locks []Lock
+	var indexes []int
+	now := time.Now()
+	for i, lr := range req.Locks {
+		// Results name the path as it is stored, as single lock responses do
+		path := normalizeLockPath(lr.Path)
+		res.Locks[i].Path = path
+		if !isLockablePath(path) {
+			res.Locks[i].Error = &ObjectError{
+				Code:    http.StatusForbidden,
+				Message: fmt.Sprintf("path %q may not be locked, lockable paths: %s", lr.Path, strings.Join(Config.LockPathPatterns(), ", ")),
+			}
+			continue
+		}
+		lock := Lock{
+			Id:       randomLockId(),
+			Path:     path,
+			Owner:    User{Name: user},
+			LockedAt: now,
+			Ref:      lockRef(lr.Ref),
+		}
+		if ttl := Config.LockExpiry(); ttl > 0 {
+			expires := now.Add(ttl)
+			lock.ExpiresAt = &expires
+		}
+		locks = append(locks, lock)
+		indexes = append(indexes, i)
+	}
+
+	outcomes, err := a.metaStore.CreateLocks(repo, Config.LockLimit(), locks...)
+	if err != nil {
+		status := metaErrorStatus(err, http.StatusInternalServerError)
+		w.WriteHeader(status)
+		enc.Encode(&BatchLockResponse{Message: err.Error()})
+		logRequest(r, status)
+		return
+	}
+
+	status := http.StatusCreated
+	for j, i := range indexes {
+		switch outcome := outcomes[j]; {
+		case outcome.OverLimit:
+			res.Locks[i].Error = &ObjectError{Code: http.StatusForbidden, Message: lockLimitMessage()}
+		case outcome.Conflict == nil:
+			res.Locks[i].Lock = &locks[j]
+		case isRelock(outcome.Conflict, user):
+			res.Locks[i].Lock = outcome.Conflict
+		default:
+			res.Locks[i].Error = &ObjectError{Code: http.StatusConflict, Message: "lock already created"}
+		}
+	}
+	for _, l := range res.Locks {
+		if l.Error != nil {
+			status = http.StatusMultiStatus
+		}
+	}
+
+	w.WriteHeader(status)
+	enc.Encode(res)
+
+	logRequest(r, status)
+}
+
+// isRelock returns true if a request from user for the path of the held lock
+// is answered with that lock rather than a conflict.
+func isRelock(held *Lock, user string) bool {
+	return Config.IsRelockExisting() && held.Owner.Name == user
+}
+
+func lockLimitMessage() string {
+	return fmt.Sprintf("lock limit reached, a user may hold %d locks", Config.LockLimit())
+}
+
+// lockRef returns the name of ref, or the configured default ref for clients
+// that do not send one.
+func lockRef(ref *Ref) string {
+	if ref == nil || ref.Name == "" {
+		return Config.LockRef
+	}
+	return ref.Name
+}
+
+// RefreshLockHandler extends the expiry of a lock held by the user
+func (a *App) RefreshLockHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	repo := vars["repo"]
+	user := context.Get(r, "USER").(string)
+
+	enc := json.NewEncoder(w)
+
+	w.Header().Set("Content-Type", metaMediaType)
+
+	ttl := Config.LockExpiry()
+	if ttl <= 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		enc.Encode(&LockResponse{Message: "locks do not expire"})
+		return
+	}
+
+	l, err := a.metaStore.RefreshLock(repo, user, vars["id"], time.Now().Add(ttl))
+	if err != nil {
+		if err == errNotOwner {
+			w.WriteHeader(http.StatusForbidden)
+		} else {
+			w.WriteHeader(metaErrorStatus(err, http.StatusInternalServerError))
+		}
+		enc.Encode(&LockResponse{Message: err.Error()})
+		return
+	}
+	if l == nil {
+		w.WriteHeader(http.StatusNotFound)
+		enc.Encode(&LockResponse{Message: "unable to find lock"})
+		return
+	}
+
+	enc.Encode(&LockResponse{Lock: l})
+
+	logRequest(r, 200)
+}
+
 func (a *App) DeleteLockHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	repo := vars["repo"]
@@ -537,7 +1599,7 @@ func (a *App) DeleteLockHandler(w http.ResponseWriter, r *http.Request) {
 		if err == errNotOwner {
 			w.WriteHeader(http.StatusForbidden)
 		} else {
-			w.WriteHeader(http.StatusInternalServerError)
+			w.WriteHeader(metaErrorStatus(err, http.StatusInternalServerError))
 		}
 		enc.Encode(&UnlockResponse{Message: err.Error()})
 		return
@@ -557,39 +1619,64 @@ func (a *App) DeleteLockHandler(w http.ResponseWriter, r *http.Request) {
 // for json encoding
 func (a *App) Represent(rv *RequestVars, meta *MetaObject, download, upload, useTus bool) *Representation {
 	rep := &Representation{
-		Oid:     meta.Oid,
-		Size:    meta.Size,
-		Actions: make(map[string]*link),
+		Oid:        meta.Oid,
+		Size:       meta.Size,
+		Actions:    make(map[string]*link),
+		Extensions: meta.Extensions,
 	}
 
 	header := make(map[string]string)
-	verifyHeader := make(map[string]string)
 
 	header["Accept"] = contentMediaType
 
 	if len(rv.Authorization) > 0 {
 		header["Authorization"] = rv.Authorization
-		verifyHeader["Authorization"] = rv.Authorization
 	}
 
 	if download {
-		rep.Actions["download"] = &link{Href: rv.DownloadLink(), Header: header}
+		href := rv.DownloadLink()
+		if Config.IsSignDownloads() {
+			href = a.signedDownloadLink(href, meta)
+		}
+		rep.Actions["download"] = &link{Href: href, Header: header}
 	}
 
 	if upload {
 		rep.Actions["upload"] = &link{Href: rv.UploadLink(useTus), Header: header}
 		if useTus {
-			rep.Actions["verify"] = &link{Href: rv.VerifyLink(), Header: verifyHeader}
+			rep.Actions["verify"] = verifyAction(rv)
 		}
 	}
 	return rep
 }
 
+// verifyAction returns the verify action of an upload. The client's
+// credentials are only passed on to the server's own verify endpoint, never
+// to an external verifier.
+func verifyAction(rv *RequestVars) *link {
+	header := make(map[string]string)
+	if len(rv.Authorization) > 0 && Config.VerifyURL == "" {
+		header["Authorization"] = rv.Authorization
+	}
+	return &link{Href: rv.VerifyLink(), Header: header}
+}
+
 func (a *App) requireAuth(h http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if !Config.IsPublic() {
+		if a.injectFault(w, r) {
+			return
+		}
+
+		if user, ok := a.proxyUser(r); ok {
+			context.Set(r, "USER", user)
+		} else if !Config.IsPublic() {
 			user, password, _ := r.BasicAuth()
 			if user, ret := a.metaStore.Authenticate(user, password); !ret {
+				if !a.metaStore.IsAvailable() {
+					writeStatus(w, r, 503, false)
+					return
+				}
+
 				w.Header().Set("WWW-Authenticate", "Basic realm=git-lfs-server")
 
 				// if user is empty, this is probably the initial 401 response
@@ -605,6 +1692,124 @@ func (a *App) requireAuth(h http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// proxyUser returns the user named in the TrustAuthHeader of a request made
+// by a trusted proxy, which authenticated the user itself, and true if the
+// user has a record. The header is ignored on requests from anywhere else, so
+// clients cannot claim an identity by sending it.
+func (a *App) proxyUser(r *http.Request) (string, bool) {
+	if Config.TrustAuthHeader == "" {
+		return "", false
+	}
+	header := strings.TrimSpace(r.Header.Get(Config.TrustAuthHeader))
+	if header == "" || !isFromNets(r, Config.TrustedProxies()) {
+		return "", false
+	}
+
+	user, err := a.metaStore.UserName(header)
+	if err != nil || user == "" {
+		logger.Log(kv{"fn": "proxyUser", "user": header, "ip": r.RemoteAddr, "err": "No record of the user authenticated by the proxy"})
+		return "", false
+	}
+	return user, true
+}
+
+// quarantine holds a newly uploaded object back from downloads until it is
+// approved, if quarantining uploads is enabled. It is called before the
+// content is stored, so that the object is never served unapproved, and the
+// upload must fail if it returns an error.
+func (a *App) quarantine(oid string) error {
+	if !a.flags.IsQuarantine() {
+		return nil
+	}
+	if _, err := a.metaStore.SetQuarantined(oid, true); err != nil {
+		logger.Log(kv{"fn": "quarantine", "oid": oid, "err": "Could not quarantine object: " + err.Error()})
+		return err
+	}
+	return nil
+}
+
+// degradedMeta stands in for the meta of an object when the meta store failed
+// with metaErr, so that content can still be downloaded while it is down. The
+// size comes from the stored content. It returns metaErr if the content is
+// not in the store, or if objects may be quarantined, held back for a grace
+// period or withheld by age, as none of that can be checked.
+func (a *App) degradedMeta(oid string, metaErr error) (*MetaObject, error) {
+	if a.flags.IsQuarantine() || Config.GracePeriodDuration() > 0 || Config.WithholdAgeLimit() > 0 {
+		return nil, metaErr
+	}
+
+	size, err := a.contentStore.Size(oid)
+	if err != nil {
+		return nil, metaErr
+	}
+
+	logger.Log(kv{"fn": "degradedMeta", "oid": oid, "msg": "serving content without meta", "err": metaErr.Error()})
+	return &MetaObject{Oid: oid, Size: size}, nil
+}
+
+// writePendingScan answers a download of an object pending a scan.
+func writePendingScan(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", metaMediaType)
+	w.WriteHeader(409)
+	fmt.Fprintf(w, `{"message":"%s"}`, errPendingScan)
+	logRequest(r, 409)
+}
+
+// requireWrite wraps requireAuth, additionally refusing requests that change
+// objects or locks from users that may only read.
+func (a *App) requireWrite(h http.HandlerFunc) http.HandlerFunc {
+	return a.requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		if !a.canWrite(r) {
+			writeForbidden(w, r)
+			return
+		}
+		h(w, r)
+	})
+}
+
+// canWrite returns false if the request was authenticated as a read-only
+// user, or if the server was made read-only.
+func (a *App) canWrite(r *http.Request) bool {
+	if a.flags.IsReadOnly() {
+		return false
+	}
+
+	user, ok := context.Get(r, "USER").(string)
+	return !ok || !Config.IsReadOnlyUser(user)
+}
+
+// writeForbidden answers a request the authenticated user is not allowed to
+// make. Unlike a 401 it always carries an LFS error body, so clients can tell
+// that logging in again will not help.
+func writeForbidden(w http.ResponseWriter, r *http.Request) {
+	requestID, _ := context.Get(r, "RequestID").(string)
+
+	w.Header().Set("Content-Type", metaMediaType)
+	w.WriteHeader(403)
+	json.NewEncoder(w).Encode(struct {
+		Message   string `json:"message"`
+		RequestID string `json:"request_id,omitempty"`
+	}{"You do not have permission to write to this server", requestID})
+
+	logRequest(r, 403)
+}
+
+// setLabelHeaders sets the headers configured for the labels of an object on
+// its download.
+func setLabelHeaders(w http.ResponseWriter, meta *MetaObject) {
+	for _, h := range Config.LabelHeaderList() {
+		if meta.HasLabel(h.Label) {
+			w.Header().Set(h.Name, h.Value)
+		}
+	}
+}
+
+// contentDisposition returns a Content-Disposition header value naming the
+// object's file.
+func contentDisposition(disposition string, meta *MetaObject) string {
+	return mime.FormatMediaType(disposition, map[string]string{"filename": meta.Filename()})
+}
+
 // ContentMatcher provides a mux.MatcherFunc that only allows requests that contain
 // an Accept header with the contentMediaType
 func ContentMatcher(r *http.Request, m *mux.RouteMatch) bool {
@@ -613,6 +1818,24 @@ func ContentMatcher(r *http.Request, m *mux.RouteMatch) bool {
 	return mt == contentMediaType
 }
 
+// isOctetStream returns true if contentType is empty or the
+// application/octet-stream media type, which is all raw uploads should send.
+func isOctetStream(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mt, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mt == "application/octet-stream"
+}
+
+// MultipartMatcher provides a mux.MatcherFunc that only allows requests that
+// contain an Accept header with the multipart/mixed media type
+func MultipartMatcher(r *http.Request, m *mux.RouteMatch) bool {
+	mediaParts := strings.Split(r.Header.Get("Accept"), ";")
+	mt := mediaParts[0]
+	return mt == "multipart/mixed"
+}
+
 // MetaMatcher provides a mux.MatcherFunc that only allows requests that contain
 // an Accept header with the metaMediaType
 func MetaMatcher(r *http.Request, m *mux.RouteMatch) bool {
@@ -629,11 +1852,14 @@ func randomLockId() string {
 
 func unpack(r *http.Request) *RequestVars {
 	vars := mux.Vars(r)
+	uploader, _ := context.Get(r, "USER").(string)
 	rv := &RequestVars{
 		User:          vars["user"],
 		Repo:          vars["repo"],
 		Oid:           vars["oid"],
 		Authorization: r.Header.Get("Authorization"),
+		Uploader:      uploader,
+		GitRef:        r.URL.Query().Get("ref"),
 	}
 
 	if r.Method == "POST" { // Maybe also check if +json
@@ -663,15 +1889,31 @@ func unpackBatch(r *http.Request) *BatchVars {
 		return &bv
 	}
 
+	uploader, _ := context.Get(r, "USER").(string)
+	var gitRef string
+	if bv.Ref != nil {
+		gitRef = bv.Ref.Name
+	}
 	for i := 0; i < len(bv.Objects); i++ {
 		bv.Objects[i].User = vars["user"]
 		bv.Objects[i].Repo = vars["repo"]
 		bv.Objects[i].Authorization = r.Header.Get("Authorization")
+		bv.Objects[i].Uploader = uploader
+		bv.Objects[i].GitRef = gitRef
 	}
 
 	return &bv
 }
 
+// metaErrorStatus returns the status to respond with for a meta store error,
+// turning an open circuit breaker into a 503.
+func metaErrorStatus(err error, status int) int {
+	if err == errCircuitOpen {
+		return 503
+	}
+	return status
+}
+
 func writeStatus(w http.ResponseWriter, r *http.Request, status int, isInitialAuthResponse bool) {
 	message := http.StatusText(status)
 
@@ -701,5 +1943,17 @@ func writeStatus(w http.ResponseWriter, r *http.Request, status int, isInitialAu
 }
 
 func logRequest(r *http.Request, status int) {
-	logger.Log(kv{"method": r.Method, "url": r.URL, "status": status, "ip": r.RemoteAddr, "request_id": context.Get(r, "RequestID")})
+	// Only successes are sampled, every other response is logged
+	if status >= 200 && status < 300 {
+		if rate := Config.LogSampling(); rate < 1 && mathrand.Float64() >= rate {
+			return
+		}
+	}
+
+	data := kv{"method": r.Method, "url": r.URL, "status": status, "ip": r.RemoteAddr, "request_id": context.Get(r, "RequestID")}
+	if span, ok := traceOf(r); ok {
+		data["trace_id"] = span.TraceID
+		data["span_id"] = span.SpanID
+	}
+	logger.Log(data)
 }
@@ -1,14 +1,30 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
 )
 
 func TestGetAuthed(t *testing.T) {
@@ -31,6 +47,428 @@ func TestGetAuthed(t *testing.T) {
 	}
 }
 
+func TestGetMultipart(t *testing.T) {
+	res, err := api("GET", "/user/repo/objects/"+contentOid, "multipart/mixed", testUser, testPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+
+	if res.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+
+	mt, params, err := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if err != nil || mt != "multipart/mixed" {
+		t.Fatalf("expected multipart/mixed content type, got: %s", res.Header.Get("Content-Type"))
+	}
+	mr := multipart.NewReader(res.Body, params["boundary"])
+
+	part, err := mr.NextPart()
+	if err != nil {
+		t.Fatalf("expected metadata part, got error: %s", err)
+	}
+	if ct := part.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected metadata part to be json, got: %s", ct)
+	}
+	var meta struct {
+		Oid  string `json:"oid"`
+		Size int64  `json:"size"`
+	}
+	if err := json.NewDecoder(part).Decode(&meta); err != nil {
+		t.Fatalf("expected json metadata, got error: %s", err)
+	}
+	if meta.Oid != contentOid || meta.Size != contentSize {
+		t.Errorf("expected metadata %s/%d, got %s/%d", contentOid, contentSize, meta.Oid, meta.Size)
+	}
+
+	part, err = mr.NextPart()
+	if err != nil {
+		t.Fatalf("expected content part, got error: %s", err)
+	}
+	if ct := part.Header.Get("Content-Type"); ct != contentMediaType {
+		t.Errorf("expected content part to be %s, got: %s", contentMediaType, ct)
+	}
+	by, err := ioutil.ReadAll(part)
+	if err != nil {
+		t.Fatalf("expected content part to contain content, got error: %s", err)
+	}
+	if string(by) != content {
+		t.Errorf("expected content to be `content`, got: %s", string(by))
+	}
+
+	if _, err := mr.NextPart(); err == nil {
+		t.Errorf("expected only two parts")
+	}
+}
+
+func TestGetVerify(t *testing.T) {
+	oid, _ := seedObject(t, "verified content")
+
+	for _, tc := range []struct {
+		stored    string
+		integrity string
+	}{{"verified content", "ok"}, {"corrupted content", "failed"}} {
+		path := filepath.Join(testContentStore.basePath, transformKey(oid))
+		if err := ioutil.WriteFile(path, []byte(tc.stored), 0640); err != nil {
+			t.Fatalf("error writing content: %s", err)
+		}
+
+		res, err := api("GET", "/user/repo/objects/"+oid+"?verify=1", contentMediaType, testUser, testPass, nil)
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		if res.StatusCode != 200 {
+			t.Fatalf("expected status 200, got %d", res.StatusCode)
+		}
+
+		by, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			t.Fatalf("expected response to contain content, got error: %s", err)
+		}
+		if string(by) != tc.stored {
+			t.Errorf("expected content `%s`, got: %s", tc.stored, string(by))
+		}
+		if got := res.Trailer.Get("X-LFS-Integrity"); got != tc.integrity {
+			t.Errorf("expected X-LFS-Integrity trailer %q, got %q", tc.integrity, got)
+		}
+	}
+}
+
+// abortingWriter takes the first write of a response and fails the others,
+// like a client that goes away during a download.
+type abortingWriter struct {
+	*httptest.ResponseRecorder
+	writes int
+}
+
+func (w *abortingWriter) Write(b []byte) (int, error) {
+	if w.writes++; w.writes > 1 {
+		return 0, fmt.Errorf("client went away")
+	}
+	return w.ResponseRecorder.Write(b)
+}
+
+func TestGetVerifyAborted(t *testing.T) {
+	defer func(l *KVLogger) { logger = l }(logger)
+	var log bytes.Buffer
+	logger = NewKVLogger(&log)
+
+	oid, _ := seedObject(t, strings.Repeat("TestGetVerifyAborted content ", 4096))
+
+	req := httptest.NewRequest("GET", "/user/repo/objects/"+oid+"?verify=1", nil)
+	req.SetBasicAuth(testUser, testPass)
+	req.Header.Set("Accept", contentMediaType)
+	w := &abortingWriter{ResponseRecorder: httptest.NewRecorder()}
+	lfsServer.Config.Handler.ServeHTTP(w, req)
+
+	if w.writes < 2 {
+		t.Fatalf("expected the download to be aborted, got %d writes", w.writes)
+	}
+	if strings.Contains(log.String(), errHashMismatch.Error()) {
+		t.Errorf("expected an aborted download to not be reported as a mismatch, got %s", log.String())
+	}
+	if got := w.Header().Get("X-LFS-Integrity"); got != "" {
+		t.Errorf("expected no integrity result for an aborted download, got %q", got)
+	}
+}
+
+func TestGetVerifySampled(t *testing.T) {
+	defer func(rate string) { Config.VerifySample = rate }(Config.VerifySample)
+	defer func(l *KVLogger) { logger = l }(logger)
+
+	oid, _ := seedObject(t, "sampled content")
+	path := filepath.Join(testContentStore.basePath, transformKey(oid))
+	if err := ioutil.WriteFile(path, []byte("corrupted sample"), 0640); err != nil {
+		t.Fatalf("error writing content: %s", err)
+	}
+
+	for _, tc := range []struct {
+		rate     string
+		verified int
+	}{{"1.0", 5}, {"0.0", 0}} {
+		Config.VerifySample = tc.rate
+		var log bytes.Buffer
+		logger = NewKVLogger(&log)
+
+		for i := 0; i < 5; i++ {
+			res, err := api("GET", "/user/repo/objects/"+oid, contentMediaType, testUser, testPass, nil)
+			if err != nil {
+				t.Fatalf("request error: %s", err)
+			}
+			if res.StatusCode != 200 {
+				t.Fatalf("expected status 200, got %d", res.StatusCode)
+			}
+			ioutil.ReadAll(res.Body)
+			if got := res.Trailer.Get("X-LFS-Integrity"); got != "" {
+				t.Errorf("expected no X-LFS-Integrity trailer for a sampled download, got %q", got)
+			}
+		}
+
+		if n := strings.Count(log.String(), errHashMismatch.Error()); n != tc.verified {
+			t.Errorf("expected %d mismatches to be logged with rate %s, got %d", tc.verified, tc.rate, n)
+		}
+	}
+}
+
+func TestGetContentMD5(t *testing.T) {
+	defer func(contentMD5 string) { Config.ContentMD5 = contentMD5 }(Config.ContentMD5)
+	Config.ContentMD5 = "true"
+
+	data := "md5 content"
+	oid, _ := seedObject(t, data)
+	sum := md5.Sum([]byte(data))
+	expected := base64.StdEncoding.EncodeToString(sum[:])
+
+	for i := 0; i < 2; i++ {
+		res, err := api("GET", "/user/repo/objects/"+oid, contentMediaType, testUser, testPass, nil)
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		if res.StatusCode != 200 {
+			t.Fatalf("expected status 200, got %d", res.StatusCode)
+		}
+		if got := res.Header.Get("Content-MD5"); got != expected {
+			t.Errorf("expected Content-MD5 %s, got %q", expected, got)
+		}
+	}
+
+	meta, err := testMetaStore.Get(&RequestVars{Oid: oid})
+	if err != nil {
+		t.Fatalf("error retrieving meta: %s", err)
+	}
+	if meta.MD5 != expected {
+		t.Errorf("expected MD5 to be cached on the object, got %q", meta.MD5)
+	}
+}
+
+func TestGetFromUpstream(t *testing.T) {
+	objects := map[string]string{}
+	hits := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		user, pass, _ := r.BasicAuth()
+		data, ok := objects[strings.TrimPrefix(r.URL.Path, "/objects/")]
+		if !ok || user != "mirror" || pass != "secret" || r.Header.Get("Accept") != contentMediaType {
+			w.WriteHeader(404)
+			return
+		}
+		fmt.Fprint(w, data)
+	}))
+	defer upstream.Close()
+
+	defer func(url, user, pass string) {
+		Config.Upstream, Config.UpstreamUser, Config.UpstreamPass = url, user, pass
+	}(Config.Upstream, Config.UpstreamUser, Config.UpstreamPass)
+	Config.Upstream, Config.UpstreamUser, Config.UpstreamPass = upstream.URL+"/", "mirror", "secret"
+
+	register := func(data string) string {
+		oid := fmt.Sprintf("%x", sha256.Sum256([]byte(data)))
+		if _, err := testMetaStore.Put(&RequestVars{Oid: oid, Size: int64(len(data))}); err != nil {
+			t.Fatalf("error seeding meta store: %s", err)
+		}
+		objects[oid] = data
+		return oid
+	}
+
+	data := "upstream content"
+	oid := register(data)
+	for i := 0; i < 2; i++ {
+		res, err := api("GET", "/user/repo/objects/"+oid, contentMediaType, testUser, testPass, nil)
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		if res.StatusCode != 200 {
+			t.Fatalf("expected status 200, got %d", res.StatusCode)
+		}
+		if by, _ := ioutil.ReadAll(res.Body); string(by) != data {
+			t.Errorf("expected content `%s`, got: %s", data, string(by))
+		}
+	}
+	if hits != 1 {
+		t.Errorf("expected upstream to be fetched once and then served from cache, got %d fetches", hits)
+	}
+	if !testContentStore.Exists(&MetaObject{Oid: oid}) {
+		t.Errorf("expected upstream content to be cached locally")
+	}
+
+	ranged := register("ranged upstream content")
+	req, err := http.NewRequest("GET", lfsServer.URL+"/user/repo/objects/"+ranged, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	req.SetBasicAuth(testUser, testPass)
+	req.Header.Set("Accept", contentMediaType)
+	req.Header.Set("Range", "bytes=7-")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("response error: %s", err)
+	}
+	if res.StatusCode != 206 {
+		t.Fatalf("expected status 206, got %d", res.StatusCode)
+	}
+	if by, _ := ioutil.ReadAll(res.Body); string(by) != "upstream content" {
+		t.Errorf("expected ranged content, got: %s", string(by))
+	}
+
+	missing := fmt.Sprintf("%x", sha256.Sum256([]byte("missing upstream")))
+	if _, err := testMetaStore.Put(&RequestVars{Oid: missing, Size: 16}); err != nil {
+		t.Fatalf("error seeding meta store: %s", err)
+	}
+	res, err = api("GET", "/user/repo/objects/"+missing, contentMediaType, testUser, testPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 404 {
+		t.Errorf("expected status 404 when upstream misses too, got %d", res.StatusCode)
+	}
+
+	// Batch downloads get an action for content only the upstream has
+	batched := "batched upstream content"
+	oid = register(batched)
+	body := fmt.Sprintf(`{"operation":"download","objects":[{"oid":"%s","size":%d}]}`, oid, len(batched))
+	res, err = api("POST", "/user/repo/objects/batch", metaMediaType, testUser, testPass, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	var batch BatchResponse
+	if err := json.NewDecoder(res.Body).Decode(&batch); err != nil || len(batch.Objects) != 1 {
+		t.Fatalf("expected a batch response with one object, got %v", err)
+	}
+	download := batch.Objects[0].Actions["download"]
+	if batch.Objects[0].Error != nil || download == nil {
+		t.Fatalf("expected a download action for upstream content, got %+v", batch.Objects[0])
+	}
+	req, err = http.NewRequest("GET", lfsServer.URL+download.Href[strings.Index(download.Href, "/user/"):], nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	for k, v := range download.Header {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Accept", contentMediaType)
+	if res, err = http.DefaultClient.Do(req); err != nil {
+		t.Fatalf("response error: %s", err)
+	}
+	if by, _ := ioutil.ReadAll(res.Body); res.StatusCode != 200 || string(by) != batched {
+		t.Errorf("expected the download action to serve upstream content, got %d %s", res.StatusCode, by)
+	}
+}
+
+func TestGetFromUpstreamConcurrent(t *testing.T) {
+	data := "TestGetFromUpstreamConcurrent content"
+	oid := fmt.Sprintf("%x", sha256.Sum256([]byte(data)))
+	if _, err := testMetaStore.Put(&RequestVars{Oid: oid, Size: int64(len(data))}); err != nil {
+		t.Fatalf("error seeding meta store: %s", err)
+	}
+
+	var hits int32
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		<-release
+		fmt.Fprint(w, data)
+	}))
+	defer upstream.Close()
+
+	defer func(url string) { Config.Upstream = url }(Config.Upstream)
+	Config.Upstream = upstream.URL
+
+	var wg sync.WaitGroup
+	bodies := make([]string, 3)
+	for i := range bodies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			res, err := api("GET", "/user/repo/objects/"+oid, contentMediaType, testUser, testPass, nil)
+			if err != nil {
+				return
+			}
+			by, _ := ioutil.ReadAll(res.Body)
+			bodies[i] = string(by)
+		}(i)
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	for i, body := range bodies {
+		if body != data {
+			t.Errorf("expected download %d to get the content, got %q", i, body)
+		}
+	}
+	if n := atomic.LoadInt32(&hits); n != 1 {
+		t.Errorf("expected concurrent downloads to share one fetch, got %d", n)
+	}
+	if !testContentStore.Exists(&MetaObject{Oid: oid}) {
+		t.Errorf("expected upstream content to be cached locally")
+	}
+}
+
+func TestGetFromUpstreamUncacheable(t *testing.T) {
+	data := "TestGetFromUpstreamUncacheable content"
+	oid := fmt.Sprintf("%x", sha256.Sum256([]byte(data)))
+	if _, err := testMetaStore.Put(&RequestVars{Oid: oid, Size: int64(len(data))}); err != nil {
+		t.Fatalf("error seeding meta store: %s", err)
+	}
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, data)
+	}))
+	defer upstream.Close()
+
+	defer func(url string) { Config.Upstream = url }(Config.Upstream)
+	Config.Upstream = upstream.URL
+
+	// An upload of the object in progress keeps it from being cached
+	tmp := filepath.Join("lfs-content-test", transformKey(oid)) + tmpSuffix
+	os.MkdirAll(filepath.Dir(tmp), 0750)
+	if err := ioutil.WriteFile(tmp, nil, 0640); err != nil {
+		t.Fatalf("error placing upload: %s", err)
+	}
+	defer os.Remove(tmp)
+
+	res, err := api("GET", "/user/repo/objects/"+oid, contentMediaType, testUser, testPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+	if by, _ := ioutil.ReadAll(res.Body); string(by) != data {
+		t.Errorf("expected the content to be passed through, got %q", by)
+	}
+	if testContentStore.Exists(&MetaObject{Oid: oid}) {
+		t.Errorf("expected content that could not be cached to not be stored")
+	}
+}
+
+func TestVerifyAction(t *testing.T) {
+	defer func(url, host, scheme string) {
+		Config.VerifyURL, Config.Host, Config.Scheme = url, host, scheme
+	}(Config.VerifyURL, Config.Host, Config.Scheme)
+	Config.Host, Config.Scheme = "lfs.example.com", "https"
+
+	rv := &RequestVars{Oid: contentOid, Authorization: "Basic secret"}
+
+	Config.VerifyURL = ""
+	action := verifyAction(rv)
+	if action.Href != "https://lfs.example.com"+Config.BasePrefix()+"/verify/"+contentOid {
+		t.Errorf("expected the built-in verify endpoint, got %s", action.Href)
+	}
+	if action.Header["Authorization"] != "Basic secret" {
+		t.Errorf("expected the client's credentials to be passed to the built-in verify, got %v", action.Header)
+	}
+
+	Config.VerifyURL = "https://verifier.example.com/lfs/"
+	action = verifyAction(rv)
+	if action.Href != "https://verifier.example.com/lfs/"+contentOid {
+		t.Errorf("expected the external verifier, got %s", action.Href)
+	}
+	if _, ok := action.Header["Authorization"]; ok {
+		t.Errorf("expected the client's credentials to not be sent to the external verifier")
+	}
+}
+
 func TestGetAuthedWithRange(t *testing.T) {
 	req, err := http.NewRequest("GET", lfsServer.URL+"/user/repo/objects/"+contentOid, nil)
 	if err != nil {
@@ -249,156 +687,2823 @@ func TestPut(t *testing.T) {
 	}
 }
 
-func TestMediaTypesRequired(t *testing.T) {
-	m := []string{"GET", "PUT", "POST", "HEAD"}
-	for _, method := range m {
-		res, err := api(method, "/user/repo/objects/"+contentOid, "", testUser, testPass, nil)
+func TestPutConflict(t *testing.T) {
+	oid, size := seedObject(t, "immutable content")
+
+	for _, tc := range []struct {
+		body   string
+		status int
+	}{{"immutable content", 200}, {"mutated!! content", 409}} {
+		req, err := http.NewRequest("PUT", lfsServer.URL+"/user/repo/objects/"+oid, bytes.NewBufferString(tc.body))
 		if err != nil {
 			t.Fatalf("request error: %s", err)
 		}
-		if res.StatusCode != 404 {
-			t.Fatalf("expected status 404, got %d", res.StatusCode)
+		req.SetBasicAuth(testUser, testPass)
+		req.Header.Set("Accept", contentMediaType)
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("response error: %s", err)
+		}
+		if res.StatusCode != tc.status {
+			t.Fatalf("expected status %d for %q, got %d", tc.status, tc.body, res.StatusCode)
 		}
 	}
+
+	if _, err := testMetaStore.Get(&RequestVars{Oid: oid}); err != nil {
+		t.Fatalf("expected meta to be kept after a conflicting upload, got: %s", err)
+	}
+	r, err := testContentStore.Get(&MetaObject{Oid: oid, Size: size}, 0)
+	if err != nil {
+		t.Fatalf("error retreiving from content store: %s", err)
+	}
+	defer r.Close()
+	if c, _ := ioutil.ReadAll(r); string(c) != "immutable content" {
+		t.Fatalf("expected stored content to be unchanged, got `%s`", string(c))
+	}
 }
 
-func TestMediaTypesParsed(t *testing.T) {
-	accept := contentMediaType + "; charset=utf-8"
-	res, err := api("GET", "/user/repo/objects/"+contentOid, accept, testUser, testPass, nil)
+func TestPutChecksum(t *testing.T) {
+	data := "checksummed content"
+	oid := fmt.Sprintf("%x", sha256.Sum256([]byte(data)))
+	if _, err := testMetaStore.Put(&RequestVars{Oid: oid, Size: int64(len(data))}); err != nil {
+		t.Fatalf("error seeding meta store: %s", err)
+	}
+
+	for _, tc := range []struct {
+		checksum string
+		status   int
+		stored   bool
+	}{
+		{"crc32=00000000", 400, false},
+		{"crc32", 400, false},
+		{"crc32=59b6af81", 200, true},
+	} {
+		req, err := http.NewRequest("PUT", lfsServer.URL+"/user/repo/objects/"+oid, bytes.NewBufferString(data))
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		req.SetBasicAuth(testUser, testPass)
+		req.Header.Set("Accept", contentMediaType)
+		req.Header.Set("X-LFS-Checksum", tc.checksum)
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("response error: %s", err)
+		}
+		if res.StatusCode != tc.status {
+			t.Fatalf("expected status %d for %q, got %d", tc.status, tc.checksum, res.StatusCode)
+		}
+		if stored := testContentStore.Exists(&MetaObject{Oid: oid}); stored != tc.stored {
+			t.Errorf("expected content stored: %t after %q", tc.stored, tc.checksum)
+		}
+	}
+}
+
+func TestPutStrictContentType(t *testing.T) {
+	defer func(strict string) { Config.StrictUpload = strict }(Config.StrictUpload)
+	Config.StrictUpload = "true"
+
+	data := "strictly typed content"
+	oid := fmt.Sprintf("%x", sha256.Sum256([]byte(data)))
+	if _, err := testMetaStore.Put(&RequestVars{Oid: oid, Size: int64(len(data))}); err != nil {
+		t.Fatalf("error seeding meta store: %s", err)
+	}
+
+	for _, tc := range []struct {
+		contentType string
+		status      int
+	}{
+		{"application/x-www-form-urlencoded", 415},
+		{"text/plain; charset=utf-8", 415},
+		{`text/plain; name="quoted"`, 415},
+		{"", 200},
+		{"application/octet-stream", 200},
+		{"application/octet-stream; charset=binary", 200},
+	} {
+		req, err := http.NewRequest("PUT", lfsServer.URL+"/user/repo/objects/"+oid, bytes.NewBufferString(data))
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		req.SetBasicAuth(testUser, testPass)
+		req.Header.Set("Accept", contentMediaType)
+		if tc.contentType != "" {
+			req.Header.Set("Content-Type", tc.contentType)
+		}
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("response error: %s", err)
+		}
+		if res.StatusCode != tc.status {
+			t.Errorf("expected status %d for %q, got %d", tc.status, tc.contentType, res.StatusCode)
+		}
+		if tc.status == 415 && testContentStore.Exists(&MetaObject{Oid: oid}) {
+			t.Fatalf("expected content to not be stored after %q", tc.contentType)
+		}
+		if tc.status == 415 {
+			var e struct{ Message string }
+			if err := json.NewDecoder(res.Body).Decode(&e); err != nil || e.Message == "" {
+				t.Errorf("expected a JSON error message for %q, got %v", tc.contentType, err)
+			}
+		}
+	}
+
+	// Lenient by default
+	Config.StrictUpload = "false"
+	req, _ := http.NewRequest("PUT", lfsServer.URL+"/user/repo/objects/"+oid, bytes.NewBufferString(data))
+	req.SetBasicAuth(testUser, testPass)
+	req.Header.Set("Accept", contentMediaType)
+	req.Header.Set("Content-Type", "text/plain")
+	res, err := http.DefaultClient.Do(req)
 	if err != nil {
-		t.Fatalf("request error: %s", err)
+		t.Fatalf("response error: %s", err)
 	}
 	if res.StatusCode != 200 {
-		t.Fatalf("expected status 200, got %d", res.StatusCode)
+		t.Errorf("expected any content type to be accepted when not strict, got %d", res.StatusCode)
 	}
 }
 
-func TestLocksList(t *testing.T) {
-	res, err := api("GET", "/user/repo/locks", metaMediaType, testUser, testPass, nil)
+func TestPutFilename(t *testing.T) {
+	data := "named content"
+	oid := fmt.Sprintf("%x", sha256.Sum256([]byte(data)))
+	if _, err := testMetaStore.Put(&RequestVars{Oid: oid, Size: int64(len(data))}); err != nil {
+		t.Fatalf("error seeding meta store: %s", err)
+	}
+
+	req, err := http.NewRequest("PUT", lfsServer.URL+"/user/repo/objects/"+oid, bytes.NewBufferString(data))
 	if err != nil {
 		t.Fatalf("request error: %s", err)
 	}
+	req.SetBasicAuth(testUser, testPass)
+	req.Header.Set("Accept", contentMediaType)
+	req.Header.Set("X-LFS-Filename", "assets/logo.png")
 
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("response error: %s", err)
+	}
 	if res.StatusCode != 200 {
 		t.Fatalf("expected status 200, got %d", res.StatusCode)
 	}
 
-	body, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		t.Fatalf("expected response to contain content, got error: %s", err)
-	}
+	for _, tc := range []struct {
+		path, accept, user, pass, disposition string
+	}{
+		{"/user/repo/objects/" + oid, contentMediaType, testUser, testPass, "attachment"},
+		{"/mgmt/raw/" + oid, "", testAdminUser, testAdminPass, "attachment"},
+		{"/mgmt/raw/" + oid + "?inline=1", "", testAdminUser, testAdminPass, "inline"},
+	} {
+		res, err := api("GET", tc.path, tc.accept, tc.user, tc.pass, nil)
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		if res.StatusCode != 200 {
+			t.Fatalf("expected status 200 for %s, got %d", tc.path, res.StatusCode)
+		}
 
-	var list LockList
-	if err := json.Unmarshal(body, &list); err != nil {
-		t.Fatalf("expected response body to be LockList, got error: %s", err)
-	}
-	if len(list.Locks) != 1 {
-		t.Errorf("expected returned lock count to match, got: %d", len(list.Locks))
-	}
-	if list.Locks[0].Id != lockId {
-		t.Errorf("expected lockId to match, got: %s", list.Locks[0].Id)
+		disposition, params, err := mime.ParseMediaType(res.Header.Get("Content-Disposition"))
+		if err != nil {
+			t.Fatalf("expected Content-Disposition for %s, got error: %s", tc.path, err)
+		}
+		if disposition != tc.disposition || params["filename"] != "logo.png" {
+			t.Errorf("expected %s with filename logo.png for %s, got %s %v", tc.disposition, tc.path, disposition, params)
+		}
 	}
-}
 
-func TestLocksListUnAuthed(t *testing.T) {
-	res, err := api("GET", "/user/repo/locks", metaMediaType, "", "", nil)
+	res, err = api("GET", "/user/repo/objects/"+contentOid, contentMediaType, testUser, testPass, nil)
 	if err != nil {
 		t.Fatalf("request error: %s", err)
 	}
-
-	if res.StatusCode != 401 {
-		t.Fatalf("expected status 401, got %d", res.StatusCode)
+	if _, params, _ := mime.ParseMediaType(res.Header.Get("Content-Disposition")); params["filename"] != contentOid {
+		t.Errorf("expected unnamed object to be named by its oid, got %v", params)
 	}
 }
 
-func TestLocksVerify(t *testing.T) {
-	buf := bytes.NewBufferString(fmt.Sprintf(`{"cursor": "", "limit": 0}`))
-	res, err := api("POST", "/user/repo/locks/verify", metaMediaType, testUser, testPass, buf)
+func TestPutQuarantine(t *testing.T) {
+	defer func(quarantine string) { Config.Quarantine = quarantine }(Config.Quarantine)
+	Config.Quarantine = "true"
+
+	data := "quarantined content"
+	oid := fmt.Sprintf("%x", sha256.Sum256([]byte(data)))
+	if _, err := testMetaStore.Put(&RequestVars{Oid: oid, Size: int64(len(data))}); err != nil {
+		t.Fatalf("error seeding meta store: %s", err)
+	}
+
+	res, err := api("PUT", "/user/repo/objects/"+oid, contentMediaType, testUser, testPass, bytes.NewBufferString(data))
 	if err != nil {
 		t.Fatalf("request error: %s", err)
 	}
-
 	if res.StatusCode != 200 {
 		t.Fatalf("expected status 200, got %d", res.StatusCode)
 	}
 
-	body, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		t.Fatalf("expected response to contain content, got error: %s", err)
+	for _, accept := range []string{contentMediaType, "multipart/mixed"} {
+		res, err = api("GET", "/user/repo/objects/"+oid, accept, testUser, testPass, nil)
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		if res.StatusCode != 409 {
+			t.Fatalf("expected status 409 for quarantined %s download, got %d", accept, res.StatusCode)
+		}
+	}
+
+	batch := fmt.Sprintf(`{"operation":"download","objects":[{"oid":"%s","size":%d}]}`, oid, len(data))
+	res, err = api("POST", "/bilbo/repo/objects/batch", metaMediaType, testUser, testPass, bytes.NewBufferString(batch))
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	var br BatchResponse
+	if err := json.NewDecoder(res.Body).Decode(&br); err != nil {
+		t.Fatalf("expected batch response, got error: %s", err)
+	}
+	if len(br.Objects) != 1 || br.Objects[0].Error == nil || br.Objects[0].Error.Code != 409 {
+		t.Fatalf("expected a 409 object error for the quarantined object, got %+v", br.Objects)
+	}
+
+	if res, err := api("GET", "/mgmt/object/approve/"+oid, "", testAdminUser, testAdminPass, nil); err != nil || res.StatusCode == 200 {
+		t.Fatalf("expected approving with GET to be refused, got %v %v", res, err)
+	}
+	res, err = api("POST", "/mgmt/object/approve/"+oid, "", testAdminUser, testAdminPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 200 {
+		t.Fatalf("expected status 200 approving object, got %d", res.StatusCode)
+	}
+
+	res, err = api("GET", "/user/repo/objects/"+oid, contentMediaType, testUser, testPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 200 {
+		t.Fatalf("expected approved object to download, got status %d", res.StatusCode)
+	}
+	if by, _ := ioutil.ReadAll(res.Body); string(by) != data {
+		t.Errorf("expected content `%s`, got: %s", data, string(by))
+	}
+}
+
+func TestPutQuarantinedBeforeStored(t *testing.T) {
+	defer func(quarantine string) { Config.Quarantine = quarantine }(Config.Quarantine)
+	Config.Quarantine = "true"
+
+	data := "TestPutQuarantinedBeforeStored content"
+	oid := fmt.Sprintf("%x", sha256.Sum256([]byte(data)))
+	if _, err := testMetaStore.Put(&RequestVars{Oid: oid, Size: int64(len(data))}); err != nil {
+		t.Fatalf("error seeding meta store: %s", err)
+	}
+
+	body, pw := io.Pipe()
+	req, err := http.NewRequest("PUT", lfsServer.URL+"/user/repo/objects/"+oid, body)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	req.SetBasicAuth(testUser, testPass)
+	req.Header.Set("Accept", contentMediaType)
+	req.ContentLength = int64(len(data))
+
+	done := make(chan int)
+	go func() {
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			done <- 0
+			return
+		}
+		res.Body.Close()
+		done <- res.StatusCode
+	}()
+
+	// The object is held back while its content is still being uploaded
+	pw.Write([]byte(data[:10]))
+	time.Sleep(50 * time.Millisecond)
+	meta, err := testMetaStore.UnsafeGet(&RequestVars{Oid: oid})
+	if err != nil || !meta.Quarantined {
+		t.Errorf("expected the object to be quarantined before its content is stored, got %+v %v", meta, err)
+	}
+
+	pw.Write([]byte(data[10:]))
+	pw.Close()
+	if status := <-done; status != 200 {
+		t.Fatalf("expected status 200, got %d", status)
+	}
+}
+
+func TestGracePeriod(t *testing.T) {
+	defer func(grace string) { Config.GracePeriod = grace }(Config.GracePeriod)
+	Config.GracePeriod = "1h"
+
+	recent, _ := seedObject(t, "TestGracePeriod recent")
+	old, _ := seedObject(t, "TestGracePeriod old")
+	created := time.Now().Add(-2 * time.Hour)
+	if _, err := testMetaStore.updateObject(old, func(meta *MetaObject) { meta.CreatedAt = &created }); err != nil {
+		t.Fatalf("error backdating object: %s", err)
+	}
+
+	download := func(oid string) int {
+		res, err := api("GET", "/user/repo/objects/"+oid, contentMediaType, testUser, testPass, nil)
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		return res.StatusCode
+	}
+
+	if status := download(recent); status != 409 {
+		t.Errorf("expected an object within the grace period to get 409, got %d", status)
+	}
+	if status := download(old); status != 200 {
+		t.Errorf("expected an object past the grace period to download, got %d", status)
+	}
+
+	batch := fmt.Sprintf(`{"operation":"download","objects":[{"oid":"%s","size":22}]}`, recent)
+	res, err := api("POST", "/bilbo/repo/objects/batch", metaMediaType, testUser, testPass, bytes.NewBufferString(batch))
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	var br BatchResponse
+	if err := json.NewDecoder(res.Body).Decode(&br); err != nil {
+		t.Fatalf("expected batch response, got error: %s", err)
+	}
+	if len(br.Objects) != 1 || br.Objects[0].Error == nil || br.Objects[0].Error.Code != 409 {
+		t.Errorf("expected a 409 object error within the grace period, got %+v", br.Objects)
+	}
+
+	res, err = api("POST", "/mgmt/object/approve/"+recent, "", testAdminUser, testAdminPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 200 {
+		t.Fatalf("expected status 200 approving object, got %d", res.StatusCode)
+	}
+	if status := download(recent); status != 200 {
+		t.Errorf("expected an approved object to download within the grace period, got %d", status)
+	}
+}
+
+func TestMediaTypesRequired(t *testing.T) {
+	m := []string{"GET", "PUT", "POST", "HEAD"}
+	for _, method := range m {
+		res, err := api(method, "/user/repo/objects/"+contentOid, "", testUser, testPass, nil)
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		if res.StatusCode != 404 {
+			t.Fatalf("expected status 404, got %d", res.StatusCode)
+		}
+	}
+}
+
+func TestMediaTypesParsed(t *testing.T) {
+	accept := contentMediaType + "; charset=utf-8"
+	res, err := api("GET", "/user/repo/objects/"+contentOid, accept, testUser, testPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+}
+
+func TestLocksList(t *testing.T) {
+	res, err := api("GET", "/user/repo/locks", metaMediaType, testUser, testPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+
+	if res.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("expected response to contain content, got error: %s", err)
+	}
+
+	var list LockList
+	if err := json.Unmarshal(body, &list); err != nil {
+		t.Fatalf("expected response body to be LockList, got error: %s", err)
+	}
+	if len(list.Locks) != 1 {
+		t.Errorf("expected returned lock count to match, got: %d", len(list.Locks))
+	}
+	if list.Locks[0].Id != lockId {
+		t.Errorf("expected lockId to match, got: %s", list.Locks[0].Id)
+	}
+}
+
+func TestLocksListUnAuthed(t *testing.T) {
+	res, err := api("GET", "/user/repo/locks", metaMediaType, "", "", nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+
+	if res.StatusCode != 401 {
+		t.Fatalf("expected status 401, got %d", res.StatusCode)
+	}
+}
+
+func TestLocksVerify(t *testing.T) {
+	buf := bytes.NewBufferString(fmt.Sprintf(`{"cursor": "", "limit": 0}`))
+	res, err := api("POST", "/user/repo/locks/verify", metaMediaType, testUser, testPass, buf)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+
+	if res.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("expected response to contain content, got error: %s", err)
 	}
 
 	var list VerifiableLockList
 	if err := json.Unmarshal(body, &list); err != nil {
 		t.Fatalf("expected response body to be VerifiableLockList, got error: %s", err)
 	}
-}
+}
+
+func TestLocksVerifyUnAuthed(t *testing.T) {
+	buf := bytes.NewBufferString(fmt.Sprintf(`{"cursor": "", "limit": 0}`))
+	res, err := api("POST", "/user/repo/locks/verify", metaMediaType, "", "", buf)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+
+	if res.StatusCode != 401 {
+		t.Fatalf("expected status 401, got %d", res.StatusCode)
+	}
+}
+
+func TestLock(t *testing.T) {
+	path := "TestLock"
+	lock, err := createLock(testUser, testPass, path)
+	if err != nil {
+		t.Fatalf("create lock error: %s", err)
+	}
+	if lock == nil {
+		t.Errorf("expected lock to be created, got: %s", lock)
+	}
+	if lock.Owner.Name != testUser {
+		t.Errorf("expected lock owner to be match, got: %s", lock.Owner.Name)
+	}
+	if lock.Path != path {
+		t.Errorf("expected lock path to be match, got: %s", lock.Path)
+	}
+}
+
+func TestLockExists(t *testing.T) {
+	l, err := createLock(testUser, testPass, "TestLockExists")
+	if err != nil {
+		t.Fatalf("create lock error: %s", err)
+	}
+
+	buf := bytes.NewBufferString(fmt.Sprintf(`{"path":"%s"}`, l.Path))
+	res, err := api("POST", "/user/repo/locks", metaMediaType, testUser1, testPass1, buf)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+
+	if res.StatusCode != 409 {
+		t.Fatalf("expected status 409, got %d", res.StatusCode)
+	}
+}
+
+func TestLockPathVariants(t *testing.T) {
+	l, err := createLock(testUser, testPass, "./TestLockPathVariants/cover.psd")
+	if err != nil {
+		t.Fatalf("create lock error: %s", err)
+	}
+	if l.Path != "TestLockPathVariants/cover.psd" {
+		t.Errorf("expected the lock path to be normalized, got %q", l.Path)
+	}
+
+	for _, p := range []string{"TestLockPathVariants/cover.psd", `TestLockPathVariants\\cover.psd`, "TestLockPathVariants//x/../cover.psd"} {
+		buf := bytes.NewBufferString(fmt.Sprintf(`{"path":"%s"}`, p))
+		res, err := api("POST", "/user/repo/locks", metaMediaType, testUser1, testPass1, buf)
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		if res.StatusCode != 409 {
+			t.Errorf("expected %s to conflict with the lock, got %d", p, res.StatusCode)
+		}
+	}
+
+	buf := bytes.NewBufferString(`{"paths":["./TestLockPathVariants/cover.psd"]}`)
+	res, err := api("POST", "/user/repo/locks/verify-paths", metaMediaType, testUser, testPass, buf)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	var verified VerifyPathsResponse
+	if err := json.NewDecoder(res.Body).Decode(&verified); err != nil || len(verified.Paths) != 1 {
+		t.Fatalf("expected one verified path, got %v", err)
+	}
+	if p := verified.Paths[0]; !p.Locked || p.Path != "./TestLockPathVariants/cover.psd" {
+		t.Errorf("expected the variant to be reported locked as sent, got %+v", p)
+	}
+
+	res, err = api("GET", "/user/repo/locks?path=./TestLockPathVariants/cover.psd", metaMediaType, testUser, testPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	var list LockList
+	if err := json.NewDecoder(res.Body).Decode(&list); err != nil || len(list.Locks) != 1 || list.Locks[0].Id != l.Id {
+		t.Errorf("expected the lock to be found by a variant of its path, got %+v %v", list.Locks, err)
+	}
+}
+
+func TestRelock(t *testing.T) {
+	l, err := createLock(testUser, testPass, "TestRelock")
+	if err != nil {
+		t.Fatalf("create lock error: %s", err)
+	}
+
+	relock := func() *http.Response {
+		buf := bytes.NewBufferString(fmt.Sprintf(`{"path":"%s"}`, l.Path))
+		res, err := api("POST", "/user/repo/locks", metaMediaType, testUser, testPass, buf)
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		return res
+	}
+
+	res := relock()
+	if res.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+	var lockResponse LockResponse
+	if err := json.NewDecoder(res.Body).Decode(&lockResponse); err != nil {
+		t.Fatalf("expected response body to be LockResponse, got error: %s", err)
+	}
+	if lockResponse.Lock == nil || lockResponse.Lock.Id != l.Id {
+		t.Errorf("expected the existing lock %s, got %+v", l.Id, lockResponse.Lock)
+	}
+
+	defer func(relock string) { Config.RelockExisting = relock }(Config.RelockExisting)
+	Config.RelockExisting = "false"
+	if res := relock(); res.StatusCode != 409 {
+		t.Errorf("expected status 409 with relocking disabled, got %d", res.StatusCode)
+	}
+}
+
+func TestLockPathAllowlist(t *testing.T) {
+	defer func(lockPaths string) { Config.LockPaths = lockPaths }(Config.LockPaths)
+	Config.LockPaths = "assets/**"
+
+	if _, err := createLock(testUser, testPass, "assets/TestLockPathAllowlist.png"); err != nil {
+		t.Fatalf("expected lock on allowed path, got error: %s", err)
+	}
+
+	buf := bytes.NewBufferString(`{"path":"src/TestLockPathAllowlist.go"}`)
+	res, err := api("POST", "/user/repo/locks", metaMediaType, testUser, testPass, buf)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 403 {
+		t.Fatalf("expected status 403, got %d", res.StatusCode)
+	}
+
+	var lockResponse LockResponse
+	if err := json.NewDecoder(res.Body).Decode(&lockResponse); err != nil {
+		t.Fatalf("expected response body to be LockResponse, got error: %s", err)
+	}
+	if lockResponse.Lock != nil || !strings.Contains(lockResponse.Message, "assets/**") {
+		t.Errorf("expected message naming the lockable paths, got: %+v", lockResponse)
+	}
+}
+
+func TestLockLimit(t *testing.T) {
+	defer func(max string) { Config.MaxLocksPerUser = max }(Config.MaxLocksPerUser)
+
+	held, err := testMetaStore.LockCountByOwner(testUser1)
+	if err != nil {
+		t.Fatalf("expected locks to be counted, got: %s", err)
+	}
+	Config.MaxLocksPerUser = fmt.Sprint(held + 1)
+
+	if _, err := createLock(testUser1, testPass1, "TestLockLimit/under"); err != nil {
+		t.Fatalf("expected a user under the limit to lock, got: %s", err)
+	}
+
+	buf := bytes.NewBufferString(`{"path":"TestLockLimit/over"}`)
+	res, err := api("POST", "/user/repo/locks", metaMediaType, testUser1, testPass1, buf)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 403 {
+		t.Fatalf("expected a user at the limit to get 403, got %d", res.StatusCode)
+	}
+
+	Config.MaxLocksPerUser = fmt.Sprint(held + 2)
+
+	// Relocking a held path does not use up the allowance
+	buf = bytes.NewBufferString(`{"locks":[{"path":"TestLockLimit/under"},{"path":"TestLockLimit/batch"},{"path":"TestLockLimit/batch-over"}]}`)
+	res, err = api("POST", "/user/repo/locks/batch", metaMediaType, testUser1, testPass1, buf)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 207 {
+		t.Fatalf("expected status 207, got %d", res.StatusCode)
+	}
+
+	var batch BatchLockResponse
+	if err := json.NewDecoder(res.Body).Decode(&batch); err != nil {
+		t.Fatalf("expected response body to be BatchLockResponse, got error: %s", err)
+	}
+	if l := batch.Locks[0]; l.Lock == nil {
+		t.Errorf("expected the held lock to be returned, got %+v", l)
+	}
+	if l := batch.Locks[1]; l.Lock == nil {
+		t.Errorf("expected the lock under the limit to be created, got %+v", l)
+	}
+	if l := batch.Locks[2]; l.Lock != nil || l.Error == nil || l.Error.Code != 403 {
+		t.Errorf("expected the lock over the limit to get 403, got %+v", l)
+	}
+}
+
+func TestBatchLock(t *testing.T) {
+	buf := bytes.NewBufferString(`{"locks":[{"path":"./TestBatchLock//a"},{"path":"TestBatchLock/b","ref":{"name":"refs/heads/main"}}]}`)
+	res, err := api("POST", "/user/repo/locks/batch", metaMediaType, testUser, testPass, buf)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 201 {
+		t.Fatalf("expected status 201, got %d", res.StatusCode)
+	}
+
+	var batch BatchLockResponse
+	if err := json.NewDecoder(res.Body).Decode(&batch); err != nil {
+		t.Fatalf("expected response body to be BatchLockResponse, got error: %s", err)
+	}
+	if len(batch.Locks) != 2 {
+		t.Fatalf("expected a result per path, got %d", len(batch.Locks))
+	}
+	for _, l := range batch.Locks {
+		if l.Error != nil || l.Lock == nil || l.Lock.Path != l.Path || l.Lock.Owner.Name != testUser {
+			t.Errorf("expected %s to be locked, got %+v", l.Path, l)
+		}
+	}
+	if batch.Locks[0].Path != "TestBatchLock/a" {
+		t.Errorf("expected the result to name the stored path, got %q", batch.Locks[0].Path)
+	}
+	if ref := batch.Locks[1].Lock.Ref; ref != "refs/heads/main" {
+		t.Errorf("expected the ref to be stored, got %q", ref)
+	}
+
+	locks, err := testMetaStore.LocksByPath("repo", "")
+	if err != nil {
+		t.Fatalf("expected locks to be listed, got: %s", err)
+	}
+	if _, ok := locks["TestBatchLock/b"]; !ok {
+		t.Errorf("expected the locks to be stored")
+	}
+}
+
+func TestBatchLockConflict(t *testing.T) {
+	held, err := createLock(testUser1, testPass1, "TestBatchLockConflict/held")
+	if err != nil {
+		t.Fatalf("create lock error: %s", err)
+	}
+
+	buf := bytes.NewBufferString(`{"locks":[{"path":"TestBatchLockConflict/free"},{"path":"TestBatchLockConflict/held"},{"path":"TestBatchLockConflict/free"}]}`)
+	res, err := api("POST", "/user/repo/locks/batch", metaMediaType, testUser, testPass, buf)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 207 {
+		t.Fatalf("expected status 207, got %d", res.StatusCode)
+	}
+
+	var batch BatchLockResponse
+	if err := json.NewDecoder(res.Body).Decode(&batch); err != nil {
+		t.Fatalf("expected response body to be BatchLockResponse, got error: %s", err)
+	}
+	if len(batch.Locks) != 3 {
+		t.Fatalf("expected a result per path, got %d", len(batch.Locks))
+	}
+	if l := batch.Locks[0]; l.Lock == nil || l.Error != nil {
+		t.Errorf("expected the free path to be locked, got %+v", l)
+	}
+	if l := batch.Locks[1]; l.Lock != nil || l.Error == nil || l.Error.Code != 409 {
+		t.Errorf("expected the path locked by another user to conflict, got %+v", l)
+	}
+	if l := batch.Locks[2]; l.Lock == nil || batch.Locks[0].Lock == nil || l.Lock.Id != batch.Locks[0].Lock.Id {
+		t.Errorf("expected a path requested twice to get the same lock, got %+v", l)
+	}
+
+	locks, err := testMetaStore.LocksByPath("repo", "")
+	if err != nil {
+		t.Fatalf("expected locks to be listed, got: %s", err)
+	}
+	if l := locks["TestBatchLockConflict/held"]; l.Id != held.Id {
+		t.Errorf("expected the held lock to be kept, got %+v", l)
+	}
+}
+
+func TestBatchLockStoreError(t *testing.T) {
+	// Locks that cannot be read fail the transaction partway
+	corrupt := []byte("not json")
+	setLocks := func(data []byte) {
+		err := testMetaStore.db.Update(func(tx *bolt.Tx) error {
+			if data == nil {
+				return tx.Bucket(locksBucket).Delete([]byte("batch-rollback"))
+			}
+			return tx.Bucket(locksBucket).Put([]byte("batch-rollback"), data)
+		})
+		if err != nil {
+			t.Fatalf("error writing locks: %s", err)
+		}
+	}
+	setLocks(corrupt)
+	defer setLocks(nil)
+
+	buf := bytes.NewBufferString(`{"locks":[{"path":"TestBatchLockStoreError/a"},{"path":"TestBatchLockStoreError/b"}]}`)
+	res, err := api("POST", "/user/batch-rollback/locks/batch", metaMediaType, testUser, testPass, buf)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 500 {
+		t.Fatalf("expected status 500, got %d", res.StatusCode)
+	}
+
+	var batch BatchLockResponse
+	if err := json.NewDecoder(res.Body).Decode(&batch); err != nil {
+		t.Fatalf("expected response body to be BatchLockResponse, got error: %s", err)
+	}
+	if len(batch.Locks) != 0 || batch.Message == "" {
+		t.Errorf("expected only an error message, got %+v", batch)
+	}
+
+	testMetaStore.db.View(func(tx *bolt.Tx) error {
+		if data := tx.Bucket(locksBucket).Get([]byte("batch-rollback")); !bytes.Equal(data, corrupt) {
+			t.Errorf("expected the locks to be left as they were, got %q", data)
+		}
+		return nil
+	})
+}
+
+func TestLocksVerifySkipsExpired(t *testing.T) {
+	expires := time.Now().Add(-time.Second)
+	lock := Lock{Id: randomLockId(), Path: "TestLocksVerifySkipsExpired", Owner: User{Name: testUser}, LockedAt: time.Now(), ExpiresAt: &expires}
+	if err := testMetaStore.AddLocks("repo", lock); err != nil {
+		t.Fatalf("error adding lock: %s", err)
+	}
+
+	res, err := api("POST", "/user/repo/locks/verify", metaMediaType, testUser, testPass, bytes.NewBufferString("{}"))
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	var list VerifiableLockList
+	if err := json.NewDecoder(res.Body).Decode(&list); err != nil {
+		t.Fatalf("expected response body to be VerifiableLockList, got error: %s", err)
+	}
+	for _, l := range append(list.Ours, list.Theirs...) {
+		if l.Id == lock.Id {
+			t.Errorf("expected expired lock to be skipped by verify")
+		}
+	}
+}
+
+func TestLocksVerifyByRef(t *testing.T) {
+	onMain := Lock{Id: randomLockId(), Path: "TestLocksVerifyByRef/main", Owner: User{Name: testUser}, LockedAt: time.Now(), Ref: "refs/heads/main"}
+	other := Lock{Id: randomLockId(), Path: "TestLocksVerifyByRef/other", Owner: User{Name: testUser}, LockedAt: time.Now(), Ref: "refs/heads/other"}
+	if err := testMetaStore.AddLocks("repo", onMain, other); err != nil {
+		t.Fatalf("error adding locks: %s", err)
+	}
+
+	verify := func(body string) map[string]bool {
+		res, err := api("POST", "/user/repo/locks/verify", metaMediaType, testUser, testPass, bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		var list VerifiableLockList
+		if err := json.NewDecoder(res.Body).Decode(&list); err != nil {
+			t.Fatalf("expected response body to be VerifiableLockList, got error: %s", err)
+		}
+		ids := make(map[string]bool)
+		for _, l := range append(list.Ours, list.Theirs...) {
+			ids[l.Id] = true
+		}
+		return ids
+	}
+
+	ids := verify(`{"ref":{"name":"refs/heads/main"}}`)
+	if !ids[onMain.Id] {
+		t.Errorf("expected lock on the requested ref to be verified")
+	}
+	if ids[other.Id] {
+		t.Errorf("expected lock on another ref to be excluded")
+	}
+
+	ids = verify("{}")
+	if !ids[onMain.Id] || !ids[other.Id] {
+		t.Errorf("expected locks on every ref without a ref or default ref")
+	}
+
+	defer func(ref string) { Config.LockRef = ref }(Config.LockRef)
+	Config.LockRef = "refs/heads/other"
+
+	ids = verify("{}")
+	if ids[onMain.Id] || !ids[other.Id] {
+		t.Errorf("expected only locks on the default ref without a ref")
+	}
+}
+
+func TestLockStoresRef(t *testing.T) {
+	buf := bytes.NewBufferString(`{"path":"TestLockStoresRef","ref":{"name":"refs/heads/main"}}`)
+	res, err := api("POST", "/user/repo/locks", metaMediaType, testUser, testPass, buf)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 201 {
+		t.Fatalf("expected status 201, got %d", res.StatusCode)
+	}
+
+	var lockResponse LockResponse
+	if err := json.NewDecoder(res.Body).Decode(&lockResponse); err != nil {
+		t.Fatalf("expected response body to be LockResponse, got error: %s", err)
+	}
+	if lockResponse.Lock == nil || lockResponse.Lock.Ref != "refs/heads/main" {
+		t.Fatalf("expected lock to store its ref, got: %+v", lockResponse.Lock)
+	}
+
+	// The same path may be locked again on another ref
+	buf = bytes.NewBufferString(`{"path":"TestLockStoresRef","ref":{"name":"refs/heads/other"}}`)
+	res, err = api("POST", "/user/repo/locks", metaMediaType, testUser, testPass, buf)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 201 {
+		t.Errorf("expected status 201 for a lock on another ref, got %d", res.StatusCode)
+	}
+}
+
+func TestLocksVerifyPaths(t *testing.T) {
+	ours, err := createLock(testUser, testPass, "TestLocksVerifyPaths/ours")
+	if err != nil {
+		t.Fatal(err)
+	}
+	theirs, err := createLock(testUser1, testPass1, "TestLocksVerifyPaths/theirs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := bytes.NewBufferString(`{"paths":["TestLocksVerifyPaths/ours","TestLocksVerifyPaths/theirs","TestLocksVerifyPaths/unlocked"]}`)
+	res, err := api("POST", "/user/repo/locks/verify-paths", metaMediaType, testUser, testPass, buf)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+
+	var verified VerifyPathsResponse
+	if err := json.NewDecoder(res.Body).Decode(&verified); err != nil {
+		t.Fatalf("expected response body to be VerifyPathsResponse, got error: %s", err)
+	}
+	if len(verified.Paths) != 3 {
+		t.Fatalf("expected 3 paths, got %d", len(verified.Paths))
+	}
+
+	expected := []struct {
+		locked bool
+		ours   bool
+		id     string
+	}{
+		{true, true, ours.Id},
+		{true, false, theirs.Id},
+		{false, false, ""},
+	}
+	for i, e := range expected {
+		p := verified.Paths[i]
+		if p.Locked != e.locked || p.Ours != e.ours {
+			t.Errorf("expected %s locked=%v ours=%v, got locked=%v ours=%v", p.Path, e.locked, e.ours, p.Locked, p.Ours)
+		}
+		if e.id == "" && p.Lock != nil {
+			t.Errorf("expected no lock for %s, got %+v", p.Path, p.Lock)
+		}
+		if e.id != "" && (p.Lock == nil || p.Lock.Id != e.id) {
+			t.Errorf("expected lock %s for %s, got %+v", e.id, p.Path, p.Lock)
+		}
+	}
+	if l := verified.Paths[1].Lock; l != nil && l.Owner.Name != testUser1 {
+		t.Errorf("expected the other user to own the lock, got %s", l.Owner.Name)
+	}
+}
+
+func TestLockRefresh(t *testing.T) {
+	defer func(ttl string) { Config.LockTTL = ttl }(Config.LockTTL)
+	Config.LockTTL = "1h"
+
+	l, err := createLock(testUser, testPass, "TestLockRefresh")
+	if err != nil {
+		t.Fatalf("create lock error: %s", err)
+	}
+	if l.ExpiresAt == nil || l.ExpiresAt.Sub(l.LockedAt) != time.Hour {
+		t.Fatalf("expected lock to expire after the TTL, got: %v", l.ExpiresAt)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	res, err := api("POST", "/user/repo/locks/"+l.Id+"/refresh", metaMediaType, testUser, testPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+	var lockResponse LockResponse
+	if err := json.NewDecoder(res.Body).Decode(&lockResponse); err != nil {
+		t.Fatalf("expected response body to be LockResponse, got error: %s", err)
+	}
+	if lockResponse.Lock == nil || lockResponse.Lock.ExpiresAt == nil || !lockResponse.Lock.ExpiresAt.After(*l.ExpiresAt) {
+		t.Errorf("expected refresh to extend the expiry past %s, got: %+v", l.ExpiresAt, lockResponse.Lock)
+	}
+
+	if err := testMetaStore.AddUser("sam", "gamgee"); err != nil {
+		t.Fatalf("error adding user: %s", err)
+	}
+	defer testMetaStore.DeleteUser("sam")
+	res, err = api("POST", "/user/repo/locks/"+l.Id+"/refresh", metaMediaType, "sam", "gamgee", nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 403 {
+		t.Errorf("expected status 403 refreshing another user's lock, got %d", res.StatusCode)
+	}
+}
+
+func TestLockUnAuthed(t *testing.T) {
+	buf := bytes.NewBufferString(fmt.Sprintf(`{"path":"%s"}`, "TestLockUnAuthed"))
+	res, err := api("POST", "/user/repo/locks", metaMediaType, "", "", buf)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+
+	if res.StatusCode != 401 {
+		t.Fatalf("expected status 401, got %d", res.StatusCode)
+	}
+}
+
+func TestUnlock(t *testing.T) {
+	l, err := createLock(testUser, testPass, "TestUnlock")
+	if err != nil {
+		t.Fatalf("create lock error: %s", err)
+	}
+
+	buf := bytes.NewBufferString(fmt.Sprintf(`{"force": %t}`, false))
+	res, err := api("POST", "/user/repo/locks/"+l.Id+"/unlock", metaMediaType, testUser, testPass, buf)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("expected response to contain content, got error: %s", err)
+	}
+
+	var unlockResponse UnlockResponse
+	if err := json.Unmarshal(body, &unlockResponse); err != nil {
+		t.Fatalf("expected response body to be UnlockResponse, got error: %s", err)
+	}
+	lock := unlockResponse.Lock
+	if lock == nil || lock.Id != l.Id {
+		t.Errorf("expected deleted lock to be returned, got: %s", lock)
+	}
+}
+
+func TestUnLockUnAuthed(t *testing.T) {
+	l, err := createLock(testUser, testPass, "TestUnLockUnAuthed")
+	if err != nil {
+		t.Fatalf("create lock error: %s", err)
+	}
+
+	buf := bytes.NewBufferString(fmt.Sprintf(`{"force": %t}`, false))
+	res, err := api("POST", "/user/repo/locks/"+l.Id+"/unlock", metaMediaType, "", "", buf)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+
+	if res.StatusCode != 401 {
+		t.Fatalf("expected status 401, got %d", res.StatusCode)
+	}
+}
+
+func TestUnlockNotOwner(t *testing.T) {
+	l, err := createLock(testUser, testPass, "TestUnlockNotOwner")
+	if err != nil {
+		t.Fatalf("create lock error: %s", err)
+	}
+
+	buf := bytes.NewBufferString(fmt.Sprintf(`{"force": %t}`, false))
+	res, err := api("POST", "/user/repo/locks/"+l.Id+"/unlock", metaMediaType, testUser1, testPass1, buf)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 403 {
+		t.Fatalf("expected status 403, got %d", res.StatusCode)
+	}
+}
+
+func TestUnlockNotOwnerForce(t *testing.T) {
+	l, err := createLock(testUser, testPass, "TestUnlockNotOwnerForce")
+	if err != nil {
+		t.Fatalf("create lock error: %s", err)
+	}
+
+	buf := bytes.NewBufferString(fmt.Sprintf(`{"force": %t}`, true))
+	res, err := api("POST", "/user/repo/locks/"+l.Id+"/unlock", metaMediaType, testUser1, testPass1, buf)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+}
+
+func TestBatchUnauthenticatedVsForbidden(t *testing.T) {
+	defer func(readOnly string) { Config.ReadOnly = readOnly }(Config.ReadOnly)
+	Config.ReadOnly = testUser
+
+	body := func() *bytes.Buffer {
+		return bytes.NewBufferString(fmt.Sprintf(`{"operation":"upload","objects":[{"oid":"%s","size":1234}]}`, nonExistingOid))
+	}
+
+	res, err := api("POST", "/bilbo/repo/objects/batch", metaMediaType, testUser, "wrong", body())
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 401 {
+		t.Fatalf("expected status 401 for invalid credentials, got %d", res.StatusCode)
+	}
+	if res.Header.Get("WWW-Authenticate") == "" {
+		t.Errorf("expected WWW-Authenticate header with the 401")
+	}
+
+	res, err = api("POST", "/bilbo/repo/objects/batch", metaMediaType, testUser, testPass, body())
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 403 {
+		t.Fatalf("expected status 403 for read-only user, got %d", res.StatusCode)
+	}
+	if res.Header.Get("WWW-Authenticate") != "" {
+		t.Errorf("expected no WWW-Authenticate header with the 403")
+	}
+	var lfsErr struct {
+		Message   string `json:"message"`
+		RequestID string `json:"request_id"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&lfsErr); err != nil {
+		t.Fatalf("expected LFS error body, got error: %s", err)
+	}
+	if lfsErr.Message == "" || lfsErr.RequestID == "" {
+		t.Errorf("expected message and request id in error body, got %+v", lfsErr)
+	}
+
+	res, err = api("PUT", "/user/repo/objects/"+contentOid, contentMediaType, testUser, testPass, bytes.NewBufferString(content))
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 403 {
+		t.Fatalf("expected status 403 for read-only upload, got %d", res.StatusCode)
+	}
+
+	res, err = api("GET", "/user/repo/objects/"+contentOid, contentMediaType, testUser, testPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 200 {
+		t.Fatalf("expected read-only user to download, got status %d", res.StatusCode)
+	}
+}
+
+func TestBatchPreloadHints(t *testing.T) {
+	Config.PreloadHints = "true"
+	defer func() { Config.PreloadHints = "false" }()
+
+	buf := bytes.NewBufferString(fmt.Sprintf(`{"operation":"download","objects":[{"oid":"%s","size":%d},{"oid":"%s","size":1234}]}`, contentOid, contentSize, nonExistingOid))
+	res, err := api("POST", "/user/repo/objects/batch", metaMediaType, testUser, testPass, buf)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+
+	var batch BatchResponse
+	if err := json.NewDecoder(res.Body).Decode(&batch); err != nil {
+		t.Fatalf("expected response body to be BatchResponse, got error: %s", err)
+	}
+
+	var expected []string
+	for _, obj := range batch.Objects {
+		if download, ok := obj.Actions["download"]; ok {
+			expected = append(expected, fmt.Sprintf("<%s>; rel=preload", download.Href))
+		}
+	}
+
+	links := res.Header["Link"]
+	if len(expected) != 1 || len(links) != len(expected) {
+		t.Fatalf("expected one Link header per download action, got: %v", links)
+	}
+	if links[0] != expected[0] {
+		t.Errorf("expected Link header %q, got %q", expected[0], links[0])
+	}
+}
+
+func TestBatchStandaloneTransfer(t *testing.T) {
+	Config.Standalone = "true"
+	defer func() {
+		Config.Standalone = "false"
+		Config.TrustedNet = ""
+	}()
+
+	batch := func(transfers string) BatchResponse {
+		buf := bytes.NewBufferString(fmt.Sprintf(`{"operation":"download","transfers":[%s],"objects":[{"oid":"%s","size":%d}]}`, transfers, contentOid, contentSize))
+		res, err := api("POST", "/user/repo/objects/batch", metaMediaType, testUser, testPass, buf)
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+
+		var br BatchResponse
+		if err := json.NewDecoder(res.Body).Decode(&br); err != nil {
+			t.Fatalf("expected response body to be BatchResponse, got error: %s", err)
+		}
+		return br
+	}
+
+	// Not a trusted client
+	Config.TrustedNet = "10.0.0.0/8"
+	br := batch(`"lfs-standalone-file","basic"`)
+	if br.Transfer != basicTransfer {
+		t.Errorf("expected untrusted client to get basic, got %q", br.Transfer)
+	}
+
+	Config.TrustedNet = "127.0.0.0/8,::1/128"
+	br = batch(`"lfs-standalone-file","basic"`)
+	if br.Transfer != standaloneTransfer {
+		t.Fatalf("expected trusted client to get %s, got %q", standaloneTransfer, br.Transfer)
+	}
+	href := br.Objects[0].Actions["download"].Href
+	if !strings.HasPrefix(href, "file://") || !strings.HasSuffix(href, "/"+transformKey(contentOid)) {
+		t.Errorf("expected file url to the content, got %s", href)
+	}
+
+	// Client prefers basic
+	br = batch(`"basic","lfs-standalone-file"`)
+	if br.Transfer != basicTransfer {
+		t.Errorf("expected client preference for basic to be honored, got %q", br.Transfer)
+	}
+	if href := br.Objects[0].Actions["download"].Href; !strings.HasPrefix(href, "http://") {
+		t.Errorf("expected http download link, got %s", href)
+	}
+}
+
+func TestBatchTransferNegotiation(t *testing.T) {
+	defer func(tus string) { Config.UseTus = tus }(Config.UseTus)
+
+	batch := func(operation, transfers string) BatchResponse {
+		body := fmt.Sprintf(`{"operation":"%s","objects":[{"oid":"%s","size":%d}]}`, operation, contentOid, contentSize)
+		if transfers != "" {
+			body = fmt.Sprintf(`{"operation":"%s","transfers":[%s],"objects":[{"oid":"%s","size":%d}]}`, operation, transfers, contentOid, contentSize)
+		}
+		res, err := api("POST", "/user/repo/objects/batch", metaMediaType, testUser, testPass, bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+
+		var br BatchResponse
+		if err := json.NewDecoder(res.Body).Decode(&br); err != nil {
+			t.Fatalf("expected response body to be BatchResponse, got error: %s", err)
+		}
+		return br
+	}
+
+	cases := []struct {
+		operation string
+		tus       string
+		transfers string
+		expected  string
+	}{
+		{"download", "false", "", ""},
+		{"download", "false", `"ssh","custom"`, basicTransfer},
+		{"upload", "false", `"tus","basic"`, basicTransfer},
+		{"upload", "true", `"tus","basic"`, tusTransfer},
+		{"upload", "true", `"basic","tus"`, basicTransfer},
+		{"download", "true", `"tus"`, basicTransfer},
+	}
+
+	for _, c := range cases {
+		Config.UseTus = c.tus
+		if br := batch(c.operation, c.transfers); br.Transfer != c.expected {
+			t.Errorf("expected %s with transfers [%s] and tus %s to negotiate %q, got %q", c.operation, c.transfers, c.tus, c.expected, br.Transfer)
+		}
+	}
+}
+
+func TestWhoami(t *testing.T) {
+	cases := []struct {
+		user, pass, role string
+	}{
+		{testUser, testPass, "user"},
+		{testAdminUser, testAdminPass, "admin"},
+	}
+
+	for _, c := range cases {
+		res, err := api("GET", "/api/whoami", "", c.user, c.pass, nil)
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		if res.StatusCode != 200 {
+			t.Fatalf("expected status 200, got %d", res.StatusCode)
+		}
+
+		var who WhoamiResponse
+		if err := json.NewDecoder(res.Body).Decode(&who); err != nil {
+			t.Fatalf("expected response body to be WhoamiResponse, got error: %s", err)
+		}
+		if who.Name != c.user {
+			t.Errorf("expected name %q, got %q", c.user, who.Name)
+		}
+		if who.Role != c.role {
+			t.Errorf("expected role %q, got %q", c.role, who.Role)
+		}
+	}
+}
+
+func TestWhoamiUsage(t *testing.T) {
+	usage := func() WhoamiUsage {
+		res, err := api("GET", "/api/whoami", "", testUser1, testPass1, nil)
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		var who WhoamiResponse
+		if err := json.NewDecoder(res.Body).Decode(&who); err != nil || who.Usage == nil {
+			t.Fatalf("expected response body to be WhoamiResponse with usage, got %+v %v", who, err)
+		}
+		return *who.Usage
+	}
+
+	before := usage()
+	for _, data := range []string{"TestWhoamiUsage one", "TestWhoamiUsage two", "TestWhoamiUsage deleted"} {
+		oid := fmt.Sprintf("%x", sha256.Sum256([]byte(data)))
+		if _, err := testMetaStore.Put(&RequestVars{Oid: oid, Size: int64(len(data)), Uploader: testUser1}); err != nil {
+			t.Fatalf("error seeding meta store: %s", err)
+		}
+		defer testMetaStore.Delete(&RequestVars{Oid: oid})
+		if data == "TestWhoamiUsage deleted" {
+			if _, err := testMetaStore.MarkPendingDelete(oid); err != nil {
+				t.Fatalf("error marking object: %s", err)
+			}
+		}
+	}
+
+	after := usage()
+	if after.Objects-before.Objects != 2 || after.Size-before.Size != 38 {
+		t.Errorf("expected usage to grow by the two objects uploaded, got %+v then %+v", before, after)
+	}
+}
+
+func TestWhoamiUnAuthed(t *testing.T) {
+	res, err := api("GET", "/api/whoami", "", "", "", nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 401 {
+		t.Fatalf("expected status 401, got %d", res.StatusCode)
+	}
+	if res.Header.Get("WWW-Authenticate") == "" {
+		t.Errorf("expected WWW-Authenticate header to be set")
+	}
+}
+
+func TestTrustAuthHeader(t *testing.T) {
+	defer func(header, proxy string) {
+		Config.TrustAuthHeader, Config.TrustedProxy = header, proxy
+	}(Config.TrustAuthHeader, Config.TrustedProxy)
+	Config.TrustAuthHeader = "X-Authenticated-User"
+
+	whoami := func(user string) (int, string) {
+		req, err := http.NewRequest("GET", lfsServer.URL+"/api/whoami", nil)
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		req.Header.Set("X-Authenticated-User", user)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		defer res.Body.Close()
+
+		var who WhoamiResponse
+		json.NewDecoder(res.Body).Decode(&who)
+		return res.StatusCode, who.Name
+	}
+
+	Config.TrustedProxy = "127.0.0.0/8,::1/128"
+	if status, name := whoami(strings.ToUpper(testUser)); status != 200 || name != testUser {
+		t.Errorf("expected the trusted proxy's user %q to be authenticated, got %d %q", testUser, status, name)
+	}
+	if status, _ := whoami("TestTrustAuthHeader unknown"); status != 401 {
+		t.Errorf("expected a user without a record to get 401, got %d", status)
+	}
+
+	// Spoofed by a client that is not a trusted proxy
+	Config.TrustedProxy = "10.0.0.0/8"
+	if status, _ := whoami(testUser); status != 401 {
+		t.Errorf("expected the header from an untrusted address to get 401, got %d", status)
+	}
+}
+
+func TestRecoverInterruptedDeletes(t *testing.T) {
+	marked, _ := seedObject(t, "TestRecoverInterruptedDeletes marked")
+	unlinked, _ := seedObject(t, "TestRecoverInterruptedDeletes unlinked")
+
+	// Crash after marking the objects, before and after unlinking content
+	for _, oid := range []string{marked, unlinked} {
+		if _, err := testMetaStore.MarkPendingDelete(oid); err != nil {
+			t.Fatalf("error marking object: %s", err)
+		}
+	}
+	if err := testContentStore.DeleteFile(unlinked); err != nil {
+		t.Fatalf("error deleting content: %s", err)
+	}
+
+	app := NewApp(testContentStore, testMetaStore)
+	deleted, err := app.recoverDeletes()
+	if err != nil {
+		t.Fatalf("expected recovery to succeed, got: %s", err)
+	}
+	if len(deleted) != 2 {
+		t.Errorf("expected 2 deletes to be finished, got %v", deleted)
+	}
+
+	for _, oid := range []string{marked, unlinked} {
+		if _, err := testMetaStore.UnsafeGet(&RequestVars{Oid: oid}); err != errObjectNotFound {
+			t.Errorf("expected metadata of %s to be removed, got %v", oid, err)
+		}
+		if testContentStore.Exists(&MetaObject{Oid: oid}) {
+			t.Errorf("expected content of %s to be removed", oid)
+		}
+	}
+
+	if _, err := testMetaStore.UnsafeGet(&RequestVars{Oid: contentOid}); err != nil {
+		t.Errorf("expected objects not pending delete to be kept, got %s", err)
+	}
+
+	if deleted, err := app.recoverDeletes(); err != nil || len(deleted) != 0 {
+		t.Errorf("expected nothing left to recover, got %v %v", deleted, err)
+	}
+}
+
+func TestPendingDeleteNotFound(t *testing.T) {
+	data := "TestPendingDeleteNotFound content"
+	oid, size := seedObject(t, data)
+	if _, err := testMetaStore.MarkPendingDelete(oid); err != nil {
+		t.Fatalf("error marking object: %s", err)
+	}
+
+	if _, err := testMetaStore.Get(&RequestVars{Oid: oid}); err != errObjectNotFound {
+		t.Errorf("expected an object pending delete to not be found, got %v", err)
+	}
+	res, err := api("GET", "/user/repo/objects/"+oid, contentMediaType, testUser, testPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 404 {
+		t.Errorf("expected a download of an object pending delete to get 404, got %d", res.StatusCode)
+	}
+
+	// Uploading the object again undoes the delete
+	body := bytes.NewBufferString(fmt.Sprintf(`{"operation":"upload","objects":[{"oid":"%s","size":%d}]}`, oid, size))
+	if res, err := api("POST", "/user/repo/objects/batch", metaMediaType, testUser, testPass, body); err != nil || res.StatusCode != 200 {
+		t.Fatalf("expected the batch to succeed, got %v %v", res, err)
+	}
+	meta, err := testMetaStore.Get(&RequestVars{Oid: oid})
+	if err != nil || meta.PendingDelete {
+		t.Fatalf("expected the object to be stored again without the pending delete, got %+v %v", meta, err)
+	}
+
+	app := NewApp(testContentStore, testMetaStore)
+	if deleted, err := app.recoverDeletes(); err != nil || len(deleted) != 0 {
+		t.Errorf("expected the object to no longer be deleted on recovery, got %v %v", deleted, err)
+	}
+}
+
+func TestMgmtDeletePinnedObject(t *testing.T) {
+	oid, _ := seedObject(t, "pinned content")
+
+	// Links followed by a browser or crawler must not change the object
+	if res, err := api("GET", "/mgmt/object/pin/"+oid, "", testAdminUser, testAdminPass, nil); err != nil || res.StatusCode == 200 {
+		t.Fatalf("expected pinning with GET to be refused, got %v %v", res, err)
+	}
+
+	res, err := api("POST", "/mgmt/object/pin/"+oid, "", testAdminUser, testAdminPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+
+	res, err = api("GET", "/mgmt/object/del/"+oid, "", testAdminUser, testAdminPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 409 {
+		t.Fatalf("expected status 409, got %d", res.StatusCode)
+	}
+	if !testContentStore.Exists(&MetaObject{Oid: oid}) {
+		t.Fatalf("expected pinned content to be preserved")
+	}
+
+	res, err = api("POST", "/mgmt/object/unpin/"+oid, "", testAdminUser, testAdminPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+
+	res, err = api("GET", "/mgmt/object/del/"+oid, "", testAdminUser, testAdminPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+	if testContentStore.Exists(&MetaObject{Oid: oid}) {
+		t.Fatalf("expected unpinned content to be removed")
+	}
+	if _, err := testMetaStore.Get(&RequestVars{Oid: oid}); err == nil {
+		t.Fatalf("expected unpinned meta to be removed")
+	}
+}
+
+func TestMgmtSeparateListener(t *testing.T) {
+	defer func(listen string) { Config.MgmtListen = listen }(Config.MgmtListen)
+	Config.MgmtListen = "tcp://127.0.0.1:0"
+
+	app := NewApp(testContentStore, testMetaStore)
+	apiServer := httptest.NewServer(app)
+	defer apiServer.Close()
+	mgmtServer := httptest.NewServer(app.MgmtHandler())
+	defer mgmtServer.Close()
+
+	for _, tc := range []struct {
+		server *httptest.Server
+		path   string
+		status int
+	}{
+		{mgmtServer, "/mgmt", 200},
+		{apiServer, "/mgmt", 404},
+		{mgmtServer, "/user/repo/objects/" + contentOid, 404},
+		{apiServer, "/user/repo/objects/" + contentOid, 200},
+	} {
+		req, err := http.NewRequest("GET", tc.server.URL+tc.path, nil)
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		req.Header.Set("Accept", contentMediaType)
+		if tc.server == mgmtServer {
+			req.SetBasicAuth(testAdminUser, testAdminPass)
+		} else {
+			req.SetBasicAuth(testUser, testPass)
+		}
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("response error: %s", err)
+		}
+		res.Body.Close()
+		if res.StatusCode != tc.status {
+			t.Errorf("expected status %d for %s on %s, got %d", tc.status, tc.path, tc.server.URL, res.StatusCode)
+		}
+	}
+}
+
+func TestMgmtAddDuplicateUser(t *testing.T) {
+	res, err := api("POST", "/mgmt/add?name=Merry&password=brandybuck", "", testAdminUser, testAdminPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+	defer testMetaStore.DeleteUser("merry")
+
+	res, err = api("POST", "/mgmt/add?name=MERRY&password=other", "", testAdminUser, testAdminPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 409 {
+		t.Fatalf("expected status 409, got %d", res.StatusCode)
+	}
+}
+
+func TestMgmtPutUser(t *testing.T) {
+	defer testMetaStore.DeleteUser("fatty")
+
+	for i, tc := range []struct {
+		pass   string
+		status int
+	}{{"bolger", 201}, {"bolger", 200}, {"other", 200}} {
+		res, err := api("PUT", "/mgmt/users/Fatty?password="+tc.pass, "", testAdminUser, testAdminPass, nil)
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		if res.StatusCode != tc.status {
+			t.Errorf("expected status %d for request %d, got %d", tc.status, i, res.StatusCode)
+		}
+	}
+	if _, ok := testMetaStore.Authenticate("fatty", "other"); !ok {
+		t.Errorf("expected the password to be updated")
+	}
+
+	res, err := api("POST", "/mgmt/add?name=fatty&password=again", "", testAdminUser, testAdminPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 409 {
+		t.Errorf("expected add to keep refusing existing users with 409, got %d", res.StatusCode)
+	}
+
+	res, err = api("PUT", "/mgmt/users/fatty", "", testAdminUser, testAdminPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 400 {
+		t.Errorf("expected status 400 without a password, got %d", res.StatusCode)
+	}
+}
+
+func TestMgmtUserImpact(t *testing.T) {
+	if err := testMetaStore.AddUser("lobelia", "spoons"); err != nil {
+		t.Fatalf("error adding user: %s", err)
+	}
+	defer testMetaStore.DeleteUser("lobelia")
+
+	for _, data := range []string{"TestMgmtUserImpact one", "TestMgmtUserImpact two"} {
+		oid := fmt.Sprintf("%x", sha256.Sum256([]byte(data)))
+		if _, err := testMetaStore.Put(&RequestVars{Oid: oid, Size: int64(len(data)), Uploader: "lobelia"}); err != nil {
+			t.Fatalf("error seeding meta store: %s", err)
+		}
+	}
+	if err := testMetaStore.AddLocks("impact-repo", NewTestLock("impact-1", "bag-end", "lobelia"), NewTestLock("impact-2", "spoons", "lobelia"), NewTestLock("impact-3", "garden", testUser)); err != nil {
+		t.Fatalf("error adding locks: %s", err)
+	}
+	defer testMetaStore.DeleteLock("impact-repo", "lobelia", "impact-1", true)
+	defer testMetaStore.DeleteLock("impact-repo", "lobelia", "impact-2", true)
+	defer testMetaStore.DeleteLock("impact-repo", testUser, "impact-3", true)
+
+	res, err := api("GET", "/mgmt/api/users/Lobelia/impact", "", testAdminUser, testAdminPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+	var impact UserImpact
+	if err := json.NewDecoder(res.Body).Decode(&impact); err != nil {
+		t.Fatalf("expected response body to be an impact, got: %s", err)
+	}
+	expected := UserImpact{User: "lobelia", Objects: 2, Size: 44, Locks: 2}
+	if impact != expected {
+		t.Errorf("expected impact %+v, got %+v", expected, impact)
+	}
+	if _, ok := testMetaStore.Authenticate("lobelia", "spoons"); !ok {
+		t.Errorf("expected the preview to not delete the user")
+	}
+
+	res, err = api("GET", "/mgmt/api/users/nobody/impact", "", testAdminUser, testAdminPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 404 {
+		t.Errorf("expected status 404 for a missing user, got %d", res.StatusCode)
+	}
+}
+
+func TestMgmtRenameUser(t *testing.T) {
+	if err := testMetaStore.AddUser("pippin", "took"); err != nil {
+		t.Fatalf("error adding user: %s", err)
+	}
+	defer testMetaStore.DeleteUser("pippin")
+	defer testMetaStore.DeleteUser("peregrin")
+
+	res, err := api("POST", "/mgmt/users/rename?name=pippin&new_name="+testUser, "", testAdminUser, testAdminPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 409 {
+		t.Fatalf("expected status 409 for a taken name, got %d", res.StatusCode)
+	}
+
+	res, err = api("POST", "/mgmt/users/rename?name=pippin&new_name=peregrin", "", testAdminUser, testAdminPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+	if _, ok := testMetaStore.Authenticate("peregrin", "took"); !ok {
+		t.Errorf("expected the user to be renamed")
+	}
+
+	res, err = api("POST", "/mgmt/users/rename?name=pippin&new_name=someone", "", testAdminUser, testAdminPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 404 {
+		t.Errorf("expected status 404 for a missing user, got %d", res.StatusCode)
+	}
+}
+
+func TestMgmtDeleteSharedObject(t *testing.T) {
+	oid, size := seedObject(t, "shared content")
+
+	for _, repo := range []string{"one", "two"} {
+		body := bytes.NewBufferString(fmt.Sprintf(`{"operation":"upload","objects":[{"oid":"%s","size":%d}]}`, oid, size))
+		res, err := api("POST", "/bilbo/"+repo+"/objects/batch", metaMediaType, testUser, testPass, body)
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		if res.StatusCode != 200 {
+			t.Fatalf("expected status 200, got %d", res.StatusCode)
+		}
+	}
+
+	for _, tc := range []struct {
+		query  string
+		status int
+		exists bool
+	}{
+		{"", 409, true},
+		{"?repo=bilbo/one", 200, true},
+		{"?repo=bilbo/three", 409, true},
+		{"?repo=bilbo/two", 200, false},
+	} {
+		res, err := api("GET", "/mgmt/object/del/"+oid+tc.query, "", testAdminUser, testAdminPass, nil)
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		if res.StatusCode != tc.status {
+			t.Fatalf("expected status %d deleting with %q, got %d", tc.status, tc.query, res.StatusCode)
+		}
+		if exists := testContentStore.Exists(&MetaObject{Oid: oid}); exists != tc.exists {
+			t.Errorf("expected content to exist: %t after deleting with %q", tc.exists, tc.query)
+		}
+	}
+
+	if _, err := testMetaStore.Get(&RequestVars{Oid: oid}); err == nil {
+		t.Errorf("expected meta to be removed with the last reference")
+	}
+}
+
+func TestDeleteObjectRefAddedMeanwhile(t *testing.T) {
+	oid, _ := seedObject(t, "TestDeleteObjectRefAddedMeanwhile content")
+
+	// The deletes work from meta read before the repositories added their refs
+	meta, err := testMetaStore.Get(&RequestVars{Oid: oid})
+	if err != nil {
+		t.Fatalf("error retrieving meta: %s", err)
+	}
+	for _, repo := range []string{"one", "two"} {
+		if _, err := testMetaStore.AddRef(&RequestVars{Oid: oid, User: "bilbo", Repo: repo}); err != nil {
+			t.Fatalf("error adding ref: %s", err)
+		}
+	}
+
+	app := NewApp(testContentStore, testMetaStore)
+	if err := app.deleteObject(meta, ""); err != errObjectShared {
+		t.Errorf("expected an object shared meanwhile to not be deleted, got %v", err)
+	}
+	if err := app.deleteObject(meta, "bilbo/one"); err != nil {
+		t.Fatalf("expected the ref to be released, got %s", err)
+	}
+	if !testContentStore.Exists(&MetaObject{Oid: oid}) {
+		t.Errorf("expected content still referenced to be kept")
+	}
+	meta, err = testMetaStore.Get(&RequestVars{Oid: oid})
+	if err != nil || meta.PendingDelete || meta.RefCount() != 1 || !meta.HasRef("bilbo/two") {
+		t.Fatalf("expected only bilbo/two to reference the object, got %+v %v", meta, err)
+	}
+
+	if err := app.deleteObject(meta, "bilbo/two"); err != nil {
+		t.Fatalf("expected the last reference to be deleted, got %s", err)
+	}
+	if _, err := testMetaStore.UnsafeGet(&RequestVars{Oid: oid}); err != errObjectNotFound {
+		t.Errorf("expected meta to be removed with the last reference, got %v", err)
+	}
+}
+
+func TestMgmtLabels(t *testing.T) {
+	labeled, _ := seedObject(t, "labeled content")
+	pinned, _ := seedObject(t, "labeled pinned content")
+	other, _ := seedObject(t, "unlabeled content")
+
+	for _, oid := range []string{labeled, pinned} {
+		res, err := api("POST", "/mgmt/object/label/"+oid+"?label=asset-pack", "", testAdminUser, testAdminPass, nil)
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		if res.StatusCode != 200 {
+			t.Fatalf("expected status 200, got %d", res.StatusCode)
+		}
+	}
+	if _, err := testMetaStore.SetPinned(pinned, true); err != nil {
+		t.Fatalf("error pinning object: %s", err)
+	}
+
+	objects, err := testMetaStore.Objects()
+	if err != nil {
+		t.Fatalf("error listing objects: %s", err)
+	}
+	filtered := filterObjectsByLabel(objects, "asset-pack")
+	if len(filtered) != 2 {
+		t.Fatalf("expected 2 labeled objects, got %d", len(filtered))
+	}
+
+	res, err := api("POST", "/mgmt/objects/del?label=asset-pack", "", testAdminUser, testAdminPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+
+	var result struct {
+		Deleted []string `json:"deleted"`
+		Skipped []string `json:"skipped"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		t.Fatalf("expected json response, got error: %s", err)
+	}
+	if len(result.Deleted) != 1 || result.Deleted[0] != labeled {
+		t.Errorf("expected only the unpinned labeled object to be deleted, got: %v", result.Deleted)
+	}
+	if len(result.Skipped) != 1 || result.Skipped[0] != pinned {
+		t.Errorf("expected the pinned object to be skipped, got: %v", result.Skipped)
+	}
+
+	if _, err := testMetaStore.Get(&RequestVars{Oid: labeled}); err == nil {
+		t.Errorf("expected labeled object to be deleted")
+	}
+	for _, oid := range []string{pinned, other} {
+		if !testContentStore.Exists(&MetaObject{Oid: oid}) {
+			t.Errorf("expected %s to be preserved", oid)
+		}
+	}
+
+	testMetaStore.SetPinned(pinned, false)
+}
+
+func TestMgmtPatchObject(t *testing.T) {
+	oid, size := seedObject(t, "TestMgmtPatchObject content")
+	defer testMetaStore.SetPinned(oid, false)
+
+	body := bytes.NewBufferString(`{"labels":["release","release","v2"],"pinned":true}`)
+	res, err := api("PATCH", "/mgmt/object/"+oid, "", testAdminUser, testAdminPass, body)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+
+	var meta MetaObject
+	if err := json.NewDecoder(res.Body).Decode(&meta); err != nil {
+		t.Fatalf("expected response body to be the object, got error: %s", err)
+	}
+	if !meta.Pinned {
+		t.Errorf("expected object to be pinned")
+	}
+	if len(meta.Labels) != 2 || meta.Labels[0] != "release" || meta.Labels[1] != "v2" {
+		t.Errorf("expected labels to be replaced, got %v", meta.Labels)
+	}
+
+	stored, err := testMetaStore.Get(&RequestVars{Oid: oid})
+	if err != nil {
+		t.Fatalf("error getting object: %s", err)
+	}
+	if !stored.Pinned || len(stored.Labels) != 2 {
+		t.Errorf("expected update to be stored, got %+v", stored)
+	}
+
+	body = bytes.NewBufferString(fmt.Sprintf(`{"size":%d}`, size+1))
+	res, err = api("PATCH", "/mgmt/object/"+oid, "", testAdminUser, testAdminPass, body)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 409 {
+		t.Errorf("expected status 409 for a size not matching the content, got %d", res.StatusCode)
+	}
+
+	stored, _ = testMetaStore.Get(&RequestVars{Oid: oid})
+	if stored.Size != size {
+		t.Errorf("expected size to be unchanged, got %d", stored.Size)
+	}
+
+	body = bytes.NewBufferString(fmt.Sprintf(`{"size":%d}`, size))
+	res, err = api("PATCH", "/mgmt/object/"+oid, "", testAdminUser, testAdminPass, body)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 200 {
+		t.Errorf("expected status 200 for a size matching the content, got %d", res.StatusCode)
+	}
+
+	body = bytes.NewBufferString(`{"pinned":true}`)
+	res, err = api("PATCH", "/mgmt/object/"+fmt.Sprintf("%x", sha256.Sum256([]byte("TestMgmtPatchObject missing"))), "", testAdminUser, testAdminPass, body)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 404 {
+		t.Errorf("expected status 404 for a missing object, got %d", res.StatusCode)
+	}
+}
+
+func TestMgmtRegister(t *testing.T) {
+	place := func(oid, data string) {
+		path := filepath.Join("lfs-content-test", transformKey(oid))
+		os.MkdirAll(filepath.Dir(path), 0750)
+		if err := ioutil.WriteFile(path, []byte(data), 0640); err != nil {
+			t.Fatalf("error placing content: %s", err)
+		}
+	}
+
+	correct := "TestMgmtRegister correct"
+	correctOid := fmt.Sprintf("%x", sha256.Sum256([]byte(correct)))
+	place(correctOid, correct)
+
+	wrongOid := fmt.Sprintf("%x", sha256.Sum256([]byte("TestMgmtRegister expected")))
+	place(wrongOid, "TestMgmtRegister tampered")
+
+	absentOid := fmt.Sprintf("%x", sha256.Sum256([]byte("TestMgmtRegister absent")))
+
+	body := bytes.NewBufferString(fmt.Sprintf(`{"objects":[{"oid":"%s","size":%d},{"oid":"%s","size":25},{"oid":"%s","size":23}]}`,
+		correctOid, len(correct), wrongOid, absentOid))
+	res, err := api("POST", "/mgmt/api/register", "", testAdminUser, testAdminPass, body)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+
+	var results []*RegisterResult
+	if err := json.NewDecoder(res.Body).Decode(&results); err != nil {
+		t.Fatalf("expected response body to be results, got error: %s", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+
+	expected := []struct {
+		oid    string
+		status string
+	}{
+		{correctOid, "registered"},
+		{wrongOid, "skipped"},
+		{absentOid, "skipped"},
+	}
+	for i, e := range expected {
+		if results[i].Oid != e.oid || results[i].Status != e.status {
+			t.Errorf("expected %s to be %s, got %+v", e.oid, e.status, results[i])
+		}
+	}
+
+	if _, err := testMetaStore.Get(&RequestVars{Oid: correctOid}); err != nil {
+		t.Errorf("expected correct content to be registered, got: %s", err)
+	}
+	for _, oid := range []string{wrongOid, absentOid} {
+		if _, err := testMetaStore.Get(&RequestVars{Oid: oid}); err == nil {
+			t.Errorf("expected %s to not be registered", oid)
+		}
+	}
+
+	// Scanning the store finds the placed content again
+	res, err = api("POST", "/mgmt/api/register", "", testAdminUser, testAdminPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	results = nil
+	if err := json.NewDecoder(res.Body).Decode(&results); err != nil {
+		t.Fatalf("expected response body to be results, got error: %s", err)
+	}
+	found := false
+	for _, r := range results {
+		if r.Oid == correctOid {
+			found = true
+			if r.Status != "exists" {
+				t.Errorf("expected registered content to exist, got %s", r.Status)
+			}
+		}
+		if r.Oid == wrongOid && r.Status != "skipped" {
+			t.Errorf("expected mismatched content to be skipped when scanning, got %s", r.Status)
+		}
+	}
+	if !found {
+		t.Errorf("expected scan to find the placed content")
+	}
+
+	os.Remove(filepath.Join("lfs-content-test", transformKey(wrongOid)))
+}
+
+func TestMgmtRegisterCopy(t *testing.T) {
+	content := "TestMgmtRegisterCopy content"
+	oid := fmt.Sprintf("%x", sha256.Sum256([]byte(content)))
+	src := filepath.Join("lfs-content-test", "rehome", "TestMgmtRegisterCopy")
+	os.MkdirAll(filepath.Dir(src), 0750)
+	if err := ioutil.WriteFile(src, []byte(content), 0640); err != nil {
+		t.Fatalf("error placing content: %s", err)
+	}
+	defer os.RemoveAll(filepath.Dir(src))
+
+	body := bytes.NewBufferString(fmt.Sprintf(`{"objects":[{"oid":"%s","size":%d,"source":"rehome/TestMgmtRegisterCopy"}]}`, oid, len(content)))
+	res, err := api("POST", "/mgmt/api/register", "", testAdminUser, testAdminPass, body)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+
+	var results []*RegisterResult
+	if err := json.NewDecoder(res.Body).Decode(&results); err != nil {
+		t.Fatalf("expected response body to be results, got error: %s", err)
+	}
+	if len(results) != 1 || results[0].Status != "registered" {
+		t.Fatalf("expected the object to be registered, got %+v", results)
+	}
+
+	srcInfo, _ := os.Stat(src)
+	dstInfo, err := os.Stat(filepath.Join("lfs-content-test", transformKey(oid)))
+	if err != nil || !os.SameFile(srcInfo, dstInfo) {
+		t.Errorf("expected the content to be linked from the source, got: %v", err)
+	}
+	if _, err := testMetaStore.UnsafeGet(&RequestVars{Oid: oid}); err != nil {
+		t.Errorf("expected metadata for the object, got: %s", err)
+	}
+}
+
+func TestMgmtRegisterSizeOnly(t *testing.T) {
+	defer func(v string) { Config.RegisterVerify = v }(Config.RegisterVerify)
+	Config.RegisterVerify = "size"
+
+	place := func(oid, data string) {
+		path := filepath.Join("lfs-content-test", transformKey(oid))
+		os.MkdirAll(filepath.Dir(path), 0750)
+		if err := ioutil.WriteFile(path, []byte(data), 0640); err != nil {
+			t.Fatalf("error placing content: %s", err)
+		}
+	}
+
+	// Content of the declared size is trusted without being hashed
+	trustedOid := fmt.Sprintf("%x", sha256.Sum256([]byte("TestMgmtRegisterSizeOnly expected")))
+	place(trustedOid, "TestMgmtRegisterSizeOnly tampered")
+
+	shortOid := fmt.Sprintf("%x", sha256.Sum256([]byte("TestMgmtRegisterSizeOnly short")))
+	place(shortOid, "TestMgmtRegisterSizeOnly short")
+
+	body := bytes.NewBufferString(fmt.Sprintf(`{"objects":[{"oid":"%s","size":33},{"oid":"%s","size":31}]}`, trustedOid, shortOid))
+	res, err := api("POST", "/mgmt/api/register", "", testAdminUser, testAdminPass, body)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+
+	var results []*RegisterResult
+	if err := json.NewDecoder(res.Body).Decode(&results); err != nil {
+		t.Fatalf("expected response body to be results, got error: %s", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if results[0].Status != "registered" {
+		t.Errorf("expected content of the declared size to be registered, got %+v", results[0])
+	}
+	if results[1].Status != "skipped" || results[1].Message != errSizeMismatch.Error() {
+		t.Errorf("expected a size mismatch to be skipped, got %+v", results[1])
+	}
+	if _, err := testMetaStore.UnsafeGet(&RequestVars{Oid: shortOid}); err == nil {
+		t.Errorf("expected a size mismatch to not be registered")
+	}
+
+	testMetaStore.Delete(&RequestVars{Oid: trustedOid})
+	for _, oid := range []string{trustedOid, shortOid} {
+		os.Remove(filepath.Join("lfs-content-test", transformKey(oid)))
+	}
+}
+
+func TestMgmtRawInline(t *testing.T) {
+	png := "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01"
+	text := "just some plain text"
+
+	cases := []struct {
+		data        string
+		contentType string
+	}{
+		{png, "image/png"},
+		{text, "text/plain; charset=utf-8"},
+	}
+
+	for _, c := range cases {
+		oid, _ := seedObject(t, c.data)
+
+		res, err := api("GET", "/mgmt/raw/"+oid+"?inline=1", "", testAdminUser, testAdminPass, nil)
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		if res.StatusCode != 200 {
+			t.Fatalf("expected status 200, got %d", res.StatusCode)
+		}
+		if ct := res.Header.Get("Content-Type"); ct != c.contentType {
+			t.Errorf("expected Content-Type %q, got %q", c.contentType, ct)
+		}
+		if cd := res.Header.Get("Content-Disposition"); !strings.HasPrefix(cd, "inline;") {
+			t.Errorf("expected inline Content-Disposition, got %q", cd)
+		}
+
+		by, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			t.Fatalf("expected response to contain content, got error: %s", err)
+		}
+		if string(by) != c.data {
+			t.Errorf("expected full content to be returned, got: %q", string(by))
+		}
+
+		res, err = api("GET", "/mgmt/raw/"+oid, "", testAdminUser, testAdminPass, nil)
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		if ct := res.Header.Get("Content-Type"); ct != "application/octet-stream" {
+			t.Errorf("expected default Content-Type to be octet-stream, got %q", ct)
+		}
+	}
+
+	// Content that could run script in the admin's browser is not shown inline
+	oid, _ := seedObject(t, "<html><script>alert(1)</script></html>")
+	res, err := api("GET", "/mgmt/raw/"+oid+"?inline=1", "", testAdminUser, testAdminPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if ct := res.Header.Get("Content-Type"); ct != "application/octet-stream" {
+		t.Errorf("expected HTML to be served as octet-stream, got %q", ct)
+	}
+	if cd := res.Header.Get("Content-Disposition"); !strings.HasPrefix(cd, "attachment;") {
+		t.Errorf("expected HTML to be served as an attachment, got %q", cd)
+	}
+	if res.Header.Get("X-Content-Type-Options") != "nosniff" || res.Header.Get("Content-Security-Policy") != "sandbox" {
+		t.Errorf("expected nosniff and a sandbox policy, got %v", res.Header)
+	}
+}
+
+func TestSizeHistogram(t *testing.T) {
+	mb := int64(1024 * 1024)
+	bounds := []int64{mb, 10 * mb, 100 * mb}
+
+	var objects []*MetaObject
+	for _, size := range []int64{0, mb - 1, mb, 10*mb - 1, 10 * mb, 100*mb - 1, 100 * mb, 500 * mb} {
+		objects = append(objects, &MetaObject{Size: size})
+	}
+
+	buckets := sizeHistogram(objects, bounds)
+
+	expected := []struct {
+		label string
+		count int
+	}{
+		{"<1MB", 2},
+		{"1MB-10MB", 2},
+		{"10MB-100MB", 2},
+		{">=100MB", 2},
+	}
+	if len(buckets) != len(expected) {
+		t.Fatalf("expected %d buckets, got %d", len(expected), len(buckets))
+	}
+	for i, e := range expected {
+		if buckets[i].Label != e.label || buckets[i].Count != e.count {
+			t.Errorf("expected bucket %d to be %s=%d, got %s=%d", i, e.label, e.count, buckets[i].Label, buckets[i].Count)
+		}
+	}
+}
+
+func TestMgmtHistogramAPI(t *testing.T) {
+	res, err := api("GET", "/mgmt/api/histogram", "", testAdminUser, testAdminPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+
+	var buckets []*sizeBucket
+	if err := json.NewDecoder(res.Body).Decode(&buckets); err != nil {
+		t.Fatalf("expected response body to be a histogram, got error: %s", err)
+	}
+	if len(buckets) != 4 {
+		t.Fatalf("expected 4 buckets, got %d", len(buckets))
+	}
+	if buckets[0].Count < 1 {
+		t.Errorf("expected seeded content to be counted in the first bucket, got %d", buckets[0].Count)
+	}
+}
+
+func TestMgmtUploads(t *testing.T) {
+	oid := fmt.Sprintf("%x", sha256.Sum256([]byte("unfinished upload")))
+	tmpPath := filepath.Join("lfs-content-test", transformKey(oid)) + ".tmp"
+	os.MkdirAll(filepath.Dir(tmpPath), 0750)
+	if err := ioutil.WriteFile(tmpPath, []byte("unfinished"), 0640); err != nil {
+		t.Fatalf("error writing temp file: %s", err)
+	}
+	defer os.Remove(tmpPath)
+
+	res, err := api("GET", "/mgmt/api/uploads", "", testAdminUser, testAdminPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+
+	var uploads []*Upload
+	if err := json.NewDecoder(res.Body).Decode(&uploads); err != nil {
+		t.Fatalf("expected response body to be uploads, got error: %s", err)
+	}
+	if len(uploads) != 1 || uploads[0].Oid != oid || uploads[0].Received != 10 {
+		t.Fatalf("expected the unfinished upload to be listed, got %v", uploads)
+	}
+
+	if res, err := api("GET", "/mgmt/upload/cancel/"+oid, "", testAdminUser, testAdminPass, nil); err != nil || res.StatusCode == 200 {
+		t.Fatalf("expected cancelling with GET to be refused, got %v %v", res, err)
+	}
+	if _, err := os.Stat(tmpPath); err != nil {
+		t.Fatalf("expected temp file to be kept, got %s", err)
+	}
+	res, err = api("POST", "/mgmt/upload/cancel/"+oid, "", testAdminUser, testAdminPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+	if _, err := os.Stat(tmpPath); err == nil {
+		t.Errorf("expected temp file to be removed")
+	}
+
+	res, err = api("POST", "/mgmt/upload/cancel/"+oid, "", testAdminUser, testAdminPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 404 {
+		t.Errorf("expected status 404 for a missing upload, got %d", res.StatusCode)
+	}
+}
+
+func TestGetContentMetaStoreDown(t *testing.T) {
+	defer func(public string) { Config.Public = public }(Config.Public)
+	Config.Public = "true"
+
+	metaStore, err := NewMetaStore("lfs-test-down.db")
+	if err != nil {
+		t.Fatalf("error creating meta store: %s", err)
+	}
+	defer os.Remove("lfs-test-down.db")
+	metaStore.db.Close()
+
+	server := httptest.NewServer(NewApp(testContentStore, metaStore))
+	defer server.Close()
+
+	get := func(oid string) *http.Response {
+		req, err := http.NewRequest("GET", server.URL+"/user/repo/objects/"+oid, nil)
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		req.Header.Set("Accept", contentMediaType)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		return res
+	}
+
+	res := get(contentOid)
+	if res.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	if string(body) != content {
+		t.Errorf("expected content to be served from the content store, got %q", body)
+	}
+
+	if res := get(nonExistingOid); res.StatusCode != 404 {
+		t.Errorf("expected status 404 for content missing from the store, got %d", res.StatusCode)
+	}
+
+	defer func(grace, age string) {
+		Config.GracePeriod, Config.WithholdAge = grace, age
+	}(Config.GracePeriod, Config.WithholdAge)
+	for _, c := range []struct{ grace, age string }{{"1h", "0"}, {"0", "720h"}} {
+		Config.GracePeriod, Config.WithholdAge = c.grace, c.age
+		if res := get(contentOid); res.StatusCode == 200 {
+			t.Errorf("expected content to not be served without meta with grace period %s and withhold age %s", c.grace, c.age)
+		}
+	}
+	Config.GracePeriod, Config.WithholdAge = "0", "0"
+
+	buf := bytes.NewBufferString(fmt.Sprintf(`{"operation":"download","objects":[{"oid":"%s","size":%d}]}`, contentOid, contentSize))
+	req, _ := http.NewRequest("POST", server.URL+"/user/repo/objects/batch", buf)
+	req.Header.Set("Accept", metaMediaType)
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	var batch BatchResponse
+	json.NewDecoder(res.Body).Decode(&batch)
+	if res.StatusCode == 200 && len(batch.Objects) == 1 && batch.Objects[0].Error == nil {
+		t.Errorf("expected batch to fail while the meta store is down")
+	}
+}
+
+func TestBasePath(t *testing.T) {
+	defer func(base string) { Config.BasePath = base }(Config.BasePath)
+	Config.BasePath = "/lfs/"
+
+	server := httptest.NewServer(NewApp(testContentStore, testMetaStore))
+	defer server.Close()
+
+	get := func(path, accept string) *http.Response {
+		req, err := http.NewRequest("GET", server.URL+path, nil)
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		req.SetBasicAuth(testAdminUser, testAdminPass)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		return res
+	}
+
+	res := get("/lfs/mgmt/users", "")
+	if res.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	for _, link := range []string{`href="/lfs/mgmt/objects"`, `action="/lfs/mgmt/add"`, `href="/lfs/mgmt/css/primer.css"`} {
+		if !strings.Contains(string(body), link) {
+			t.Errorf("expected page to link to %s", link)
+		}
+	}
+
+	if res := get("/lfs/mgmt/css/primer.css", ""); res.StatusCode != 200 {
+		t.Errorf("expected css under the base path, got status %d", res.StatusCode)
+	}
+	if res := get("/mgmt/users", ""); res.StatusCode != 404 {
+		t.Errorf("expected status 404 outside the base path, got %d", res.StatusCode)
+	}
+
+	req, _ := http.NewRequest("GET", server.URL+"/lfs/user/repo/objects/"+contentOid, nil)
+	req.Header.Set("Accept", metaMediaType)
+	req.SetBasicAuth(testUser, testPass)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+	var meta Representation
+	if err := json.NewDecoder(res.Body).Decode(&meta); err != nil {
+		t.Fatalf("expected response body to be json, got error: %s", err)
+	}
+	if href := meta.Actions["download"].Href; href != "http://localhost:8080/lfs/user/repo/objects/"+contentOid {
+		t.Errorf("expected links to include the base path, got %s", href)
+	}
+}
+
+func TestGetContentTracksAccess(t *testing.T) {
+	testMetaStore.EnableAccessTracking(time.Hour)
+	defer func() {
+		testMetaStore.access.close()
+		testMetaStore.access = nil
+	}()
+
+	oid, _ := seedObject(t, "TestGetContentTracksAccess content")
+
+	before := time.Now()
+	res, err := api("GET", "/user/repo/objects/"+oid, contentMediaType, testUser, testPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+	ioutil.ReadAll(res.Body)
+
+	if err := testMetaStore.access.flush(); err != nil {
+		t.Fatalf("expected flush to succeed, got: %s", err)
+	}
+
+	res, err = api("GET", "/mgmt/api/objects?sort=last_accessed", "", testAdminUser, testAdminPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	var objects []*MetaObject
+	if err := json.NewDecoder(res.Body).Decode(&objects); err != nil {
+		t.Fatalf("expected response body to be objects, got error: %s", err)
+	}
+	if len(objects) == 0 {
+		t.Fatalf("expected objects to be listed")
+	}
+
+	var accessed *MetaObject
+	for _, o := range objects {
+		if o.Oid == oid {
+			accessed = o
+		}
+	}
+	if accessed == nil || accessed.LastAccessedAt == nil || accessed.LastAccessedAt.Before(before) {
+		t.Fatalf("expected the download to update the last access time, got %+v", accessed)
+	}
+	if last := objects[len(objects)-1]; last.Oid != oid {
+		t.Errorf("expected the most recently accessed object to be listed last, got %s", last.Oid)
+	}
+}
+
+func TestMgmtObjectAPI(t *testing.T) {
+	testMetaStore.EnableAccessTracking(time.Hour)
+	defer func() {
+		testMetaStore.access.close()
+		testMetaStore.access = nil
+	}()
+
+	oid, size := seedObject(t, "TestMgmtObjectAPI content")
+	if _, err := testMetaStore.updateObject(oid, func(meta *MetaObject) {
+		meta.Uploader = testUser
+		meta.Labels = []string{"release"}
+		meta.Pinned = true
+	}); err != nil {
+		t.Fatalf("error updating object: %s", err)
+	}
+
+	res, err := api("GET", "/user/repo/objects/"+oid, contentMediaType, testUser, testPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	ioutil.ReadAll(res.Body)
+	if err := testMetaStore.access.flush(); err != nil {
+		t.Fatalf("expected flush to succeed, got: %s", err)
+	}
+
+	res, err = api("GET", "/mgmt/api/object/"+oid, "", testAdminUser, testAdminPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+
+	var meta MetaObject
+	if err := json.NewDecoder(res.Body).Decode(&meta); err != nil {
+		t.Fatalf("expected response body to be an object, got error: %s", err)
+	}
+	if meta.Oid != oid || meta.Size != size || meta.CreatedAt == nil {
+		t.Errorf("expected the object's oid, size and creation time, got %+v", meta)
+	}
+	if meta.Uploader != testUser || !meta.Pinned || len(meta.Labels) != 1 || meta.Labels[0] != "release" {
+		t.Errorf("expected the object's uploader, pin and labels, got %+v", meta)
+	}
+	if meta.Downloads != 1 || meta.LastAccessedAt == nil {
+		t.Errorf("expected the download to be counted, got %d at %v", meta.Downloads, meta.LastAccessedAt)
+	}
+
+	absent := fmt.Sprintf("%x", sha256.Sum256([]byte("TestMgmtObjectAPI absent")))
+	res, err = api("GET", "/mgmt/api/object/"+absent, "", testAdminUser, testAdminPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 404 {
+		t.Errorf("expected status 404 for a missing object, got %d", res.StatusCode)
+	}
+}
+
+func TestGetContentWithheld(t *testing.T) {
+	defer func(size string) { Config.WithholdSize = size }(Config.WithholdSize)
+	Config.WithholdSize = "20"
+
+	big, bigSize := seedObject(t, "TestGetContentWithheld over the limit")
+	exempt, _ := seedObject(t, "TestGetContentWithheld exempt over the limit")
+
+	if res, err := api("GET", "/mgmt/object/exempt/"+exempt, "", testAdminUser, testAdminPass, nil); err != nil || res.StatusCode == 200 {
+		t.Fatalf("expected exempting with GET to be refused, got %v %v", res, err)
+	}
+	res, err := api("POST", "/mgmt/object/exempt/"+exempt, "", testAdminUser, testAdminPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+
+	res, err = api("GET", "/user/repo/objects/"+big, contentMediaType, testUser, testPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 402 {
+		t.Fatalf("expected status 402, got %d", res.StatusCode)
+	}
+	var body struct {
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		t.Fatalf("expected a json error, got: %s", err)
+	}
+	if body.Message != errContentWithheld.Error() {
+		t.Errorf("expected withheld message, got %q", body.Message)
+	}
+
+	buf := bytes.NewBufferString(fmt.Sprintf(`{"operation":"download","objects":[{"oid":"%s","size":%d}]}`, big, bigSize))
+	res, err = api("POST", "/user/repo/objects/batch", metaMediaType, testUser, testPass, buf)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	var batch BatchResponse
+	if err := json.NewDecoder(res.Body).Decode(&batch); err != nil {
+		t.Fatalf("expected response body to be a batch response, got: %s", err)
+	}
+	if len(batch.Objects) != 1 || batch.Objects[0].Error == nil || batch.Objects[0].Error.Code != 402 {
+		t.Errorf("expected batch to report the object as withheld, got %+v", batch.Objects)
+	}
+
+	res, err = api("GET", "/user/repo/objects/"+exempt, contentMediaType, testUser, testPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 200 {
+		t.Errorf("expected exempt object to be served, got status %d", res.StatusCode)
+	}
+
+	if _, err := testMetaStore.Get(&RequestVars{Oid: big}); err != nil {
+		t.Errorf("expected withheld object to keep its metadata, got: %s", err)
+	}
+
+	Config.WithholdSize = "0"
+	defer func(age string) { Config.WithholdAge = age }(Config.WithholdAge)
+	Config.WithholdAge = "1ns"
+
+	res, err = api("GET", "/user/repo/objects/"+big, contentMediaType, testUser, testPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 402 {
+		t.Errorf("expected object over the age limit to be withheld, got status %d", res.StatusCode)
+	}
+}
+
+func TestMgmtObjectsStream(t *testing.T) {
+	seedObject(t, "TestMgmtObjectsStream content")
+
+	objects, err := testMetaStore.Objects()
+	if err != nil {
+		t.Fatalf("error listing objects: %s", err)
+	}
+
+	res, err := api("GET", "/mgmt/api/objects/stream", "", testAdminUser, testAdminPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+	if ct := res.Header.Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("expected ndjson content type, got %s", ct)
+	}
+
+	dec := json.NewDecoder(res.Body)
+	streamed := make(map[string]bool)
+	for dec.More() {
+		var meta MetaObject
+		if err := dec.Decode(&meta); err != nil {
+			t.Fatalf("expected each line to be an object, got error: %s", err)
+		}
+		streamed[meta.Oid] = true
+	}
+
+	if len(streamed) != len(objects) {
+		t.Errorf("expected %d objects, got %d", len(objects), len(streamed))
+	}
+	for _, o := range objects {
+		if !streamed[o.Oid] {
+			t.Errorf("expected %s to be streamed", o.Oid)
+		}
+	}
+}
+
+func TestBatchETag(t *testing.T) {
+	kept, keptSize := seedObject(t, "TestBatchETag kept")
+	deleted, deletedSize := seedObject(t, "TestBatchETag deleted")
+
+	batch := func(ifNoneMatch string) *http.Response {
+		buf := bytes.NewBufferString(fmt.Sprintf(`{"operation":"download","objects":[{"oid":"%s","size":%d},{"oid":"%s","size":%d}]}`,
+			kept, keptSize, deleted, deletedSize))
+		req, err := http.NewRequest("POST", lfsServer.URL+"/user/repo/objects/batch", buf)
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		req.SetBasicAuth(testUser, testPass)
+		req.Header.Set("Accept", metaMediaType)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("response error: %s", err)
+		}
+		return res
+	}
+
+	res := batch("")
+	if res.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+	etag := res.Header.Get("ETag")
+	if !strings.HasPrefix(etag, `W/"`) {
+		t.Fatalf("expected a weak ETag, got %q", etag)
+	}
+
+	res = batch(etag)
+	if res.StatusCode != 304 {
+		t.Fatalf("expected status 304 while the objects are unchanged, got %d", res.StatusCode)
+	}
+	if body, _ := ioutil.ReadAll(res.Body); len(body) != 0 {
+		t.Errorf("expected no body with a 304, got %s", body)
+	}
 
-func TestLocksVerifyUnAuthed(t *testing.T) {
-	buf := bytes.NewBufferString(fmt.Sprintf(`{"cursor": "", "limit": 0}`))
-	res, err := api("POST", "/user/repo/locks/verify", metaMediaType, "", "", buf)
-	if err != nil {
-		t.Fatalf("request error: %s", err)
+	if err := testMetaStore.Delete(&RequestVars{Oid: deleted}); err != nil {
+		t.Fatalf("error deleting object: %s", err)
 	}
 
-	if res.StatusCode != 401 {
-		t.Fatalf("expected status 401, got %d", res.StatusCode)
+	res = batch(etag)
+	if res.StatusCode != 200 {
+		t.Fatalf("expected status 200 after an object was deleted, got %d", res.StatusCode)
+	}
+	if res.Header.Get("ETag") == etag {
+		t.Errorf("expected the ETag to change after an object was deleted")
+	}
+	var response BatchResponse
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		t.Fatalf("expected response body to be a batch response, got: %s", err)
+	}
+	if len(response.Objects) != 2 || response.Objects[1].Error == nil || response.Objects[1].Error.Code != 404 {
+		t.Errorf("expected the deleted object to be reported missing, got %+v", response.Objects)
 	}
 }
 
-func TestLock(t *testing.T) {
-	path := "TestLock"
-	lock, err := createLock(testUser, testPass, path)
-	if err != nil {
-		t.Fatalf("create lock error: %s", err)
+func TestLogSampling(t *testing.T) {
+	defer func(rate string) { Config.LogSampleRate = rate }(Config.LogSampleRate)
+	defer func(l *KVLogger) { logger = l }(logger)
+
+	missing := fmt.Sprintf("%x", sha256.Sum256([]byte("TestLogSampling missing")))
+	for _, tc := range []struct {
+		rate      string
+		successes int
+	}{{"1.0", 5}, {"0.0", 0}, {"all", 5}} {
+		Config.LogSampleRate = tc.rate
+		var log bytes.Buffer
+		logger = NewKVLogger(&log)
+
+		for i := 0; i < 5; i++ {
+			for _, oid := range []string{contentOid, missing} {
+				res, err := api("GET", "/user/repo/objects/"+oid, contentMediaType, testUser, testPass, nil)
+				if err != nil {
+					t.Fatalf("request error: %s", err)
+				}
+				ioutil.ReadAll(res.Body)
+			}
+		}
+
+		if n := strings.Count(log.String(), "status=200"); n != tc.successes {
+			t.Errorf("expected %d successes logged at rate %s, got %d", tc.successes, tc.rate, n)
+		}
+		if n := strings.Count(log.String(), "status=404"); n != 5 {
+			t.Errorf("expected every error logged at rate %s, got %d", tc.rate, n)
+		}
 	}
-	if lock == nil {
-		t.Errorf("expected lock to be created, got: %s", lock)
+}
+
+func TestBatchProtectedRef(t *testing.T) {
+	defer func(refs, writers string) {
+		Config.ProtectedRefs = refs
+		Config.RefWriters = writers
+	}(Config.ProtectedRefs, Config.RefWriters)
+	Config.ProtectedRefs = "refs/heads/main,refs/heads/release/**"
+	Config.RefWriters = testUser
+
+	oid := fmt.Sprintf("%x", sha256.Sum256([]byte("TestBatchProtectedRef")))
+	upload := func(user, pass, ref string) *Representation {
+		body := fmt.Sprintf(`{"operation":"upload","ref":{"name":"%s"},"objects":[{"oid":"%s","size":21}]}`, ref, oid)
+		res, err := api("POST", "/user/repo/objects/batch", metaMediaType, user, pass, bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		var response BatchResponse
+		if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+			t.Fatalf("expected response body to be a batch response, got: %s", err)
+		}
+		if len(response.Objects) != 1 {
+			t.Fatalf("expected one object, got %d", len(response.Objects))
+		}
+		return response.Objects[0]
 	}
-	if lock.Owner.Name != testUser {
-		t.Errorf("expected lock owner to be match, got: %s", lock.Owner.Name)
+
+	if o := upload(testUser1, testPass1, "refs/heads/release/1.0"); o.Error == nil || o.Error.Code != 403 || o.Actions != nil {
+		t.Errorf("expected a user that is not a ref writer to get a 403, got %+v", o)
 	}
-	if lock.Path != path {
-		t.Errorf("expected lock path to be match, got: %s", lock.Path)
+	if o := upload(testUser, testPass, "refs/heads/main"); o.Error != nil || o.Actions["upload"] == nil {
+		t.Errorf("expected a ref writer to get an upload action, got %+v", o)
+	}
+	o := upload(testUser1, testPass1, "refs/heads/feature")
+	if o.Error != nil {
+		t.Errorf("expected other refs to be unprotected, got %+v", o)
+	}
+
+	// Uploads naming no ref are only allowed for ref writers, and upload
+	// links carry the batch's ref
+	if o := upload(testUser1, testPass1, ""); o.Error == nil || o.Error.Code != 403 {
+		t.Errorf("expected a batch without a ref to get a 403, got %+v", o)
+	}
+	href := o.Actions["upload"].Href
+	if !strings.HasSuffix(href, "?ref=refs%2Fheads%2Ffeature") {
+		t.Fatalf("expected the upload link to carry the ref, got %s", href)
+	}
+	put := func(path string) int {
+		req, _ := http.NewRequest("PUT", lfsServer.URL+path, bytes.NewBufferString("TestBatchProtectedRef"))
+		req.SetBasicAuth(testUser1, testPass1)
+		req.Header.Set("Accept", contentMediaType)
+		req.Header.Set("Content-Type", "application/octet-stream")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+	if status := put("/user/repo/objects/" + oid); status != 403 {
+		t.Errorf("expected a PUT without a ref to get a 403, got %d", status)
+	}
+	if status := put("/user/repo/objects/" + oid + "?ref=refs/heads/main"); status != 403 {
+		t.Errorf("expected a PUT to a protected ref to get a 403, got %d", status)
+	}
+	if status := put(href[strings.Index(href, "/user/"):]); status != 200 {
+		t.Errorf("expected a PUT to the upload link to succeed, got %d", status)
+	}
+
+	body := fmt.Sprintf(`{"oid":"%s","size":21}`, oid)
+	if res, err := api("POST", "/user/repo/objects", metaMediaType, testUser1, testPass1, bytes.NewBufferString(body)); err != nil || res.StatusCode != 403 {
+		t.Errorf("expected a POST without a ref to get a 403, got %v %v", res, err)
 	}
 }
 
-func TestLockExists(t *testing.T) {
-	l, err := createLock(testUser, testPass, "TestLockExists")
-	if err != nil {
-		t.Fatalf("create lock error: %s", err)
+func TestBatchExtensions(t *testing.T) {
+	data := "TestBatchExtensions"
+	oid := fmt.Sprintf("%x", sha256.Sum256([]byte(data)))
+
+	batch := func(operation, extensions string) *Representation {
+		body := fmt.Sprintf(`{"operation":"%s","objects":[{"oid":"%s","size":%d%s}]}`, operation, oid, len(data), extensions)
+		res, err := api("POST", "/user/repo/objects/batch", metaMediaType, testUser, testPass, bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		var response BatchResponse
+		if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+			t.Fatalf("expected response body to be a batch response, got: %s", err)
+		}
+		if len(response.Objects) != 1 {
+			t.Fatalf("expected one object, got %d", len(response.Objects))
+		}
+		return response.Objects[0]
 	}
 
-	buf := bytes.NewBufferString(fmt.Sprintf(`{"path":"%s"}`, l.Path))
-	res, err := api("POST", "/user/repo/locks", metaMediaType, testUser, testPass, buf)
-	if err != nil {
-		t.Fatalf("request error: %s", err)
+	o := batch("upload", `,"extensions":{"filter":{"name":"crypt","priority":0},"x-unknown":[1,2]}`)
+	if string(o.Extensions["filter"]) != `{"name":"crypt","priority":0}` || string(o.Extensions["x-unknown"]) != `[1,2]` {
+		t.Errorf("expected the extensions to be echoed on upload, got %v", o.Extensions)
+	}
+	if err := testContentStore.Put(&MetaObject{Oid: oid, Size: int64(len(data))}, bytes.NewBufferString(data)); err != nil {
+		t.Fatalf("error storing content: %s", err)
 	}
 
-	if res.StatusCode != 409 {
-		t.Fatalf("expected status 409, got %d", res.StatusCode)
+	o = batch("download", "")
+	if string(o.Extensions["filter"]) != `{"name":"crypt","priority":0}` || string(o.Extensions["x-unknown"]) != `[1,2]` {
+		t.Errorf("expected the stored extensions in a later batch, got %v", o.Extensions)
+	}
+
+	// Pushing the object again updates the extensions it is sent with
+	batch("upload", `,"extensions":{"x-unknown":"changed"}`)
+	o = batch("download", "")
+	if string(o.Extensions["filter"]) != `{"name":"crypt","priority":0}` || string(o.Extensions["x-unknown"]) != `"changed"` {
+		t.Errorf("expected the extensions to be merged, got %v", o.Extensions)
 	}
 }
 
-func TestLockUnAuthed(t *testing.T) {
-	buf := bytes.NewBufferString(fmt.Sprintf(`{"path":"%s"}`, "TestLockUnAuthed"))
-	res, err := api("POST", "/user/repo/locks", metaMediaType, "", "", buf)
-	if err != nil {
-		t.Fatalf("request error: %s", err)
+func TestBatchStream(t *testing.T) {
+	var objects []string
+	for i := 0; i < 200; i++ {
+		oid := fmt.Sprintf("%x", sha256.Sum256([]byte(fmt.Sprintf("TestBatchStream %d", i))))
+		objects = append(objects, fmt.Sprintf(`{"oid":"%s","size":%d}`, oid, i))
 	}
+	upload := `{"operation":"upload","transfers":["basic"],"objects":[` + strings.Join(objects, ",") + `]}`
+	download := `{"operation":"download","objects":[` + strings.Join(objects, ",") + `]}`
 
-	if res.StatusCode != 401 {
-		t.Fatalf("expected status 401, got %d", res.StatusCode)
+	batch := func(body string) (*http.Response, []byte) {
+		res, err := api("POST", "/user/repo/objects/batch", metaMediaType, testUser, testPass, bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		by, _ := ioutil.ReadAll(res.Body)
+		return res, by
+	}
+
+	defer func(size string) { Config.BatchStream = size }(Config.BatchStream)
+	Config.BatchStream = "100"
+
+	res, body := batch(upload)
+	if res.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+	var streamed BatchResponse
+	if err := json.Unmarshal(body, &streamed); err != nil {
+		t.Fatalf("expected the streamed response to be JSON, got: %s", err)
+	}
+	if streamed.Transfer != basicTransfer || len(streamed.Objects) != 200 {
+		t.Fatalf("expected 200 objects over basic, got %d over %q", len(streamed.Objects), streamed.Transfer)
+	}
+	for i, o := range streamed.Objects {
+		if o.Size != int64(i) || o.Actions["upload"] == nil {
+			t.Fatalf("expected object %d to be uploaded in order, got %+v", i, o)
+		}
+	}
+
+	res, body = batch(download)
+	if res.Header.Get("ETag") != "" {
+		t.Errorf("expected streamed responses to have no ETag")
+	}
+
+	// The same batch sent whole
+	Config.BatchStream = "0"
+	_, whole := batch(download)
+	if !bytes.Equal(body, whole) {
+		t.Errorf("expected the streamed response to match the whole response, got:\n%s\nwant:\n%s", body, whole)
 	}
 }
 
-func TestUnlock(t *testing.T) {
-	l, err := createLock(testUser, testPass, "TestUnlock")
+func TestBatchStreamError(t *testing.T) {
+	defer func(size string) { Config.BatchStream = size }(Config.BatchStream)
+	Config.BatchStream = "1"
+
+	var buf bytes.Buffer
+	defer func(l *KVLogger) { logger = l }(logger)
+	logger = NewKVLogger(&buf)
+
+	app := NewApp(testContentStore, testMetaStore)
+	app.authorizer = &mockAuthorizer{err: fmt.Errorf("unavailable")}
+	server := httptest.NewServer(app)
+	defer server.Close()
+
+	body := fmt.Sprintf(`{"operation":"download","objects":[{"oid":"%s","size":%d}]}`, contentOid, contentSize)
+	req, err := http.NewRequest("POST", server.URL+"/user/repo/objects/batch", bytes.NewBufferString(body))
 	if err != nil {
-		t.Fatalf("create lock error: %s", err)
+		t.Fatalf("request error: %s", err)
+	}
+	req.SetBasicAuth(testUser, testPass)
+	req.Header.Set("Accept", metaMediaType)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("response error: %s", err)
 	}
 
-	buf := bytes.NewBufferString(fmt.Sprintf(`{"force": %t}`, false))
-	res, err := api("POST", "/user/repo/locks/"+l.Id+"/unlock", metaMediaType, testUser, testPass, buf)
+	// The status was sent before the failure
+	if res.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+	var response BatchResponse
+	if err := json.NewDecoder(res.Body).Decode(&response); err == nil {
+		t.Errorf("expected the response to be cut short, got %+v", response)
+	}
+	if !strings.Contains(buf.String(), "fn=streamBatch") || !strings.Contains(buf.String(), "status=503") {
+		t.Errorf("expected the failure to be logged, got: %s", buf.String())
+	}
+}
+
+func TestMgmtObjectsByUploader(t *testing.T) {
+	data := "TestMgmtObjectsByUploader content"
+	oid := fmt.Sprintf("%x", sha256.Sum256([]byte(data)))
+	buf := bytes.NewBufferString(fmt.Sprintf(`{"operation":"upload","objects":[{"oid":"%s","size":%d,"uploader":"%s"}]}`, oid, len(data), testUser))
+	res, err := api("POST", "/user/repo/objects/batch", metaMediaType, testUser1, testPass1, buf)
 	if err != nil {
 		t.Fatalf("request error: %s", err)
 	}
@@ -406,68 +3511,350 @@ func TestUnlock(t *testing.T) {
 		t.Fatalf("expected status 200, got %d", res.StatusCode)
 	}
 
-	body, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		t.Fatalf("expected response to contain content, got error: %s", err)
+	list := func(uploader string) []*MetaObject {
+		res, err := api("GET", "/mgmt/api/objects?uploader="+uploader, "", testAdminUser, testAdminPass, nil)
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		var objects []*MetaObject
+		if err := json.NewDecoder(res.Body).Decode(&objects); err != nil {
+			t.Fatalf("expected response body to be objects, got error: %s", err)
+		}
+		return objects
 	}
 
-	var unlockResponse UnlockResponse
-	if err := json.Unmarshal(body, &unlockResponse); err != nil {
-		t.Fatalf("expected response body to be UnlockResponse, got error: %s", err)
+	if objects := list(testUser1); len(objects) != 1 || objects[0].Oid != oid || objects[0].Uploader != testUser1 {
+		t.Errorf("expected the object uploaded by the authenticated user, got %+v", objects)
 	}
-	lock := unlockResponse.Lock
-	if lock == nil || lock.Id != l.Id {
-		t.Errorf("expected deleted lock to be returned, got: %s", lock)
+	for _, meta := range list(testUser) {
+		if meta.Oid == oid {
+			t.Errorf("expected clients to not be able to set the uploader")
+		}
 	}
-}
 
-func TestUnLockUnAuthed(t *testing.T) {
-	l, err := createLock(testUser, testPass, "TestUnLockUnAuthed")
+	res, err = api("GET", "/mgmt/objects?uploader="+testUser1, "", testAdminUser, testAdminPass, nil)
 	if err != nil {
-		t.Fatalf("create lock error: %s", err)
+		t.Fatalf("request error: %s", err)
 	}
+	body, _ := ioutil.ReadAll(res.Body)
+	if !strings.Contains(string(body), `<option value="`+testUser1+`" selected>`) {
+		t.Errorf("expected the uploader to be selected in the filter")
+	}
+}
 
-	buf := bytes.NewBufferString(fmt.Sprintf(`{"force": %t}`, false))
-	res, err := api("POST", "/user/repo/locks/"+l.Id+"/unlock", metaMediaType, "", "", buf)
+func TestPutExpectContinue(t *testing.T) {
+	defer func(max string) { Config.MaxUpload = max }(Config.MaxUpload)
+	Config.MaxUpload = "1024"
+
+	large := fmt.Sprintf("%x", sha256.Sum256([]byte("TestPutExpectContinue large")))
+	if _, err := testMetaStore.Put(&RequestVars{Oid: large, Size: 1 << 30}); err != nil {
+		t.Fatalf("error seeding meta store: %s", err)
+	}
+	data := "TestPutExpectContinue content"
+	small := fmt.Sprintf("%x", sha256.Sum256([]byte(data)))
+	if _, err := testMetaStore.Put(&RequestVars{Oid: small, Size: int64(len(data))}); err != nil {
+		t.Fatalf("error seeding meta store: %s", err)
+	}
+
+	// put sends the headers of an upload waiting for 100 Continue, and the
+	// body only if the server asks for it.
+	put := func(oid string, length int64, body string) (continued bool, status int) {
+		conn, err := net.Dial("tcp", strings.TrimPrefix(lfsServer.URL, "http://"))
+		if err != nil {
+			t.Fatalf("dial error: %s", err)
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+		req, _ := http.NewRequest("PUT", lfsServer.URL+"/user/repo/objects/"+oid, nil)
+		req.SetBasicAuth(testUser, testPass)
+		fmt.Fprintf(conn, "PUT %s HTTP/1.1\r\nHost: %s\r\nAuthorization: %s\r\nAccept: %s\r\nContent-Length: %d\r\nExpect: 100-continue\r\n\r\n",
+			req.URL.Path, req.URL.Host, req.Header.Get("Authorization"), contentMediaType, length)
+
+		reader := bufio.NewReader(conn)
+		res, err := http.ReadResponse(reader, req)
+		if err != nil {
+			t.Fatalf("response error: %s", err)
+		}
+		if res.StatusCode != 100 {
+			return false, res.StatusCode
+		}
+
+		fmt.Fprint(conn, body)
+		if res, err = http.ReadResponse(reader, req); err != nil {
+			t.Fatalf("response error: %s", err)
+		}
+		return true, res.StatusCode
+	}
+
+	if continued, status := put(large, 1<<30, ""); continued || status != 413 {
+		t.Errorf("expected an upload over the limit to be refused with 413 before its body, got continued=%v status %d", continued, status)
+	}
+	if continued, status := put(small, 1<<20, ""); continued || status != 400 {
+		t.Errorf("expected a mismatched Content-Length to be refused with 400 before the body, got continued=%v status %d", continued, status)
+	}
+	if continued, status := put(small, int64(len(data)), data); !continued || status != 200 {
+		t.Errorf("expected an accepted upload to continue and succeed, got continued=%v status %d", continued, status)
+	}
+
+	batched := fmt.Sprintf("%x", sha256.Sum256([]byte("TestPutExpectContinue batch")))
+	buf := bytes.NewBufferString(fmt.Sprintf(`{"operation":"upload","objects":[{"oid":"%s","size":%d}]}`, batched, 1<<20))
+	res, err := api("POST", "/user/repo/objects/batch", metaMediaType, testUser, testPass, buf)
 	if err != nil {
 		t.Fatalf("request error: %s", err)
 	}
+	var response BatchResponse
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		t.Fatalf("expected response body to be a batch response, got: %s", err)
+	}
+	if len(response.Objects) != 1 || response.Objects[0].Error == nil || response.Objects[0].Error.Code != 413 {
+		t.Errorf("expected batch uploads over the limit to be refused, got %+v", response.Objects)
+	}
+}
 
-	if res.StatusCode != 401 {
-		t.Fatalf("expected status 401, got %d", res.StatusCode)
+func TestTLSConfig(t *testing.T) {
+	defer func(min, ciphers string) { Config.TLSMinVersion, Config.TLSCiphers = min, ciphers }(Config.TLSMinVersion, Config.TLSCiphers)
+
+	Config.TLSMinVersion, Config.TLSCiphers = "", ""
+	config := newTLSConfig()
+	if config.MinVersion != tls.VersionTLS12 {
+		t.Errorf("expected TLS 1.2 to be the default minimum, got %x", config.MinVersion)
+	}
+	if len(config.CipherSuites) != len(tlsCipherSuites) {
+		t.Errorf("expected the curated cipher suites by default, got %v", config.CipherSuites)
+	}
+
+	Config.TLSMinVersion, Config.TLSCiphers = "1.3", "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384, TLS_RSA_WITH_RC4_128_SHA"
+	config = newTLSConfig()
+	if config.MinVersion != tls.VersionTLS13 {
+		t.Errorf("expected the configured minimum version, got %x", config.MinVersion)
+	}
+	if len(config.CipherSuites) != 1 || config.CipherSuites[0] != tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384 {
+		t.Errorf("expected only the configured curated cipher suite, got %v", config.CipherSuites)
 	}
 }
 
-func TestUnlockNotOwner(t *testing.T) {
-	l, err := createLock(testUser, testPass, "TestUnlockNotOwner")
-	if err != nil {
-		t.Fatalf("create lock error: %s", err)
+func TestHSTS(t *testing.T) {
+	defer func(age string) { Config.HSTSMaxAge = age }(Config.HSTSMaxAge)
+
+	server := httptest.NewTLSServer(NewApp(testContentStore, testMetaStore))
+	defer server.Close()
+
+	get := func(client *http.Client, url string) string {
+		res, err := client.Get(url + "/api/whoami")
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		return res.Header.Get("Strict-Transport-Security")
 	}
 
-	buf := bytes.NewBufferString(fmt.Sprintf(`{"force": %t}`, false))
-	res, err := api("POST", "/user/repo/locks/"+l.Id+"/unlock", metaMediaType, testUser1, testPass1, buf)
-	if err != nil {
-		t.Fatalf("request error: %s", err)
+	Config.HSTSMaxAge = "600"
+	if hsts := get(server.Client(), server.URL); hsts != "max-age=600" {
+		t.Errorf("expected HSTS on TLS responses, got %q", hsts)
 	}
-	if res.StatusCode != 403 {
-		t.Fatalf("expected status 403, got %d", res.StatusCode)
+	if hsts := get(http.DefaultClient, lfsServer.URL); hsts != "" {
+		t.Errorf("expected no HSTS without TLS, got %q", hsts)
+	}
+
+	Config.HSTSMaxAge = "0"
+	if hsts := get(server.Client(), server.URL); hsts != "" {
+		t.Errorf("expected HSTS to be disabled, got %q", hsts)
 	}
 }
 
-func TestUnlockNotOwnerForce(t *testing.T) {
-	l, err := createLock(testUser, testPass, "TestUnlockNotOwnerForce")
+func TestMgmtDeleteRange(t *testing.T) {
+	day := time.Date(2001, 2, 3, 0, 0, 0, 0, time.UTC)
+	seed := func(data string, at time.Time) string {
+		oid, _ := seedObject(t, data)
+		if _, err := testMetaStore.updateObject(oid, func(meta *MetaObject) { meta.CreatedAt = &at }); err != nil {
+			t.Fatalf("error setting creation time: %s", err)
+		}
+		return oid
+	}
+
+	doomed := seed("TestMgmtDeleteRange doomed", day)
+	boundary := seed("TestMgmtDeleteRange boundary", day.Add(24*time.Hour))
+	pinned := seed("TestMgmtDeleteRange pinned", day.Add(time.Hour))
+	if _, err := testMetaStore.SetPinned(pinned, true); err != nil {
+		t.Fatalf("error pinning object: %s", err)
+	}
+	locked := seed("TestMgmtDeleteRange locked", day.Add(2*time.Hour))
+	if _, err := testMetaStore.AddRef(&RequestVars{Oid: locked, User: "user", Repo: testRepo}); err != nil {
+		t.Fatalf("error adding ref: %s", err)
+	}
+	if _, err := testMetaStore.SetName(locked, "locked.bin"); err != nil {
+		t.Fatalf("error naming object: %s", err)
+	}
+	lock, err := createLock(testUser, testPass, "assets/locked.bin")
 	if err != nil {
-		t.Fatalf("create lock error: %s", err)
+		t.Fatalf("error creating lock: %s", err)
 	}
+	defer testMetaStore.DeleteLock(testRepo, testUser, lock.Id, true)
 
-	buf := bytes.NewBufferString(fmt.Sprintf(`{"force": %t}`, true))
-	res, err := api("POST", "/user/repo/locks/"+l.Id+"/unlock", metaMediaType, testUser1, testPass1, buf)
+	deleteRange := func(query string) (*http.Response, RangeDeleteResult) {
+		res, err := api("POST", "/mgmt/objects/del/range?"+query, "", testAdminUser, testAdminPass, nil)
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		var result RangeDeleteResult
+		if res.StatusCode == 200 {
+			if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+				t.Fatalf("expected response body to be a result, got: %s", err)
+			}
+		}
+		return res, result
+	}
+
+	window := "start=2001-02-03T00:00:00Z&end=2001-02-04T00:00:00Z"
+	check := func(result RangeDeleteResult) {
+		if result.Matched != 3 {
+			t.Errorf("expected 3 objects in the window, got %d", result.Matched)
+		}
+		if len(result.Deleted) != 1 || result.Deleted[0] != doomed {
+			t.Errorf("expected only the unprotected object to be deleted, got %v", result.Deleted)
+		}
+		reasons := make(map[string]string)
+		for _, s := range result.Skipped {
+			reasons[s.Oid] = s.Reason
+		}
+		if reasons[pinned] != errObjectPinned.Error() || reasons[locked] != errObjectLocked.Error() || len(reasons) != 2 {
+			t.Errorf("expected the pinned and locked objects to be skipped, got %v", reasons)
+		}
+	}
+
+	_, result := deleteRange(window)
+	if !result.DryRun {
+		t.Errorf("expected a dry run without confirm")
+	}
+	check(result)
+	if _, err := testMetaStore.UnsafeGet(&RequestVars{Oid: doomed}); err != nil {
+		t.Fatalf("expected a dry run to keep objects, got %s", err)
+	}
+
+	_, result = deleteRange(window + "&confirm=true")
+	if result.DryRun {
+		t.Errorf("expected confirm to delete")
+	}
+	check(result)
+	if _, err := testMetaStore.UnsafeGet(&RequestVars{Oid: doomed}); err != errObjectNotFound {
+		t.Errorf("expected the object to be deleted, got %v", err)
+	}
+	for _, oid := range []string{boundary, pinned, locked} {
+		if _, err := testMetaStore.UnsafeGet(&RequestVars{Oid: oid}); err != nil {
+			t.Errorf("expected %s to be kept, got %s", oid, err)
+		}
+	}
+	testMetaStore.SetPinned(pinned, false)
+
+	for _, query := range []string{"start=yesterday&end=2001-02-04T00:00:00Z", "start=%22quoted%22&end=2001-02-04T00:00:00Z", "start=2001-02-04T00:00:00Z&end=2001-02-03T00:00:00Z", ""} {
+		res, _ := deleteRange(query)
+		if res.StatusCode != 400 {
+			t.Errorf("expected status 400 for %q, got %d", query, res.StatusCode)
+		}
+		var e struct{ Message string }
+		if err := json.NewDecoder(res.Body).Decode(&e); err != nil || e.Message == "" {
+			t.Errorf("expected a JSON error message for %q, got %v", query, err)
+		}
+	}
+}
+
+func TestLabelHeaders(t *testing.T) {
+	defer func(headers string) { Config.LabelHeaders = headers }(Config.LabelHeaders)
+	Config.LabelHeaders = "public:Cache-Control=public, max-age=86400; public:X-Cache-Tag=public;invalid"
+
+	public, _ := seedObject(t, "TestLabelHeaders public")
+	if _, err := testMetaStore.AddLabels(public, "public"); err != nil {
+		t.Fatalf("error labeling object: %s", err)
+	}
+	other, _ := seedObject(t, "TestLabelHeaders other")
+
+	get := func(oid string) http.Header {
+		res, err := api("GET", "/user/repo/objects/"+oid, contentMediaType, testUser, testPass, nil)
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		if res.StatusCode != 200 {
+			t.Fatalf("expected status 200, got %d", res.StatusCode)
+		}
+		return res.Header
+	}
+
+	h := get(public)
+	if cc := h.Get("Cache-Control"); cc != "public, max-age=86400" {
+		t.Errorf("expected the label's Cache-Control, got %q", cc)
+	}
+	if tag := h.Get("X-Cache-Tag"); tag != "public" {
+		t.Errorf("expected the label's cache tag, got %q", tag)
+	}
+
+	h = get(other)
+	if h.Get("Cache-Control") != "" || h.Get("X-Cache-Tag") != "" {
+		t.Errorf("expected no label headers on unlabeled objects, got %v", h)
+	}
+}
+
+func TestPutLogsDiagnostics(t *testing.T) {
+	defer func(diag string) { Config.UploadDiag = diag }(Config.UploadDiag)
+	Config.UploadDiag = "true"
+
+	var buf bytes.Buffer
+	defer func(l *KVLogger) { logger = l }(logger)
+	logger = NewKVLogger(&buf)
+
+	expected := "TestPutLogsDiagnostics content"
+	sent := "TestPutLogsDiagnostics CONTENT"
+	oid := fmt.Sprintf("%x", sha256.Sum256([]byte(expected)))
+	if _, err := testMetaStore.Put(&RequestVars{Oid: oid, Size: int64(len(expected))}); err != nil {
+		t.Fatalf("error seeding meta store: %s", err)
+	}
+
+	req, err := http.NewRequest("PUT", lfsServer.URL+"/user/repo/objects/"+oid, bytes.NewBufferString(sent))
 	if err != nil {
 		t.Fatalf("request error: %s", err)
 	}
-	if res.StatusCode != 200 {
-		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	req.SetBasicAuth(testUser, testPass)
+	req.Header.Set("Accept", contentMediaType)
+	req.Header.Set("User-Agent", "git-lfs/2.13.3")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("response error: %s", err)
+	}
+	if res.StatusCode != 500 {
+		t.Fatalf("expected the hash mismatch to fail the upload, got %d", res.StatusCode)
+	}
+
+	log := buf.String()
+	for _, field := range []string{
+		"level=error",
+		"oid=" + oid,
+		fmt.Sprintf("declared_size=%d", len(expected)),
+		fmt.Sprintf("received=%d", len(sent)),
+		fmt.Sprintf("computed_hash=%x", sha256.Sum256([]byte(sent))),
+		"user_agent=git-lfs/2.13.3",
+		"err=" + errHashMismatch.Error(),
+	} {
+		if !strings.Contains(log, field) {
+			t.Errorf("expected %s in the log, got: %s", field, log)
+		}
+	}
+	if strings.Contains(log, sent) {
+		t.Errorf("expected the body to not be logged")
+	}
+}
+
+// seedObject stores data in both the meta and content stores, returning its oid.
+func seedObject(t *testing.T, data string) (string, int64) {
+	oid := fmt.Sprintf("%x", sha256.Sum256([]byte(data)))
+	size := int64(len(data))
+
+	if _, err := testMetaStore.Put(&RequestVars{Oid: oid, Size: size}); err != nil {
+		t.Fatalf("error seeding meta store: %s", err)
+	}
+	if err := testContentStore.Put(&MetaObject{Oid: oid, Size: size}, bytes.NewBufferString(data)); err != nil {
+		t.Fatalf("error seeding content store: %s", err)
 	}
+
+	return oid, size
 }
 
 func createLock(username, password, path string) (*Lock, error) {
@@ -505,7 +3892,7 @@ func api(method, path, accept, username, password string, body *bytes.Buffer) (*
 	if username != "" || password != "" {
 		req.SetBasicAuth(username, password)
 	}
-	if (method == "POST" || method == "PUT") && body != nil {
+	if (method == "POST" || method == "PUT" || method == "PATCH") && body != nil {
 		req.Body = ioutil.NopCloser(body)
 	}
 
@@ -523,6 +3910,8 @@ const (
 	testPass          = "baggins"
 	testUser1         = "bilbo1"
 	testPass1         = "baggins1"
+	testAdminUser     = "gandalf"
+	testAdminPass     = "mithrandir"
 	testRepo          = "repo"
 	content           = "this is my content"
 	contentSize       = int64(len(content))
@@ -559,6 +3948,13 @@ func TestMain(m *testing.M) {
 		os.Exit(1)
 	}
 
+	Config.AdminUser = testAdminUser
+	Config.AdminPass = testAdminPass
+	if err := loadAdmin(testMetaStore); err != nil {
+		fmt.Printf("Error loading admin: %s", err)
+		os.Exit(1)
+	}
+
 	app := NewApp(testContentStore, testMetaStore)
 	lfsServer = httptest.NewServer(app)
 
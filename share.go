@@ -0,0 +1,183 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const defaultShareTTL = 24 * time.Hour
+
+// newShareKey returns the key share links are signed with. Without a
+// configured secret a random key is used, and links stop working when the
+// server restarts. The server does not start without a key, as links signed
+// with a predictable one could be forged.
+func newShareKey() []byte {
+	if Config.ShareSecret != "" {
+		return []byte(Config.ShareSecret)
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		logger.Fatal(kv{"fn": "newShareKey", "err": "Could not generate a share key: " + err.Error()})
+	}
+	return key
+}
+
+// shareSignature signs an oid and the unix time its link expires at.
+func shareSignature(key []byte, oid string, expires int64) string {
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "%s\n%d", oid, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// sizeSignature signs an oid and the size download links promise for it. It
+// is kept apart from share signatures, so one cannot be passed for the other.
+func sizeSignature(key []byte, oid string, size int64) string {
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "size\n%s\n%d", oid, size)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// signedDownloadLink adds the size of the object and its signature to a
+// download link.
+func (a *App) signedDownloadLink(href string, meta *MetaObject) string {
+	return href + fmt.Sprintf("?size=%d&sig=%s", meta.Size, sizeSignature(a.shareKey, meta.Oid, meta.Size))
+}
+
+// checkSignedSize answers the request itself and returns false if it was made
+// with a signed download link that does not match the stored object. The
+// size is checked first, so that a stale or altered size gets a 409 and an
+// altered signature a 403. Requests without a size are not checked.
+func (a *App) checkSignedSize(w http.ResponseWriter, r *http.Request, meta *MetaObject) bool {
+	v := r.URL.Query().Get("size")
+	if v == "" {
+		return true
+	}
+
+	size, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || size != meta.Size {
+		w.Header().Set("Content-Type", metaMediaType)
+		w.WriteHeader(409)
+		fmt.Fprint(w, `{"message":"Object size does not match the download link"}`)
+		logRequest(r, 409)
+		return false
+	}
+
+	sig := r.URL.Query().Get("sig")
+	if !hmac.Equal([]byte(sig), []byte(sizeSignature(a.shareKey, meta.Oid, size))) {
+		writeShareForbidden(w, r, "Invalid download link signature")
+		return false
+	}
+	return true
+}
+
+// ShareLink is a signed link to download an object without credentials.
+type ShareLink struct {
+	Href      string    `json:"href"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (a *App) shareLink(oid string, expiresAt time.Time) *ShareLink {
+	expires := expiresAt.Unix()
+	href := (&RequestVars{Oid: oid}).internalLink("share")
+	href += fmt.Sprintf("?expires=%d&sig=%s", expires, shareSignature(a.shareKey, oid, expires))
+	return &ShareLink{Href: href, ExpiresAt: time.Unix(expires, 0).UTC()}
+}
+
+// shareObjectHandler creates a share link for an object, valid for the ttl
+// duration in the form or a day by default.
+func (a *App) shareObjectHandler(w http.ResponseWriter, r *http.Request) {
+	oid := mux.Vars(r)["oid"]
+
+	ttl := defaultShareTTL
+	if v := r.FormValue("ttl"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(400)
+			fmt.Fprint(w, `{"message":"Invalid ttl, expected a positive duration such as 1h"}`)
+			return
+		}
+		ttl = d
+	}
+
+	if _, err := a.metaStore.UnsafeGet(&RequestVars{Oid: oid}); err != nil {
+		writeStatus(w, r, metaErrorStatus(err, 404), false)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(a.shareLink(oid, time.Now().Add(ttl)))
+}
+
+// ShareHandler serves the content of an object to anyone holding an unexpired
+// share link for it.
+func (a *App) ShareHandler(w http.ResponseWriter, r *http.Request) {
+	oid := mux.Vars(r)["oid"]
+
+	expires, err := strconv.ParseInt(r.FormValue("expires"), 10, 64)
+	sig := r.FormValue("sig")
+	if err != nil || !hmac.Equal([]byte(sig), []byte(shareSignature(a.shareKey, oid, expires))) {
+		writeShareForbidden(w, r, "Invalid share link")
+		return
+	}
+	if time.Now().Unix() >= expires {
+		writeShareForbidden(w, r, "Share link expired")
+		return
+	}
+
+	meta, err := a.metaStore.UnsafeGet(&RequestVars{Oid: oid})
+	if err != nil {
+		writeStatus(w, r, metaErrorStatus(err, 404), false)
+		return
+	}
+
+	if isPendingScan(meta, time.Now()) {
+		writePendingScan(w, r)
+		return
+	}
+
+	if isWithheld(meta, time.Now()) {
+		writeWithheld(w, r)
+		return
+	}
+
+	if !a.readLimit.Acquire() {
+		writeStatus(w, r, 503, false)
+		return
+	}
+	defer a.readLimit.Release()
+
+	content, err := a.contentStore.Get(meta, 0)
+	if err != nil {
+		writeStatus(w, r, 404, false)
+		return
+	}
+	defer content.Close()
+
+	a.metaStore.Touch(meta.Oid, time.Now())
+
+	setLabelHeaders(w, meta)
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", contentDisposition("attachment", meta))
+	w.WriteHeader(200)
+	io.Copy(w, content)
+	logRequest(r, 200)
+}
+
+func writeShareForbidden(w http.ResponseWriter, r *http.Request, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(403)
+	fmt.Fprintf(w, `{"message":"%s"}`, message)
+	logRequest(r, 403)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShareLink(t *testing.T) {
+	oid, _ := seedObject(t, "TestShareLink content")
+
+	res, err := api("POST", "/mgmt/object/share/"+oid+"?ttl=1h", "", testAdminUser, testAdminPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if res.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+	var link ShareLink
+	if err := json.NewDecoder(res.Body).Decode(&link); err != nil {
+		t.Fatalf("expected response body to be a share link, got: %s", err)
+	}
+	if d := time.Until(link.ExpiresAt); d <= 59*time.Minute || d > time.Hour {
+		t.Errorf("expected the link to expire in an hour, got %s", link.ExpiresAt)
+	}
+
+	href, err := url.Parse(link.Href)
+	if err != nil {
+		t.Fatalf("expected a valid link, got %q", link.Href)
+	}
+
+	// Links are fetched without credentials
+	get := func(path string, query url.Values) *http.Response {
+		res, err := http.Get(lfsServer.URL + path + "?" + query.Encode())
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		return res
+	}
+
+	res = get(href.Path, href.Query())
+	if res.StatusCode != 200 {
+		t.Fatalf("expected a valid link to download, got %d", res.StatusCode)
+	}
+	if body, _ := ioutil.ReadAll(res.Body); string(body) != "TestShareLink content" {
+		t.Errorf("expected the object's content, got %q", body)
+	}
+
+	tampered := href.Query()
+	sig := tampered.Get("sig")
+	tampered.Set("sig", strings.Repeat("0", len(sig)))
+	if res := get(href.Path, tampered); res.StatusCode != 403 {
+		t.Errorf("expected a tampered signature to get 403, got %d", res.StatusCode)
+	}
+
+	extended := href.Query()
+	extended.Set("expires", fmt.Sprint(time.Now().Add(48*time.Hour).Unix()))
+	if res := get(href.Path, extended); res.StatusCode != 403 {
+		t.Errorf("expected a tampered expiry to get 403, got %d", res.StatusCode)
+	}
+
+	if res := get(strings.Replace(href.Path, oid, contentOid, 1), href.Query()); res.StatusCode != 403 {
+		t.Errorf("expected the link to not work for other objects, got %d", res.StatusCode)
+	}
+
+	res, err = api("POST", "/mgmt/object/share/"+oid+"?ttl=%22soon%22", "", testAdminUser, testAdminPass, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	var invalid struct{ Message string }
+	if err := json.NewDecoder(res.Body).Decode(&invalid); res.StatusCode != 400 || err != nil {
+		t.Errorf("expected an invalid ttl to get 400 with a JSON message, got %d %v", res.StatusCode, err)
+	}
+
+	app := lfsServer.Config.Handler.(*App)
+	expired, _ := url.Parse(app.shareLink(oid, time.Now().Add(-time.Second)).Href)
+	res = get(expired.Path, expired.Query())
+	if res.StatusCode != 403 {
+		t.Errorf("expected an expired link to get 403, got %d", res.StatusCode)
+	}
+	var e struct{ Message string }
+	if json.NewDecoder(res.Body).Decode(&e); e.Message != "Share link expired" {
+		t.Errorf("expected the link to be reported expired, got %q", e.Message)
+	}
+}
+
+func TestSignedDownloadSize(t *testing.T) {
+	defer func(v string) { Config.SignDownloads = v }(Config.SignDownloads)
+	Config.SignDownloads = "true"
+
+	oid, size := seedObject(t, "TestSignedDownloadSize content")
+	body := fmt.Sprintf(`{"operation":"download","objects":[{"oid":"%s","size":%d}]}`, oid, size)
+	res, err := api("POST", "/user/repo/objects/batch", metaMediaType, testUser, testPass, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	var response BatchResponse
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil || len(response.Objects) != 1 {
+		t.Fatalf("expected a batch response with one object, got %v", err)
+	}
+	download := response.Objects[0].Actions["download"]
+	if download == nil {
+		t.Fatalf("expected a download action")
+	}
+	href, err := url.Parse(download.Href)
+	if err != nil || href.Query().Get("size") != fmt.Sprint(size) || href.Query().Get("sig") == "" {
+		t.Fatalf("expected the download link to carry the signed size, got %q", download.Href)
+	}
+
+	for _, accept := range []string{contentMediaType, "multipart/mixed"} {
+		get := func(query url.Values) int {
+			res, err := api("GET", href.Path+"?"+query.Encode(), accept, testUser, testPass, nil)
+			if err != nil {
+				t.Fatalf("request error: %s", err)
+			}
+			res.Body.Close()
+			return res.StatusCode
+		}
+
+		if status := get(href.Query()); status != 200 {
+			t.Errorf("%s: expected the signed link to download, got %d", accept, status)
+		}
+
+		tampered := href.Query()
+		tampered.Set("size", fmt.Sprint(size+1))
+		if status := get(tampered); status != 409 {
+			t.Errorf("%s: expected a tampered size to get 409, got %d", accept, status)
+		}
+
+		forged := href.Query()
+		forged.Set("sig", strings.Repeat("0", len(forged.Get("sig"))))
+		if status := get(forged); status != 403 {
+			t.Errorf("%s: expected a forged signature to get 403, got %d", accept, status)
+		}
+
+		if status := get(url.Values{}); status != 200 {
+			t.Errorf("%s: expected downloads without a signed size to be served, got %d", accept, status)
+		}
+	}
+}
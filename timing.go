@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/context"
+	"github.com/gorilla/mux"
+)
+
+// requestTiming collects how long the phases of a request take, so that
+// requests slower than the threshold can be logged with a breakdown. The
+// route and request id are kept on it, as the request context is cleared
+// once the router is done with the request.
+type requestTiming struct {
+	start     time.Time
+	route     string
+	oid       string
+	requestID interface{}
+
+	mu     sync.Mutex
+	phases map[string]time.Duration
+}
+
+// startTiming starts timing a request to be served by router.
+func startTiming(r *http.Request, router *mux.Router) *requestTiming {
+	t := &requestTiming{start: time.Now(), requestID: context.Get(r, "RequestID"), phases: make(map[string]time.Duration)}
+
+	var match mux.RouteMatch
+	if router.Match(r, &match) && match.Route != nil {
+		t.route = routeTemplate(match)
+		t.oid = match.Vars["oid"]
+	}
+
+	context.Set(r, "Timing", t)
+	return t
+}
+
+// routeTemplate returns the path template of the matched route, built from
+// the route with each variable standing for itself.
+func routeTemplate(match mux.RouteMatch) string {
+	pairs := make([]string, 0, 2*len(match.Vars))
+	for name := range match.Vars {
+		pairs = append(pairs, name, "{"+name+"}")
+	}
+	u, err := match.Route.URLPath(pairs...)
+	if err != nil {
+		return ""
+	}
+	return u.Path
+}
+
+// timePhase starts timing a phase of the request, and returns the function
+// that ends it. Phases timed more than once add up. It does nothing for
+// requests that are not timed.
+func timePhase(r *http.Request, phase string) func() {
+	t, ok := context.Get(r, "Timing").(*requestTiming)
+	if !ok {
+		return func() {}
+	}
+
+	start := time.Now()
+	return func() {
+		t.mu.Lock()
+		t.phases[phase] += time.Since(start)
+		t.mu.Unlock()
+	}
+}
+
+// logIfSlow logs the request with its phases if it took longer than
+// threshold.
+func (t *requestTiming) logIfSlow(r *http.Request, threshold time.Duration) {
+	total := time.Since(t.start)
+	if total <= threshold {
+		return
+	}
+
+	data := kv{"level": "warn", "fn": "slowRequest", "method": r.Method, "url": r.URL, "duration": total, "request_id": t.requestID}
+	if t.route != "" {
+		data["route"] = t.route
+	}
+	if t.oid != "" {
+		data["oid"] = t.oid
+	}
+
+	t.mu.Lock()
+	names := make([]string, 0, len(t.phases))
+	for name := range t.phases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		data["phase_"+name] = t.phases[name]
+	}
+	t.mu.Unlock()
+
+	logger.Log(data)
+}
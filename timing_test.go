@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestSlowRequestLog(t *testing.T) {
+	defer func(v string) { Config.SlowRequest = v }(Config.SlowRequest)
+	Config.SlowRequest = "20ms"
+
+	var buf bytes.Buffer
+	defer func(l *KVLogger) { logger = l }(logger)
+	logger = NewKVLogger(&buf)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/{user}/{repo}/objects/{oid}", func(w http.ResponseWriter, r *http.Request) {
+		done := timePhase(r, "content")
+		if r.FormValue("slow") != "" {
+			time.Sleep(30 * time.Millisecond)
+		}
+		done()
+	})
+	app := &App{}
+	serve := func(url string) {
+		app.serve(httptest.NewRecorder(), httptest.NewRequest("GET", url, nil), router)
+	}
+
+	serve("/user/repo/objects/" + contentOid)
+	if buf.Len() != 0 {
+		t.Fatalf("expected fast requests to not be logged, got %q", buf.String())
+	}
+
+	serve("/user/repo/objects/" + contentOid + "?slow=1")
+	line := buf.String()
+	for _, expected := range []string{"level=warn", "fn=slowRequest", "route=/{user}/{repo}/objects/{oid}", "oid=" + contentOid, "phase_content=3"} {
+		if !strings.Contains(line, expected) {
+			t.Errorf("expected the slow request to be logged with %s, got %q", expected, line)
+		}
+	}
+}
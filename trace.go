@@ -0,0 +1,93 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/context"
+)
+
+// traceContext identifies the span of a request within a distributed trace,
+// following the W3C Trace Context traceparent header.
+type traceContext struct {
+	TraceID string
+	SpanID  string
+	Flags   string
+}
+
+// String formats the trace context as a traceparent header value.
+func (t traceContext) String() string {
+	return fmt.Sprintf("00-%s-%s-%s", t.TraceID, t.SpanID, t.Flags)
+}
+
+// parseTraceParent parses a traceparent header value. Versions other than 00
+// are read by their first four fields, as the specification asks.
+func parseTraceParent(header string) (traceContext, bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 {
+		return traceContext{}, false
+	}
+
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+	if !isLowerHex(version, 2) || version == "ff" || (version == "00" && len(parts) != 4) {
+		return traceContext{}, false
+	}
+	if !isLowerHex(traceID, 32) || traceID == strings.Repeat("0", 32) {
+		return traceContext{}, false
+	}
+	if !isLowerHex(spanID, 16) || spanID == strings.Repeat("0", 16) {
+		return traceContext{}, false
+	}
+	if !isLowerHex(flags, 2) {
+		return traceContext{}, false
+	}
+
+	return traceContext{TraceID: traceID, SpanID: spanID, Flags: flags}, true
+}
+
+func isLowerHex(s string, length int) bool {
+	if len(s) != length {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// startSpan starts the span of a request, continuing the trace of the
+// incoming traceparent header if it is valid and starting a new one
+// otherwise.
+func startSpan(header string) traceContext {
+	span, ok := parseTraceParent(header)
+	if !ok {
+		span = traceContext{TraceID: randomHex(16), Flags: "01"}
+	}
+	span.SpanID = randomHex(8)
+	return span
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// traceOf returns the span of the request, if tracing is enabled.
+func traceOf(r *http.Request) (traceContext, bool) {
+	span, ok := context.Get(r, "Trace").(traceContext)
+	return span, ok
+}
+
+// propagateTrace sets the traceparent header of a downstream request made
+// while handling r, making it a child of r's span.
+func propagateTrace(req, r *http.Request) {
+	if span, ok := traceOf(r); ok {
+		req.Header.Set("traceparent", span.String())
+	}
+}
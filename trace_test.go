@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testTraceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestParseTraceParent(t *testing.T) {
+	cases := []struct {
+		header string
+		valid  bool
+	}{
+		{testTraceParent, true},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future", true},
+		{"", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01", false},
+	}
+
+	for _, c := range cases {
+		span, ok := parseTraceParent(c.header)
+		if ok != c.valid {
+			t.Errorf("expected %q to be valid: %v, got %v", c.header, c.valid, ok)
+		}
+		if ok && span.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("expected trace id of %q, got %s", c.header, span.TraceID)
+		}
+	}
+}
+
+func TestTraceContextPropagation(t *testing.T) {
+	var upstreamParent string
+	data := "TestTraceContextPropagation upstream content"
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamParent = r.Header.Get("traceparent")
+		fmt.Fprint(w, data)
+	}))
+	defer upstream.Close()
+
+	defer func(trace, url string) { Config.TraceContext, Config.Upstream = trace, url }(Config.TraceContext, Config.Upstream)
+	Config.TraceContext, Config.Upstream = "true", upstream.URL
+
+	var buf bytes.Buffer
+	defer func(l *KVLogger) { logger = l }(logger)
+	logger = NewKVLogger(&buf)
+
+	oid := fmt.Sprintf("%x", sha256.Sum256([]byte(data)))
+	if _, err := testMetaStore.Put(&RequestVars{Oid: oid, Size: int64(len(data))}); err != nil {
+		t.Fatalf("error seeding meta store: %s", err)
+	}
+
+	req, err := http.NewRequest("GET", lfsServer.URL+"/user/repo/objects/"+oid, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	req.SetBasicAuth(testUser, testPass)
+	req.Header.Set("Accept", contentMediaType)
+	req.Header.Set("traceparent", testTraceParent)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("response error: %s", err)
+	}
+	if res.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+
+	span, ok := parseTraceParent(res.Header.Get("traceparent"))
+	if !ok {
+		t.Fatalf("expected a traceparent response header, got %q", res.Header.Get("traceparent"))
+	}
+	if span.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || span.SpanID == "00f067aa0ba902b7" {
+		t.Errorf("expected a new span in the incoming trace, got %s", span)
+	}
+	if upstreamParent != span.String() {
+		t.Errorf("expected the upstream fetch to be a child of %s, got %q", span, upstreamParent)
+	}
+	if !strings.Contains(buf.String(), "trace_id=4bf92f3577b34da6a3ce929d0e0e4736") {
+		t.Errorf("expected the trace id in the log, got: %s", buf.String())
+	}
+
+	req.Header.Set("traceparent", "invalid")
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("response error: %s", err)
+	}
+	if span, ok := parseTraceParent(res.Header.Get("traceparent")); !ok || span.TraceID == "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("expected an invalid traceparent to start a new trace, got %q", res.Header.Get("traceparent"))
+	}
+
+	Config.TraceContext = "false"
+	req.Header.Set("traceparent", testTraceParent)
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("response error: %s", err)
+	}
+	if h := res.Header.Get("traceparent"); h != "" {
+		t.Errorf("expected no traceparent while tracing is disabled, got %q", h)
+	}
+}
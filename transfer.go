@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net"
+	"net/http"
+)
+
+const (
+	basicTransfer      = "basic"
+	tusTransfer        = "tus"
+	standaloneTransfer = "lfs-standalone-file"
+)
+
+// negotiateTransfer picks the transfer adapter for a batch request. The
+// client's transfers are tried in its order of preference, falling back to
+// basic when none of them are supported and enabled.
+func negotiateTransfer(bv *BatchVars, r *http.Request) string {
+	for _, t := range bv.Transfers {
+		switch t {
+		case tusTransfer:
+			if bv.Operation == "upload" && Config.IsUsingTus() {
+				return tusTransfer
+			}
+		case standaloneTransfer:
+			// Uploads always go over HTTP so their content is verified
+			if bv.Operation == "download" && Config.IsStandaloneTransfer() && isTrustedClient(r) {
+				return standaloneTransfer
+			}
+		case basicTransfer:
+			return basicTransfer
+		}
+	}
+	return basicTransfer
+}
+
+// isTrustedClient returns true if the request comes from one of the
+// configured trusted networks.
+func isTrustedClient(r *http.Request) bool {
+	return isFromNets(r, Config.TrustedNets())
+}
+
+// isFromNets returns true if the request was made from an address in one of
+// the networks.
+func isFromNets(r *http.Request, nets []*net.IPNet) bool {
+	ip := clientIP(r)
+	if ip == nil {
+		return false
+	}
+
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the IP address the request was made from.
+func clientIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/hex"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gorilla/context"
+)
+
+const tmpSuffix = ".tmp"
+
+// Upload describes content that is still being written to a temp file in the
+// content store, either in progress or abandoned.
+type Upload struct {
+	Oid          string    `json:"oid"`
+	Received     int64     `json:"received"`
+	LastActivity time.Time `json:"last_activity"`
+}
+
+// uploadDiagnostics records what an upload actually sent, to be logged if it
+// fails. It hashes the body with the algorithm of the oid as it is read.
+type uploadDiagnostics struct {
+	r        io.Reader
+	received int64
+	hash     hash.Hash
+}
+
+func newUploadDiagnostics(r io.Reader, oid string) *uploadDiagnostics {
+	h, _ := newOidHash(oid)
+	return &uploadDiagnostics{r: r, hash: h}
+}
+
+func (d *uploadDiagnostics) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	d.received += int64(n)
+	if d.hash != nil {
+		d.hash.Write(p[:n])
+	}
+	return n, err
+}
+
+// log logs the failure of an upload of meta with err, along with the size
+// and hash of what was received.
+func (d *uploadDiagnostics) log(r *http.Request, meta *MetaObject, err error) {
+	computed := ""
+	if d.hash != nil {
+		computed = hex.EncodeToString(d.hash.Sum(nil))
+	}
+
+	logger.Log(kv{
+		"fn":            "PutHandler",
+		"level":         "error",
+		"oid":           meta.Oid,
+		"declared_size": meta.Size,
+		"received":      d.received,
+		"computed_hash": computed,
+		"user_agent":    r.UserAgent(),
+		"request_id":    context.Get(r, "RequestID"),
+		"err":           err.Error(),
+	})
+}
+
+// Uploads lists the uploads whose temp files are in the store.
+func (s *ContentStore) Uploads() ([]*Upload, error) {
+	var uploads []*Upload
+
+	err := filepath.Walk(s.basePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// Temp files may disappear while walking
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(path, tmpSuffix) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.basePath, strings.TrimSuffix(path, tmpSuffix))
+		if err != nil {
+			return err
+		}
+		uploads = append(uploads, &Upload{
+			Oid:          strings.Replace(filepath.ToSlash(rel), "/", "", -1),
+			Received:     info.Size(),
+			LastActivity: info.ModTime(),
+		})
+		return nil
+	})
+
+	return uploads, err
+}
+
+// CancelUpload removes the temp file of an upload. An upload still in
+// progress then fails instead of being promoted.
+func (s *ContentStore) CancelUpload(oid string) error {
+	path := filepath.Join(s.basePath, transformKey(oid)) + tmpSuffix
+	if err := os.Remove(path); err != nil {
+		if os.IsNotExist(err) {
+			return errFileNotExist
+		}
+		return err
+	}
+	return nil
+}
+
+// PurgeUploads cancels the uploads that have not been written to since
+// before, returning their oids.
+func (s *ContentStore) PurgeUploads(before time.Time) ([]string, error) {
+	uploads, err := s.Uploads()
+	if err != nil {
+		return nil, err
+	}
+
+	var purged []string
+	for _, u := range uploads {
+		if !u.LastActivity.Before(before) {
+			continue
+		}
+		if err := s.CancelUpload(u.Oid); err != nil && err != errFileNotExist {
+			return purged, err
+		}
+		purged = append(purged, u.Oid)
+	}
+	return purged, nil
+}
+
+// purgeIdleUploads purges uploads idle for longer than maxIdle every maxIdle
+// until stop is closed.
+func purgeIdleUploads(s *ContentStore, maxIdle time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(maxIdle)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+
+		purged, err := s.PurgeUploads(time.Now().Add(-maxIdle))
+		if err != nil {
+			logger.Log(kv{"fn": "purgeIdleUploads", "err": "Could not purge idle uploads: " + err.Error()})
+			continue
+		}
+		for _, oid := range purged {
+			logger.Log(kv{"fn": "purgeIdleUploads", "msg": "purged idle upload", "oid": oid})
+		}
+	}
+}
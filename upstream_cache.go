@@ -0,0 +1,181 @@
+package main
+
+import (
+	"container/list"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// upstreamCache keeps content fetched from the upstream server in a content
+// store of its own, apart from the objects uploaded to this server. When it
+// is bounded, the least recently used objects are evicted to keep it under
+// its size limit. Evicted objects are fetched from upstream again when they
+// are next requested.
+type upstreamCache struct {
+	store *ContentStore
+	limit int64
+
+	mu      sync.Mutex
+	size    int64
+	lru     *list.List // of *cacheEntry, most recently used first
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	oid  string
+	size int64
+}
+
+// newUpstreamCache returns the configured cache of upstream content, or nil
+// if upstream content is cached in the content store.
+func newUpstreamCache() *upstreamCache {
+	if Config.Upstream == "" || Config.CachePath == "" {
+		return nil
+	}
+
+	c, err := openUpstreamCache(Config.CachePath, Config.CacheSizeLimit())
+	if err != nil {
+		logger.Log(kv{"fn": "newUpstreamCache", "err": "Could not open the upstream cache: " + err.Error()})
+		return nil
+	}
+	return c
+}
+
+// openUpstreamCache opens a cache in the directory at path, holding up to
+// limit bytes, or any amount if limit is zero. Objects already cached are
+// kept, the most recently fetched counting as the most recently used.
+func openUpstreamCache(path string, limit int64) (*upstreamCache, error) {
+	store, err := NewContentStore(path)
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err := store.Objects()
+	if err != nil {
+		return nil, err
+	}
+	modTimes := make(map[string]time.Time, len(objects))
+	for _, o := range objects {
+		if info, err := os.Stat(filepath.Join(path, transformKey(o.Oid))); err == nil {
+			modTimes[o.Oid] = info.ModTime()
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool {
+		return modTimes[objects[i].Oid].After(modTimes[objects[j].Oid])
+	})
+
+	c := &upstreamCache{store: store, limit: limit, lru: list.New(), entries: make(map[string]*list.Element)}
+	for _, o := range objects {
+		c.entries[o.Oid] = c.lru.PushBack(&cacheEntry{o.Oid, o.Size})
+		c.size += o.Size
+	}
+	c.mu.Lock()
+	c.evict()
+	c.mu.Unlock()
+	return c, nil
+}
+
+// Get returns the cached content of an object, counting it as used.
+func (c *upstreamCache) Get(meta *MetaObject, fromByte int64) (io.ReadCloser, error) {
+	content, err := c.store.Get(meta, fromByte)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if e, ok := c.entries[meta.Oid]; ok {
+		c.lru.MoveToFront(e)
+	}
+	c.mu.Unlock()
+	return content, nil
+}
+
+// Exists returns true if the object is cached.
+func (c *upstreamCache) Exists(meta *MetaObject) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.entries[meta.Oid]
+	return ok
+}
+
+// Put caches the content of an object, evicting others if the cache grows
+// over its limit. The object itself is kept even if it is over the limit
+// alone, so that the download it was fetched for can be served.
+func (c *upstreamCache) Put(meta *MetaObject, r io.Reader) error {
+	if err := c.store.Put(meta, r); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[meta.Oid]; ok {
+		c.lru.MoveToFront(e)
+		return nil
+	}
+	c.entries[meta.Oid] = c.lru.PushFront(&cacheEntry{meta.Oid, meta.Size})
+	c.size += meta.Size
+	c.evict()
+	return nil
+}
+
+// evict removes the least recently used objects until the cache is under its
+// limit, always keeping the most recently used one. c.mu must be held.
+func (c *upstreamCache) evict() {
+	if c.limit <= 0 {
+		return
+	}
+
+	for c.size > c.limit && c.lru.Len() > 1 {
+		e := c.lru.Back()
+		entry := e.Value.(*cacheEntry)
+		if err := c.store.DeleteFile(entry.oid); err != nil && err != errFileNotExist {
+			logger.Log(kv{"fn": "evict", "oid": entry.oid, "err": "Could not evict cached content: " + err.Error()})
+			return
+		}
+		c.lru.Remove(e)
+		delete(c.entries, entry.oid)
+		c.size -= entry.size
+	}
+}
+
+// fillGroup runs one fill of the store at a time for each oid, letting
+// concurrent downloads of an object wait for the fill already running instead
+// of starting their own. The zero value is ready to use.
+type fillGroup struct {
+	mu    sync.Mutex
+	fills map[string]*fill
+}
+
+type fill struct {
+	done chan struct{}
+	err  error
+}
+
+// do runs fn for oid, or waits for the fn already running for it, and
+// returns its error.
+func (g *fillGroup) do(oid string, fn func() error) error {
+	g.mu.Lock()
+	if f, ok := g.fills[oid]; ok {
+		g.mu.Unlock()
+		<-f.done
+		return f.err
+	}
+	if g.fills == nil {
+		g.fills = make(map[string]*fill)
+	}
+	f := &fill{done: make(chan struct{})}
+	g.fills[oid] = f
+	g.mu.Unlock()
+
+	f.err = fn()
+
+	g.mu.Lock()
+	delete(g.fills, oid)
+	g.mu.Unlock()
+	close(f.done)
+	return f.err
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestUpstreamCacheEviction(t *testing.T) {
+	defer os.RemoveAll("upstream-cache-test")
+	cache, err := openUpstreamCache("upstream-cache-test", 25)
+	if err != nil {
+		t.Fatalf("error opening cache: %s", err)
+	}
+
+	put := func(data string) *MetaObject {
+		meta := &MetaObject{Oid: fmt.Sprintf("%x", sha256.Sum256([]byte(data))), Size: int64(len(data))}
+		if err := cache.Put(meta, bytes.NewBufferString(data)); err != nil {
+			t.Fatalf("error caching content: %s", err)
+		}
+		return meta
+	}
+	get := func(meta *MetaObject) bool {
+		content, err := cache.Get(meta, 0)
+		if err != nil {
+			return false
+		}
+		content.Close()
+		return true
+	}
+
+	a, b := put("0123456789"), put("abcdefghij")
+	if !get(a) || !get(b) {
+		t.Fatalf("expected cached content to be served")
+	}
+	get(a)
+
+	// c pushes the cache over its limit, evicting b as the least recently used
+	c := put("ABCDEFGHIJ")
+	if get(b) || cache.Exists(b) {
+		t.Errorf("expected the least recently used object to be evicted")
+	}
+	if !get(a) || !get(c) {
+		t.Errorf("expected the recently used objects to be kept")
+	}
+	if cache.size != 20 {
+		t.Errorf("expected 20 bytes to be cached, got %d", cache.size)
+	}
+
+	// An object over the limit alone is still kept, evicting everything else
+	big := put(strings.Repeat("x", 30))
+	if !get(big) || cache.Exists(a) || cache.Exists(c) {
+		t.Errorf("expected only the object over the limit to be kept")
+	}
+
+	reopened, err := openUpstreamCache("upstream-cache-test", 25)
+	if err != nil {
+		t.Fatalf("error reopening cache: %s", err)
+	}
+	if !reopened.Exists(big) || reopened.size != 30 {
+		t.Errorf("expected cached content to be kept when reopened, got %d bytes", reopened.size)
+	}
+}
+
+func TestGetFromUpstreamCache(t *testing.T) {
+	hits := 0
+	data := "TestGetFromUpstreamCache content"
+	oid := fmt.Sprintf("%x", sha256.Sum256([]byte(data)))
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if strings.TrimPrefix(r.URL.Path, "/objects/") != oid {
+			w.WriteHeader(404)
+			return
+		}
+		fmt.Fprint(w, data)
+	}))
+	defer upstream.Close()
+
+	defer func(url string) { Config.Upstream = url }(Config.Upstream)
+	Config.Upstream = upstream.URL
+
+	defer os.RemoveAll("upstream-cache-test")
+	cache, err := openUpstreamCache("upstream-cache-test", 0)
+	if err != nil {
+		t.Fatalf("error opening cache: %s", err)
+	}
+	app := lfsServer.Config.Handler.(*App)
+	app.upstream = cache
+	defer func() { app.upstream = nil }()
+
+	if _, err := testMetaStore.Put(&RequestVars{Oid: oid, Size: int64(len(data))}); err != nil {
+		t.Fatalf("error seeding meta store: %s", err)
+	}
+
+	// The first download misses and fills the cache, the second hits it
+	for i := 0; i < 2; i++ {
+		res, err := api("GET", "/user/repo/objects/"+oid, contentMediaType, testUser, testPass, nil)
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		if by, _ := ioutil.ReadAll(res.Body); res.StatusCode != 200 || string(by) != data {
+			t.Fatalf("expected the upstream content, got %d %q", res.StatusCode, by)
+		}
+	}
+	if hits != 1 {
+		t.Errorf("expected upstream to be fetched once and then served from the cache, got %d fetches", hits)
+	}
+	if !cache.Exists(&MetaObject{Oid: oid}) || testContentStore.Exists(&MetaObject{Oid: oid}) {
+		t.Errorf("expected the content to be cached apart from the content store")
+	}
+
+	body := fmt.Sprintf(`{"operation":"download","objects":[{"oid":"%s","size":%d}]}`, oid, len(data))
+	res, err := api("POST", "/user/repo/objects/batch", metaMediaType, testUser, testPass, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	var response BatchResponse
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil || len(response.Objects) != 1 {
+		t.Fatalf("expected a batch response with one object, got %v", err)
+	}
+	if o := response.Objects[0]; o.Error != nil || o.Actions["download"] == nil {
+		t.Errorf("expected cached content to be downloadable through batches, got %+v", o)
+	}
+}
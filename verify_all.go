@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const defaultVerifyReport = "verify-report.jsonl"
+
+// VerifyResult is a line of the verify-all report.
+type VerifyResult struct {
+	Oid        string `json:"oid"`
+	Expected   string `json:"expected"`
+	Actual     string `json:"actual,omitempty"`
+	Size       int64  `json:"size"`
+	ActualSize int64  `json:"actual_size"`
+	SizeMatch  bool   `json:"size_match"`
+	Pass       bool   `json:"pass"`
+	Error      string `json:"error,omitempty"`
+}
+
+// runVerifyAll opens the configured stores and verifies every object with
+// VerifyAll, appending to the report file. Objects already in the report are
+// skipped, so an interrupted run can be resumed by running it again. It
+// returns the process exit code.
+func runVerifyAll(report string) int {
+	metaStore, err := NewMetaStore(Config.MetaDB)
+	if err != nil {
+		fmt.Printf("Could not open the meta store: %s\n", err)
+		return 1
+	}
+	defer metaStore.Close()
+
+	contentStore, err := NewContentStore(Config.ContentPath)
+	if err != nil {
+		fmt.Printf("Could not open the content store: %s\n", err)
+		return 1
+	}
+
+	done, err := readVerifyReport(report)
+	if err != nil {
+		fmt.Printf("Could not read the report: %s\n", err)
+		return 1
+	}
+
+	f, err := os.OpenFile(report, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		fmt.Printf("Could not open the report: %s\n", err)
+		return 1
+	}
+	defer f.Close()
+
+	passed, failed, err := VerifyAll(f, metaStore, contentStore, Config.VerifyAllWorkers(), done)
+	fmt.Printf("passed: %d\nfailed: %d\nskipped: %d\n", passed, failed, len(done))
+	if err != nil {
+		fmt.Printf("Could not verify all objects: %s\n", err)
+		return 1
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// readVerifyReport returns the oids in an existing report. A missing report
+// is empty. A line cut short by an interrupted run is ended, so that results
+// can be appended after it.
+func readVerifyReport(report string) (map[string]bool, error) {
+	done := make(map[string]bool)
+
+	f, err := os.OpenFile(report, os.O_RDWR, 0)
+	if os.IsNotExist(err) {
+		return done, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				_, err = f.Write([]byte("\n"))
+				return done, err
+			}
+			return done, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var result VerifyResult
+		if json.Unmarshal(line, &result) == nil && result.Oid != "" {
+			done[result.Oid] = true
+		}
+	}
+}
+
+// verifyAllPage is how many objects VerifyAll reads from the meta store at a
+// time.
+const verifyAllPage = 100
+
+// VerifyAll recomputes the hash of every object in the meta store that is not
+// in done, using workers goroutines, and writes a VerifyResult line for each
+// to w. It returns how many objects passed and failed.
+func VerifyAll(w io.Writer, metaStore *MetaStore, contentStore *ContentStore, workers int, done map[string]bool) (int, int, error) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	var mu sync.Mutex
+	var passed, failed int
+	var writeErr error
+	enc := json.NewEncoder(w)
+
+	objects := make(chan *MetaObject)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for meta := range objects {
+				result := contentStore.verifyObject(meta)
+
+				mu.Lock()
+				if result.Pass {
+					passed++
+				} else {
+					failed++
+				}
+				if err := enc.Encode(result); err != nil && writeErr == nil {
+					writeErr = err
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	// Objects are read a page at a time and handed to the workers once the
+	// page's transaction is done, so hashing does not hold a transaction open.
+	var err error
+	after := ""
+	for {
+		var page []*MetaObject
+		if page, err = metaStore.ObjectsAfter(after, verifyAllPage); err != nil {
+			break
+		}
+		for _, meta := range page {
+			if !done[meta.Oid] {
+				objects <- meta
+			}
+		}
+		if len(page) < verifyAllPage {
+			break
+		}
+		after = page[len(page)-1].Oid
+	}
+	close(objects)
+	wg.Wait()
+
+	if err == nil {
+		err = writeErr
+	}
+	return passed, failed, err
+}
+
+// verifyObject hashes the stored content of an object and compares it with
+// the oid and size.
+func (s *ContentStore) verifyObject(meta *MetaObject) *VerifyResult {
+	result := &VerifyResult{Oid: meta.Oid, Expected: meta.Oid, Size: meta.Size}
+
+	hash, err := newOidHash(meta.Oid)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	f, err := os.Open(filepath.Join(s.basePath, transformKey(meta.Oid)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = errFileNotExist
+		}
+		result.Error = err.Error()
+		return result
+	}
+	defer f.Close()
+
+	n, err := io.Copy(hash, f)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Actual = hex.EncodeToString(hash.Sum(nil))
+	result.ActualSize = n
+	result.SizeMatch = n == meta.Size
+	result.Pass = result.SizeMatch && result.Actual == result.Expected
+	return result
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifyAll(t *testing.T) {
+	metaStore, err := NewMetaStore("verify-all-test.db")
+	if err != nil {
+		t.Fatalf("error creating meta store: %s", err)
+	}
+	defer os.Remove("verify-all-test.db")
+	defer metaStore.Close()
+
+	contentStore, err := NewContentStore("verify-all-content-test")
+	if err != nil {
+		t.Fatalf("error creating content store: %s", err)
+	}
+	defer os.RemoveAll("verify-all-content-test")
+
+	oids := make(map[string]string)
+	for _, data := range []string{"good", "also good", "corrupt", "missing"} {
+		oid := fmt.Sprintf("%x", sha256.Sum256([]byte(data)))
+		meta, err := metaStore.Put(&RequestVars{Oid: oid, Size: int64(len(data))})
+		if err != nil {
+			t.Fatalf("error putting object: %s", err)
+		}
+		if data != "missing" {
+			if err := contentStore.Put(meta, bytes.NewBufferString(data)); err != nil {
+				t.Fatalf("error storing content: %s", err)
+			}
+		}
+		oids[data] = oid
+	}
+
+	// Plant a corrupt object of the right size
+	path := filepath.Join("verify-all-content-test", transformKey(oids["corrupt"]))
+	if err := ioutil.WriteFile(path, []byte("CORRUPT"), 0640); err != nil {
+		t.Fatalf("error corrupting content: %s", err)
+	}
+
+	var report bytes.Buffer
+	passed, failed, err := VerifyAll(&report, metaStore, contentStore, 2, nil)
+	if err != nil {
+		t.Fatalf("expected verify-all to succeed, got: %s", err)
+	}
+	if passed != 2 || failed != 2 {
+		t.Errorf("expected 2 passed and 2 failed, got %d and %d", passed, failed)
+	}
+
+	results := make(map[string]VerifyResult)
+	scanner := bufio.NewScanner(bytes.NewReader(report.Bytes()))
+	for scanner.Scan() {
+		var result VerifyResult
+		if err := json.Unmarshal(scanner.Bytes(), &result); err != nil {
+			t.Fatalf("expected report lines to be JSON, got %q", scanner.Text())
+		}
+		results[result.Oid] = result
+	}
+	if len(results) != 4 {
+		t.Fatalf("expected a line per object, got %d", len(results))
+	}
+
+	for _, data := range []string{"good", "also good"} {
+		if r := results[oids[data]]; !r.Pass || !r.SizeMatch || r.Actual != r.Expected {
+			t.Errorf("expected %q to pass, got %+v", data, r)
+		}
+	}
+	if r := results[oids["corrupt"]]; r.Pass || !r.SizeMatch || r.Actual == r.Expected || r.Actual == "" {
+		t.Errorf("expected the corrupt object to fail on its hash, got %+v", r)
+	}
+	if r := results[oids["missing"]]; r.Pass || r.Error != errFileNotExist.Error() {
+		t.Errorf("expected the missing object to fail, got %+v", r)
+	}
+}
+
+func TestVerifyAllResume(t *testing.T) {
+	metaStore, err := NewMetaStore("verify-all-test.db")
+	if err != nil {
+		t.Fatalf("error creating meta store: %s", err)
+	}
+	defer os.Remove("verify-all-test.db")
+	defer metaStore.Close()
+
+	contentStore, err := NewContentStore("verify-all-content-test")
+	if err != nil {
+		t.Fatalf("error creating content store: %s", err)
+	}
+	defer os.RemoveAll("verify-all-content-test")
+
+	var oids []string
+	for _, data := range []string{"first", "second"} {
+		oid := fmt.Sprintf("%x", sha256.Sum256([]byte(data)))
+		meta, err := metaStore.Put(&RequestVars{Oid: oid, Size: int64(len(data))})
+		if err != nil {
+			t.Fatalf("error putting object: %s", err)
+		}
+		if err := contentStore.Put(meta, bytes.NewBufferString(data)); err != nil {
+			t.Fatalf("error storing content: %s", err)
+		}
+		oids = append(oids, oid)
+	}
+
+	// A report interrupted while writing its second line
+	report := "verify-all-report-test.jsonl"
+	defer os.Remove(report)
+	first, _ := json.Marshal(VerifyResult{Oid: oids[0], Pass: true})
+	if err := ioutil.WriteFile(report, append(first, []byte("\n{\"oid\":\"trunc")...), 0640); err != nil {
+		t.Fatalf("error writing report: %s", err)
+	}
+
+	done, err := readVerifyReport(report)
+	if err != nil {
+		t.Fatalf("expected the report to be read, got: %s", err)
+	}
+	if len(done) != 1 || !done[oids[0]] {
+		t.Fatalf("expected the finished object to be skipped, got %v", done)
+	}
+
+	f, err := os.OpenFile(report, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("error opening report: %s", err)
+	}
+	passed, failed, err := VerifyAll(f, metaStore, contentStore, 1, done)
+	f.Close()
+	if err != nil || passed != 1 || failed != 0 {
+		t.Fatalf("expected only the remaining object to be verified, got %d passed, %d failed, %v", passed, failed, err)
+	}
+
+	if done, err = readVerifyReport(report); err != nil || len(done) != 2 {
+		t.Errorf("expected the resumed report to cover both objects, got %v %v", done, err)
+	}
+}
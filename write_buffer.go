@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// writeBuffer coalesces MetaObject writes so they can be flushed to the meta
+// store in a single bolt transaction instead of one transaction per object.
+type writeBuffer struct {
+	mu      sync.Mutex
+	pending map[string]*MetaObject
+
+	// flushMu serializes flushes with deletes so a flush can never resurrect
+	// an object that was deleted while it was buffered.
+	flushMu sync.Mutex
+
+	size  int
+	kick  chan struct{}
+	stop  chan struct{}
+	wg    sync.WaitGroup
+	store *MetaStore
+}
+
+// EnableWriteBuffer turns on write-behind buffering of object writes. Buffered
+// writes are flushed every interval, or as soon as size objects are pending.
+func (s *MetaStore) EnableWriteBuffer(size int, interval time.Duration) {
+	b := &writeBuffer{
+		pending: make(map[string]*MetaObject),
+		size:    size,
+		kick:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		store:   s,
+	}
+	s.buffer = b
+
+	b.wg.Add(1)
+	go b.run(interval)
+}
+
+func (b *writeBuffer) run(interval time.Duration) {
+	defer b.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-b.kick:
+		case <-b.stop:
+			return
+		}
+
+		if err := b.flush(); err != nil {
+			logger.Log(kv{"fn": "writeBuffer", "err": "Could not flush meta writes: " + err.Error()})
+		}
+	}
+}
+
+func (b *writeBuffer) add(meta *MetaObject) {
+	b.mu.Lock()
+	b.pending[meta.Oid] = meta
+	full := len(b.pending) >= b.size
+	b.mu.Unlock()
+
+	if full {
+		select {
+		case b.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (b *writeBuffer) get(oid string) (*MetaObject, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	meta, ok := b.pending[oid]
+	if !ok {
+		return nil, false
+	}
+	m := *meta
+	return &m, true
+}
+
+func (b *writeBuffer) snapshot() []*MetaObject {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	objects := make([]*MetaObject, 0, len(b.pending))
+	for _, meta := range b.pending {
+		objects = append(objects, meta)
+	}
+	return objects
+}
+
+// flush writes all pending objects in one transaction. Objects stay visible
+// in the buffer until the transaction has committed.
+func (b *writeBuffer) flush() error {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+
+	objects := b.snapshot()
+	if len(objects) == 0 {
+		return nil
+	}
+
+	err := b.store.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(objectsBucket)
+		if bucket == nil {
+			return errNoBucket
+		}
+
+		for _, meta := range objects {
+			var buf bytes.Buffer
+			enc := gob.NewEncoder(&buf)
+			if err := enc.Encode(meta); err != nil {
+				return err
+			}
+
+			if err := bucket.Put([]byte(meta.Oid), buf.Bytes()); err != nil {
+				return err
+			}
+			if err := indexUploader(tx, meta); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	for _, meta := range objects {
+		if b.pending[meta.Oid] == meta {
+			delete(b.pending, meta.Oid)
+		}
+	}
+	b.mu.Unlock()
+
+	return nil
+}
+
+// remove drops a buffered object. The caller must hold flushMu.
+func (b *writeBuffer) remove(oid string) {
+	b.mu.Lock()
+	delete(b.pending, oid)
+	b.mu.Unlock()
+}
+
+func (b *writeBuffer) close() error {
+	close(b.stop)
+	b.wg.Wait()
+	return b.flush()
+}
+
+// Flush synchronously writes any buffered object writes to the store. It is a
+// no-op when write buffering is not enabled.
+func (s *MetaStore) Flush() error {
+	if s.buffer == nil {
+		return nil
+	}
+	return s.buffer.flush()
+}